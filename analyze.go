@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+	"compress_comics/internal/processor"
+)
+
+// Detail levels of the analyze output
+const (
+	analyzeSummary = "summary" // Totals only
+	analyzeFiles   = "files"   // One line per archive
+	analyzePages   = "pages"   // And one per page
+)
+
+// analyzeSorts maps -sort keys to orderings of analyze entries. Numeric keys
+// sort largest first.
+var analyzeSorts = map[string]func(a, b *analyzeEntry) bool{
+	"path":    func(a, b *analyzeEntry) bool { return cbz.NaturalLess(a.Path, b.Path) },
+	"size":    func(a, b *analyzeEntry) bool { return a.Size > b.Size },
+	"mbpp":    func(a, b *analyzeEntry) bool { return a.MBPerPage > b.MBPerPage },
+	"savings": func(a, b *analyzeEntry) bool { return a.EstimatedSavings > b.EstimatedSavings },
+	"pages":   func(a, b *analyzeEntry) bool { return a.Pages > b.Pages },
+}
+
+// analyzeEntry is one archive in the analyze output
+type analyzeEntry struct {
+	Path                string        `json:"path"`
+	Verdict             string        `json:"verdict"` // process, skip or error
+	Reason              string        `json:"reason,omitempty"`
+	Reasons             []string      `json:"reasons,omitempty"`
+	Size                int64         `json:"size_bytes"`
+	Pages               int           `json:"pages"`
+	MBPerPage           float64       `json:"mb_per_page"`
+	MaxWidth            int           `json:"max_width"`
+	MaxHeight           int           `json:"max_height"`
+	EstimatedSavings    int64         `json:"estimated_savings_bytes,omitempty"`
+	EstimatedSavingsPct float64       `json:"estimated_savings_percent,omitempty"`
+	PageList            []analyzePage `json:"page_list,omitempty"`
+
+	result *analyzer.AnalysisResult // nil when the archive couldn't be read
+}
+
+// analyzePage is one page of an archive in the analyze output
+type analyzePage struct {
+	Path         string  `json:"path"`
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	Size         int64   `json:"size_bytes"`
+	BitsPerPixel float64 `json:"bits_per_pixel"`
+}
+
+// analyzeTotals is the summary of the analyze output
+type analyzeTotals struct {
+	Archives         int            `json:"archives"`
+	ToProcess        int            `json:"to_process"`
+	ToSkip           int            `json:"to_skip"`
+	Unreadable       int            `json:"unreadable"`
+	SkipKinds        map[string]int `json:"skip_kinds,omitempty"`
+	CurrentSize      int64          `json:"current_bytes"`
+	EstimatedSize    int64          `json:"estimated_bytes"`
+	EstimatedSavings int64          `json:"estimated_savings_bytes"`
+	SavingsPercent   float64        `json:"estimated_savings_percent"`
+}
+
+// runAnalyze implements the analyze subcommand
+func runAnalyze(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	var (
+		inputPath    string
+		maxDim       int
+		threshold    float64
+		ignoreMarker bool
+		recursive    bool
+		workers      int
+		detail       string
+		format       string
+		sortKey      string
+	)
+	fs.StringVar(&inputPath, "input", "", "Path to CBZ file or directory (required)")
+	fs.StringVar(&inputPath, "i", "", "Path to CBZ file or directory (shorthand)")
+	fs.IntVar(&maxDim, "max-dim", baseCfg.MaxDimension, "Maximum image dimension")
+	fs.Float64Var(&threshold, "threshold", baseCfg.ThresholdMBPage, "MB per page threshold for processing")
+	fs.BoolVar(&ignoreMarker, "ignore-marker", baseCfg.IgnoreMarker, "Re-evaluate archives a previous run marked as processed")
+	fs.BoolVar(&recursive, "recursive", true, "Scan directories recursively")
+	fs.IntVar(&workers, "workers", runtime.NumCPU(), "Number of archives read in parallel")
+	fs.StringVar(&detail, "detail", analyzeFiles, "Detail level: summary, files or pages")
+	fs.StringVar(&format, "format", "text", "Output format: text, json or csv")
+	fs.StringVar(&sortKey, "sort", "path", "Order of archives: path, size, mbpp, savings or pages (numbers largest first)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s analyze -input <path> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Reports which archives a run would process and why, with estimated savings,\n")
+		fmt.Fprintf(os.Stderr, "from zip directories and image headers only. Unlike -dry-run it never builds\n")
+		fmt.Fprintf(os.Stderr, "the processing pipeline, so nothing is written (no backup directory, journal or\n")
+		fmt.Fprintf(os.Stderr, "temp files) and read-only mounts are fine. Per-archive overrides are not applied.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if inputPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input is required")
+		fs.Usage()
+		return 1
+	}
+	if workers < 1 {
+		fmt.Fprintln(os.Stderr, "Error: workers must be at least 1")
+		return 1
+	}
+	if detail != analyzeSummary && detail != analyzeFiles && detail != analyzePages {
+		fmt.Fprintf(os.Stderr, "Error: unknown detail level %q (must be summary, files or pages)\n", detail)
+		return 1
+	}
+	if format != "text" && format != "json" && format != "csv" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (must be text, json or csv)\n", format)
+		return 1
+	}
+	if format == "csv" && detail == analyzeSummary {
+		fmt.Fprintln(os.Stderr, "Error: -format csv lists archives or pages; use -detail files or pages")
+		return 1
+	}
+	less, ok := analyzeSorts[sortKey]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown sort key %q (must be path, size, mbpp, savings or pages)\n", sortKey)
+		return 1
+	}
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot access %s: %v\n", inputPath, err)
+		return 1
+	}
+
+	cfg := *baseCfg
+	cfg.Recursive = recursive
+	files := []string{inputPath}
+	if info.IsDir() {
+		files, err = processor.FindArchives(cfg, inputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	a := analyzer.NewAnalyzer(maxDim, threshold, analyzer.Options{
+		IgnoreMarker:  ignoreMarker,
+		FormatPolicy:  cfg.FormatPolicy,
+		Extensions:    cfg.ProcessExtensions,
+		ComposeAspect: cfg.ComposeAspect,
+		Webtoon:       cfg.Webtoon,
+		KeepModern:    cfg.KeepModernKBPerMP,
+	})
+	entries := analyzeArchives(a, files, workers, detail == analyzePages)
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+	totals := newAnalyzeTotals(entries)
+
+	switch format {
+	case "json":
+		out := struct {
+			Summary analyzeTotals   `json:"summary"`
+			Files   []*analyzeEntry `json:"files,omitempty"`
+		}{Summary: totals}
+		if detail != analyzeSummary {
+			out.Files = entries
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	case "csv":
+		if err := writeAnalyzeCSV(entries, detail == analyzePages); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	default:
+		if detail != analyzeSummary {
+			for _, entry := range entries {
+				printAnalyzeEntry(entry)
+			}
+			if len(entries) > 0 {
+				fmt.Println()
+			}
+		}
+		printAnalyzeTotals(totals)
+	}
+	return 0
+}
+
+// analyzeArchives analyzes files in parallel, returning entries in file
+// order. Savings are estimated for archives that would be processed.
+func analyzeArchives(a *analyzer.Analyzer, files []string, workers int, pages bool) []*analyzeEntry {
+	entries := make([]*analyzeEntry, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entries[i] = analyzeArchive(a, files[i], pages)
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return entries
+}
+
+// analyzeArchive builds the entry of one archive
+func analyzeArchive(a *analyzer.Analyzer, path string, pages bool) *analyzeEntry {
+	entry := &analyzeEntry{Path: path}
+	result, err := a.Analyze(path)
+	if err != nil {
+		entry.Verdict = "error"
+		entry.Reason = err.Error()
+		if info, statErr := os.Stat(path); statErr == nil {
+			entry.Size = info.Size()
+		}
+		return entry
+	}
+	a.EstimateSavings(result)
+
+	entry.result = result
+	entry.Size = result.FileSize
+	entry.Pages = result.PageCount
+	entry.MBPerPage = result.MBPerPage
+	entry.MaxWidth, entry.MaxHeight = result.MaxWidth, result.MaxHeight
+	if result.NeedsProcessing {
+		entry.Verdict = "process"
+		entry.Reasons = result.ProcessingReasons
+		entry.EstimatedSavings = result.EstimatedSavingsBytes
+		entry.EstimatedSavingsPct = result.EstimatedSavingsPct
+	} else {
+		entry.Verdict = "skip"
+		entry.Reason = result.SkipReason
+	}
+	if pages {
+		for _, page := range result.Pages {
+			entry.PageList = append(entry.PageList, analyzePage{
+				Path:         page.Path,
+				Width:        page.Width,
+				Height:       page.Height,
+				Size:         page.Size,
+				BitsPerPixel: page.BitsPerPixel(),
+			})
+		}
+	}
+	return entry
+}
+
+// newAnalyzeTotals sums up entries
+func newAnalyzeTotals(entries []*analyzeEntry) analyzeTotals {
+	totals := analyzeTotals{Archives: len(entries)}
+	var results []*analyzer.AnalysisResult
+	for _, entry := range entries {
+		if entry.result == nil {
+			totals.Unreadable++
+			continue
+		}
+		results = append(results, entry.result)
+		if !entry.result.NeedsProcessing {
+			if totals.SkipKinds == nil {
+				totals.SkipKinds = make(map[string]int)
+			}
+			kind := entry.result.SkipKind
+			if kind == "" {
+				kind = "other"
+			}
+			totals.SkipKinds[kind]++
+		}
+	}
+
+	summary := analyzer.NewDryRunSummary(results)
+	totals.ToProcess = len(summary.FilesToProcess)
+	totals.ToSkip = len(summary.FilesToSkip)
+	totals.CurrentSize = summary.TotalCurrentSize
+	totals.EstimatedSize = summary.TotalEstimatedNew
+	totals.EstimatedSavings = summary.TotalSavings
+	totals.SavingsPercent = summary.SavingsPercent
+	return totals
+}
+
+// printAnalyzeEntry prints an archive, and its pages when they were collected
+func printAnalyzeEntry(entry *analyzeEntry) {
+	if entry.result == nil {
+		fmt.Printf("[ERROR]   %s - %s\n", entry.Path, entry.Reason)
+		return
+	}
+	stats := fmt.Sprintf("%s, %d pages, %.2f MB/page, max %dx%d",
+		processor.FormatBytes(entry.Size), entry.Pages, entry.MBPerPage, entry.MaxWidth, entry.MaxHeight)
+	if entry.Verdict == "process" {
+		fmt.Printf("[PROCESS] %s (%s) - %s; ~%s (%.1f%%) to save\n", entry.Path, stats,
+			strings.Join(entry.Reasons, ", "), processor.FormatBytes(entry.EstimatedSavings), entry.EstimatedSavingsPct)
+	} else {
+		fmt.Printf("[SKIP]    %s (%s) - %s\n", entry.Path, stats, entry.Reason)
+	}
+	for _, page := range entry.PageList {
+		fmt.Printf("    %-32s %5dx%-5d %10s %6.2f bpp\n", filepath.ToSlash(page.Path), page.Width, page.Height,
+			processor.FormatBytes(page.Size), page.BitsPerPixel)
+	}
+}
+
+// printAnalyzeTotals prints the summary in the layout of the dry-run summary
+func printAnalyzeTotals(totals analyzeTotals) {
+	fmt.Println("=== Analyze Summary ===")
+	fmt.Printf("Archives:         %d\n", totals.Archives)
+	fmt.Printf("Files to process: %d\n", totals.ToProcess)
+	fmt.Printf("Files to skip:    %d\n", totals.ToSkip)
+	kinds := make([]string, 0, len(totals.SkipKinds))
+	for kind := range totals.SkipKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("  %-20s %d\n", kind+":", totals.SkipKinds[kind])
+	}
+	if totals.Unreadable > 0 {
+		fmt.Printf("Unreadable:       %d\n", totals.Unreadable)
+	}
+
+	if totals.ToProcess > 0 {
+		fmt.Println()
+		fmt.Println("ESTIMATED TOTALS:")
+		fmt.Printf("  Current size:      %s\n", processor.FormatBytes(totals.CurrentSize))
+		fmt.Printf("  Estimated after:   ~%s\n", processor.FormatBytes(totals.EstimatedSize))
+		fmt.Printf("  Estimated savings: ~%s (%.1f%%)\n", processor.FormatBytes(totals.EstimatedSavings), totals.SavingsPercent)
+	}
+}
+
+// writeAnalyzeCSV writes one row per archive to stdout, or one per page
+// with the archive's path and verdict repeated
+func writeAnalyzeCSV(entries []*analyzeEntry, pages bool) error {
+	w := csv.NewWriter(os.Stdout)
+	if pages {
+		w.Write([]string{"path", "verdict", "page", "width", "height", "size_bytes", "bits_per_pixel"})
+		for _, entry := range entries {
+			for _, page := range entry.PageList {
+				w.Write([]string{
+					entry.Path,
+					entry.Verdict,
+					page.Path,
+					strconv.Itoa(page.Width),
+					strconv.Itoa(page.Height),
+					strconv.FormatInt(page.Size, 10),
+					strconv.FormatFloat(page.BitsPerPixel, 'f', 3, 64),
+				})
+			}
+		}
+	} else {
+		w.Write([]string{"path", "verdict", "reason", "size_bytes", "pages", "mb_per_page", "max_width", "max_height", "estimated_savings_bytes", "estimated_savings_percent"})
+		for _, entry := range entries {
+			reason := entry.Reason
+			if entry.Verdict == "process" {
+				reason = strings.Join(entry.Reasons, "; ")
+			}
+			w.Write([]string{
+				entry.Path,
+				entry.Verdict,
+				reason,
+				strconv.FormatInt(entry.Size, 10),
+				strconv.Itoa(entry.Pages),
+				strconv.FormatFloat(entry.MBPerPage, 'f', 3, 64),
+				strconv.Itoa(entry.MaxWidth),
+				strconv.Itoa(entry.MaxHeight),
+				strconv.FormatInt(entry.EstimatedSavings, 10),
+				strconv.FormatFloat(entry.EstimatedSavingsPct, 'f', 1, 64),
+			})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
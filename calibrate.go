@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+	"compress_comics/internal/processor"
+)
+
+// thresholdHeadroom: archives within this factor of the calibrated page size
+// gain too little from recompression to be worth rewriting
+const thresholdHeadroom = 1.5
+
+// runCalibrate implements the calibrate subcommand
+func runCalibrate(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	var (
+		inputPath   string
+		archives    int
+		pagesPer    int
+		display     int
+		targetPSNR  float64
+		dimsSpec    string
+		qualitySpec string
+		outputPath  string
+		recursive   bool
+	)
+	fs.StringVar(&inputPath, "input", "", "Path to CBZ file or directory to sample (required)")
+	fs.StringVar(&inputPath, "i", "", "Path to CBZ file or directory (shorthand)")
+	fs.IntVar(&archives, "archives", 12, "Number of archives to sample, spread across the library")
+	fs.IntVar(&pagesPer, "pages", 4, "Pages to sample per archive, spread across the archive")
+	fs.IntVar(&display, "display", 2048, "Long edge of the reading device in pixels; pages are compared at this size")
+	fs.Float64Var(&targetPSNR, "target-psnr", 38, "Quality point: PSNR in dB that 90% of sample pages must reach (higher = closer to the original)")
+	fs.StringVar(&dimsSpec, "max-dims", "1600,2048,2400,2732,3200,4096", "Comma-separated max dimensions to try")
+	fs.StringVar(&qualitySpec, "qualities", "70,75,80,85,90,95", "Comma-separated JPEG qualities to try")
+	fs.StringVar(&outputPath, "output", "cbz-compress.profile.yaml", "Profile file to write the recommendation to")
+	fs.StringVar(&outputPath, "o", "cbz-compress.profile.yaml", "Profile file (shorthand)")
+	fs.BoolVar(&recursive, "recursive", true, "Scan directories recursively")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s calibrate -input <path> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Compresses sample pages at several settings and recommends max_dimension,\n")
+		fmt.Fprintf(os.Stderr, "jpeg_quality and threshold_mb_per_page for a quality target, written to a\n")
+		fmt.Fprintf(os.Stderr, "profile for use with -%s.\n\n", config.ProfileFlag)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if inputPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input is required")
+		fs.Usage()
+		return 1
+	}
+	if archives < 1 || pagesPer < 1 || display < 1 {
+		fmt.Fprintln(os.Stderr, "Error: archives, pages and display must be at least 1")
+		return 1
+	}
+	dims, err := parseIntList(dimsSpec, 1, math.MaxInt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: max-dims: %v\n", err)
+		return 1
+	}
+	qualities, err := parseIntList(qualitySpec, 1, 100)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: qualities: %v\n", err)
+		return 1
+	}
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot access %s: %v\n", inputPath, err)
+		return 1
+	}
+	files := []string{inputPath}
+	if info.IsDir() {
+		cfg := *baseCfg
+		cfg.Recursive = recursive
+		files, err = processor.FindArchives(cfg, inputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("No CBZ files found in %s\n", inputPath)
+		return 0
+	}
+
+	pages := samplePages(files, archives, pagesPer)
+	if len(pages) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no readable pages in the sampled archives")
+		return 1
+	}
+
+	fmt.Printf("Trying %d settings on %d pages from %d archives...\n\n", len(dims)*len(qualities), len(pages), min(archives, len(files)))
+	trials, used := processor.Calibrate(pages, processor.CalibrationOptions{
+		MaxDims:   dims,
+		Qualities: qualities,
+		Display:   display,
+		Limits:    processor.DecodeLimitsFromConfig(*baseCfg),
+	})
+	if used == 0 {
+		fmt.Fprintln(os.Stderr, "Error: none of the sampled pages could be decoded")
+		return 1
+	}
+
+	best, ok := processor.Recommend(trials, targetPSNR)
+
+	fmt.Printf("%8s %8s %9s %10s %10s\n", "max-dim", "quality", "savings", "mean PSNR", "p10 PSNR")
+	for _, t := range trials {
+		mark := ""
+		if ok && t == best {
+			mark = "  <- recommended"
+		}
+		fmt.Printf("%8d %8d %8.1f%% %7.1f dB %7.1f dB%s\n", t.MaxDim, t.Quality, t.Savings()*100, t.MeanPSNR, t.LowPSNR, mark)
+	}
+	fmt.Println()
+
+	if !ok {
+		fmt.Printf("No setting reaches %.1f dB on 90%% of pages; lower -target-psnr or try higher -max-dims/-qualities\n", targetPSNR)
+		return 1
+	}
+
+	// Archives already near the calibrated size per page are not worth rewriting
+	pageMB := float64(best.Bytes) / float64(used) / (1024 * 1024)
+	threshold := math.Max(0.05, math.Ceil(pageMB*thresholdHeadroom*20)/20)
+
+	profile := config.Profile{
+		MaxDimension:    best.MaxDim,
+		JPEGQuality:     best.Quality,
+		ThresholdMBPage: threshold,
+	}
+	comment := []string{
+		"Written by cbz-compress calibrate",
+		fmt.Sprintf("Sample: %d pages from %s, compared at %dpx", used, inputPath, display),
+		fmt.Sprintf("Target: %.1f dB PSNR on 90%% of pages; expected savings %.1f%%", targetPSNR, best.Savings()*100),
+	}
+	if err := config.WriteProfile(outputPath, profile, comment); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("=== Recommendation ===")
+	fmt.Printf("max_dimension:         %d\n", profile.MaxDimension)
+	fmt.Printf("jpeg_quality:          %d\n", profile.JPEGQuality)
+	fmt.Printf("threshold_mb_per_page: %.2f (compressed pages average %.2f MB)\n", profile.ThresholdMBPage, pageMB)
+	fmt.Printf("Expected savings:      %.1f%%\n", best.Savings()*100)
+	fmt.Printf("\nWrote %s. Use it with:\n  %s -%s %s -input %s\n", outputPath, os.Args[0], config.ProfileFlag, outputPath, inputPath)
+	return 0
+}
+
+// samplePages reads up to perArchive evenly spaced pages from up to archives
+// evenly spaced files
+func samplePages(files []string, archives, perArchive int) []cbz.ImageEntry {
+	reader := cbz.NewReader()
+	var pages []cbz.ImageEntry
+	for _, i := range spread(len(files), archives) {
+		contents, err := reader.Extract(files[i])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		for _, j := range spread(len(contents.Images), perArchive) {
+			pages = append(pages, contents.Images[j])
+		}
+	}
+	return pages
+}
+
+// spread returns up to k indexes spread evenly over [0, n)
+func spread(n, k int) []int {
+	k = min(k, n)
+	indexes := make([]int, k)
+	for i := range indexes {
+		indexes[i] = i * n / k
+	}
+	return indexes
+}
+
+// parseIntList parses "a,b,c" into integers within [lo, hi]
+func parseIntList(spec string, lo, hi int) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", field)
+		}
+		if v < lo || v > hi {
+			return nil, fmt.Errorf("%d out of range", v)
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no values")
+	}
+	return values, nil
+}
@@ -0,0 +1,124 @@
+// Package cbzcompress is the public library API of cbz-compress. It exposes
+// the pipeline and its reporting hooks so other programs can compress
+// archives and follow progress without going through the CLI:
+//
+//	cfg := cbzcompress.DefaultConfig()
+//	p := cbzcompress.NewPipeline(cfg, cbzcompress.NewConsoleReporter(false, os.Stdout))
+//	p.AddReporter(myMetrics)
+//	batch, err := p.ProcessDirectory("/comics")
+package cbzcompress
+
+import (
+	"io"
+	"time"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/config"
+	"compress_comics/internal/fileclass"
+	"compress_comics/internal/processor"
+)
+
+// Configuration
+type Config = config.Config
+
+// Pipeline and its results
+type (
+	Pipeline     = processor.Pipeline
+	Result       = processor.Result
+	BatchResult  = processor.BatchResult
+	PageError    = processor.PageError
+	PageProgress = processor.PageProgress
+)
+
+// Reporting
+type (
+	ProgressReporter = processor.ProgressReporter
+	MultiReporter    = processor.MultiReporter
+	ConsoleReporter  = processor.ConsoleReporter
+	AnalysisResult   = analyzer.AnalysisResult
+	DryRunSummary    = analyzer.DryRunSummary
+)
+
+// Per-stage events, delivered to handlers registered with
+// Pipeline.Events().Subscribe
+type (
+	Event             = processor.Event
+	EventBus          = processor.EventBus
+	AnalysisEvent     = processor.AnalysisEvent
+	ExtractionEvent   = processor.ExtractionEvent
+	PageEvent         = processor.PageEvent
+	VerificationEvent = processor.VerificationEvent
+	BackupEvent       = processor.BackupEvent
+)
+
+// Skip/process rules of the caller's own, added with Pipeline.AddHeuristic
+type (
+	Heuristic = analyzer.Heuristic
+	Decision  = analyzer.Decision
+	Verdict   = analyzer.Verdict
+)
+
+// Verdicts of a Heuristic
+const (
+	Abstain = analyzer.Abstain
+	Process = analyzer.Process
+	Skip    = analyzer.Skip
+)
+
+// Page formats and codecs beyond the built-in ones
+type (
+	Format  = fileclass.Format
+	Encoder = processor.Encoder
+)
+
+// DefaultConfig returns the built-in defaults (1800px, quality 90, backups in originals_backup)
+func DefaultConfig() Config {
+	return config.DefaultConfig()
+}
+
+// LoadConfig reads a cbz-compress.yaml over the defaults
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadFromFile(path)
+}
+
+// NewPipeline creates a pipeline; reporter may be nil, and more can be
+// attached with Pipeline.AddReporter
+func NewPipeline(cfg Config, reporter ProgressReporter) *Pipeline {
+	return processor.NewPipeline(cfg, reporter)
+}
+
+// NewMultiReporter combines reporters so they all receive every event
+func NewMultiReporter(reporters ...ProgressReporter) *MultiReporter {
+	return processor.NewMultiReporter(reporters...)
+}
+
+// NewConsoleReporter returns the reporter the CLI prints with
+func NewConsoleReporter(verbose bool, w io.Writer) *ConsoleReporter {
+	return processor.NewConsoleReporter(verbose, w)
+}
+
+// EstimateRemaining extrapolates the time left in a batch from the figures
+// ProgressReporter.OnBatchProgress receives, or returns 0 before there's a
+// rate to go on
+func EstimateRemaining(processedBytes, totalBytes int64, elapsed time.Duration) time.Duration {
+	return processor.EstimateRemaining(processedBytes, totalBytes, elapsed)
+}
+
+// RegisterFormat makes the reader, analyzer and processor recognize another
+// page format, decoding it with f.Decode. Register before creating pipelines.
+func RegisterFormat(f Format) error {
+	return fileclass.RegisterFormat(f)
+}
+
+// RegisterEncoder adds a codec that -codecs / the codecs setting can race
+// against jpeg and webp; pages it wins get ext, a registered format's
+// extension
+func RegisterEncoder(name, ext string, encode Encoder) error {
+	return processor.RegisterEncoder(name, ext, encode)
+}
+
+// AddJunkPattern drops archive entries whose base name matches pattern
+// (e.g. "Thumbs.db") along with hidden files and __MACOSX folders
+func AddJunkPattern(pattern string) error {
+	return fileclass.AddJunkPattern(pattern)
+}
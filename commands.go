@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// command is a subcommand invoked as `cbz-compress <name> [flags]`
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists all subcommands; running without one compresses archives
+var commands = map[string]command{
+	"analyze":   {summary: "Report what a run would process, reading headers only (no pipeline, no writes)", run: runAnalyze},
+	"compare":   {summary: "Compare an original tree with its compressed mirror: sizes, missing archives, page counts", run: runCompare},
+	"covers":    {summary: "Extract the first page of each CBZ as a cover thumbnail", run: runCovers},
+	"explain":   {summary: "Show which rules and checks decide whether one archive is processed", run: runExplain},
+	"diff":      {summary: "Write an HTML page comparing compressed pages with their originals", run: runDiff},
+	"calibrate": {summary: "Recommend max_dimension/quality/threshold from trial compressions", run: runCalibrate},
+	"oneshot":   {summary: "Run one batch configured from CBZ_* variables, for containers (also --oneshot)", run: runOneshot},
+	"lint":      {summary: "Report structural problems in archives without changing them", run: runLint},
+	"histogram": {summary: "Show page size and compression distributions across a library", run: runHistogram},
+	"recover":   {summary: "Finish or roll back replacements interrupted by a crash", run: runRecover},
+	"repair":    {summary: "Salvage readable entries of corrupt or truncated CBZs into clean archives", run: runRepair},
+	"selftest":  {summary: "Check size and SSIM of compressed built-in sample pages", run: runSelftest},
+	"stats":     {summary: "Show cumulative savings from past runs", run: runStats},
+}
+
+// printCommands lists the available subcommands for usage output
+func printCommands(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
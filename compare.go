@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+	"compress_comics/internal/processor"
+)
+
+// Statuses of a compare entry
+const (
+	compareOK      = "ok"
+	compareMissing = "missing" // In the original tree only
+	compareExtra   = "extra"   // In the compressed tree only
+	comparePages   = "pages"   // Page counts differ
+	compareError   = "error"   // One of the pair couldn't be read
+)
+
+// compareEntry is one archive of the compare output
+type compareEntry struct {
+	Path            string `json:"path"` // Relative to the tree roots
+	Compressed      string `json:"compressed,omitempty"`
+	Status          string `json:"status"`
+	Reason          string `json:"reason,omitempty"`
+	OriginalBytes   int64  `json:"original_bytes,omitempty"`
+	CompressedBytes int64  `json:"compressed_bytes,omitempty"`
+	OriginalPages   int    `json:"original_pages,omitempty"`
+	CompressedPages int    `json:"compressed_pages,omitempty"`
+
+	original, compressed string // Paths as found ("" = not in that tree)
+}
+
+// compareTotals is the summary of the compare output, with sizes over the
+// pairs only
+type compareTotals struct {
+	Pairs           int     `json:"pairs"`
+	Missing         int     `json:"missing"`
+	Extra           int     `json:"extra"`
+	PageMismatches  int     `json:"page_mismatches"`
+	Unreadable      int     `json:"unreadable"`
+	OriginalBytes   int64   `json:"original_bytes"`
+	CompressedBytes int64   `json:"compressed_bytes"`
+	SavedBytes      int64   `json:"saved_bytes"`
+	SavedPercent    float64 `json:"saved_percent"`
+}
+
+// runCompare implements the compare subcommand
+func runCompare(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	var (
+		originalDir   string
+		compressedDir string
+		workers       int
+		format        string
+		mismatches    bool
+	)
+	fs.StringVar(&originalDir, "original", "", "Tree of original archives (required)")
+	fs.StringVar(&compressedDir, "compressed", "", "Tree of compressed archives, e.g. an -output-dir (required)")
+	fs.IntVar(&workers, "workers", runtime.NumCPU(), "Number of archives read in parallel")
+	fs.StringVar(&format, "format", "text", "Output format: text or json")
+	fs.BoolVar(&mismatches, "mismatches", false, "List only archives that don't match, not every pair (text format)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s compare -original <dir> -compressed <dir> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Pairs the archives of an original tree with those of a compressed mirror by\n")
+		fmt.Fprintf(os.Stderr, "relative path (a .cbz of the same name stands in for other extensions), and\n")
+		fmt.Fprintf(os.Stderr, "reports size differences per pair and in total, archives missing from either\n")
+		fmt.Fprintf(os.Stderr, "tree and pairs whose page counts differ. Exits with status 1 on any mismatch.\n")
+		fmt.Fprintf(os.Stderr, "Archives a run skipped aren't written to its output directory and show as\n")
+		fmt.Fprintf(os.Stderr, "missing; trees written with -output-name or -split don't mirror the input.\n")
+		fmt.Fprintf(os.Stderr, "Runs with -compose, -pages or -max-pages change page counts on purpose, so\n")
+		fmt.Fprintf(os.Stderr, "their archives show as page mismatches.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if originalDir == "" || compressedDir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -original and -compressed are required")
+		fs.Usage()
+		return 1
+	}
+	if workers < 1 {
+		fmt.Fprintln(os.Stderr, "Error: workers must be at least 1")
+		return 1
+	}
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (must be text or json)\n", format)
+		return 1
+	}
+	for _, dir := range []string{originalDir, compressedDir} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", dir)
+			return 1
+		}
+	}
+
+	cfg := *baseCfg
+	cfg.Recursive = true
+	entries, err := pairTrees(cfg, originalDir, compressedDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	compareArchives(entries, workers)
+	totals := newCompareTotals(entries)
+
+	if format == "json" {
+		out := struct {
+			Summary compareTotals   `json:"summary"`
+			Files   []*compareEntry `json:"files"`
+		}{totals, entries}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	} else {
+		for _, entry := range entries {
+			if !mismatches || entry.Status != compareOK {
+				printCompareEntry(entry)
+			}
+		}
+		if len(entries) > 0 {
+			fmt.Println()
+		}
+		printCompareTotals(totals)
+	}
+
+	if totals.Missing+totals.Extra+totals.PageMismatches+totals.Unreadable > 0 {
+		return 1
+	}
+	return 0
+}
+
+// pairTrees finds the archives of both trees and pairs them by relative
+// path, in path order. An original without a namesake is paired with a .cbz
+// of the same name, as -rename-to-cbz writes it.
+func pairTrees(cfg config.Config, originalDir, compressedDir string) ([]*compareEntry, error) {
+	originals, err := relativeArchives(cfg, originalDir)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := relativeArchives(cfg, compressedDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*compareEntry
+	for rel, path := range originals {
+		entry := &compareEntry{Path: rel, original: path, Status: compareMissing}
+		match := rel
+		if _, ok := compressed[match]; !ok {
+			match = strings.TrimSuffix(rel, filepath.Ext(rel)) + ".cbz"
+		}
+		if other, ok := compressed[match]; ok {
+			entry.compressed = other
+			entry.Status = compareOK
+			if match != rel {
+				entry.Compressed = match
+			}
+			delete(compressed, match)
+		}
+		entries = append(entries, entry)
+	}
+	for rel, path := range compressed {
+		entries = append(entries, &compareEntry{Path: rel, compressed: path, Status: compareExtra})
+	}
+	sort.Slice(entries, func(i, j int) bool { return cbz.NaturalLess(entries[i].Path, entries[j].Path) })
+	return entries, nil
+}
+
+// relativeArchives maps the slash path of each archive under dir, relative
+// to dir, to its full path
+func relativeArchives(cfg config.Config, dir string) (map[string]string, error) {
+	files, err := processor.FindArchives(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	archives := make(map[string]string, len(files))
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		archives[filepath.ToSlash(rel)] = path
+	}
+	return archives, nil
+}
+
+// compareArchives reads the sizes and page counts of entries in parallel,
+// settling the status of each pair
+func compareArchives(entries []*compareEntry, workers int) {
+	a := analyzer.NewAnalyzer(0, 0, analyzer.Options{IgnoreMarker: true})
+	jobs := make(chan *compareEntry)
+	var wg sync.WaitGroup
+	for range min(workers, len(entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				compareArchive(a, entry)
+			}
+		}()
+	}
+	for _, entry := range entries {
+		jobs <- entry
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// compareArchive fills in one entry
+func compareArchive(a *analyzer.Analyzer, entry *compareEntry) {
+	read := func(path string, size *int64, pages *int) error {
+		if path == "" {
+			return nil
+		}
+		result, err := a.Analyze(path)
+		if err != nil {
+			return err
+		}
+		*size, *pages = result.FileSize, result.PageCount
+		return nil
+	}
+	if err := read(entry.original, &entry.OriginalBytes, &entry.OriginalPages); err != nil {
+		entry.Status, entry.Reason = compareError, err.Error()
+		return
+	}
+	if err := read(entry.compressed, &entry.CompressedBytes, &entry.CompressedPages); err != nil {
+		entry.Status, entry.Reason = compareError, err.Error()
+		return
+	}
+	if entry.Status == compareOK && entry.OriginalPages != entry.CompressedPages {
+		entry.Status = comparePages
+		entry.Reason = fmt.Sprintf("%d pages, compressed %d", entry.OriginalPages, entry.CompressedPages)
+	}
+}
+
+// newCompareTotals sums up entries
+func newCompareTotals(entries []*compareEntry) compareTotals {
+	var totals compareTotals
+	for _, entry := range entries {
+		switch entry.Status {
+		case compareMissing:
+			totals.Missing++
+			continue
+		case compareExtra:
+			totals.Extra++
+			continue
+		case compareError:
+			totals.Unreadable++
+			continue
+		case comparePages:
+			totals.PageMismatches++
+		}
+		totals.Pairs++
+		totals.OriginalBytes += entry.OriginalBytes
+		totals.CompressedBytes += entry.CompressedBytes
+	}
+	totals.SavedBytes = totals.OriginalBytes - totals.CompressedBytes
+	if totals.OriginalBytes > 0 {
+		totals.SavedPercent = float64(totals.SavedBytes) / float64(totals.OriginalBytes) * 100
+	}
+	return totals
+}
+
+// printCompareEntry prints one archive of the comparison
+func printCompareEntry(entry *compareEntry) {
+	switch entry.Status {
+	case compareMissing:
+		fmt.Printf("[MISSING] %s - not in the compressed tree\n", entry.Path)
+	case compareExtra:
+		fmt.Printf("[EXTRA]   %s - not in the original tree\n", entry.Path)
+	case compareError:
+		fmt.Printf("[ERROR]   %s - %s\n", entry.Path, entry.Reason)
+	default:
+		status := "[OK]     "
+		if entry.Status == comparePages {
+			status = "[PAGES]  "
+		}
+		name := entry.Path
+		if entry.Compressed != "" {
+			name += " -> " + entry.Compressed
+		}
+		fmt.Printf("%s %s (%s -> %s, %s)", status, name, processor.FormatBytes(entry.OriginalBytes),
+			processor.FormatBytes(entry.CompressedBytes), savedPercent(entry.OriginalBytes, entry.CompressedBytes))
+		if entry.Status == comparePages {
+			fmt.Printf(" - %s", entry.Reason)
+		}
+		fmt.Println()
+	}
+}
+
+// savedPercent describes the change from original to compressed bytes,
+// e.g. "-42.0%"
+func savedPercent(original, compressed int64) string {
+	if original == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", float64(compressed-original)/float64(original)*100)
+}
+
+// printCompareTotals prints the summary
+func printCompareTotals(totals compareTotals) {
+	fmt.Println("=== Compare Summary ===")
+	fmt.Printf("Pairs:            %d\n", totals.Pairs)
+	fmt.Printf("Missing:          %d\n", totals.Missing)
+	fmt.Printf("Extra:            %d\n", totals.Extra)
+	fmt.Printf("Page mismatches:  %d\n", totals.PageMismatches)
+	if totals.Unreadable > 0 {
+		fmt.Printf("Unreadable:       %d\n", totals.Unreadable)
+	}
+	if totals.Pairs > 0 {
+		fmt.Println()
+		fmt.Printf("Original size:    %s\n", processor.FormatBytes(totals.OriginalBytes))
+		fmt.Printf("Compressed size:  %s\n", processor.FormatBytes(totals.CompressedBytes))
+		fmt.Printf("Saved:            %s (%.1f%%)\n", processor.FormatBytes(totals.SavedBytes), totals.SavedPercent)
+	}
+}
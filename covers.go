@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+	"compress_comics/internal/fileclass"
+	"compress_comics/internal/processor"
+)
+
+// runCovers implements the covers subcommand
+func runCovers(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("covers", flag.ExitOnError)
+	var (
+		inputPath string
+		size      int
+		quality   int
+		name      string
+		sidecar   bool
+		overwrite bool
+		recursive bool
+		dryRun    bool
+	)
+	fs.StringVar(&inputPath, "input", "", "Path to CBZ file or directory (required)")
+	fs.StringVar(&inputPath, "i", "", "Path to CBZ file or directory (shorthand)")
+	fs.IntVar(&size, "size", 400, "Maximum thumbnail dimension in pixels (long edge)")
+	fs.IntVar(&quality, "quality", 85, "Thumbnail JPEG quality (1-100)")
+	fs.StringVar(&name, "name", "cover.jpg", "File name of the per-directory cover (e.g. folder.jpg)")
+	fs.BoolVar(&sidecar, "sidecar", false, "Write one <archive name>.jpg per archive instead of one cover per directory")
+	fs.BoolVar(&overwrite, "overwrite", false, "Replace existing cover files")
+	fs.BoolVar(&recursive, "recursive", true, "Process directories recursively")
+	fs.BoolVar(&dryRun, "dry-run", false, "List the covers that would be written")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s covers -input <path> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Writes cover.jpg (or folder.jpg) thumbnails from the first page of each CBZ.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if inputPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input is required")
+		fs.Usage()
+		return 1
+	}
+	if quality < 1 || quality > 100 {
+		fmt.Fprintln(os.Stderr, "Error: quality must be between 1 and 100")
+		return 1
+	}
+	if size < 1 {
+		fmt.Fprintln(os.Stderr, "Error: size must be at least 1")
+		return 1
+	}
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot access %s: %v\n", inputPath, err)
+		return 1
+	}
+
+	files := []string{inputPath}
+	if info.IsDir() {
+		cfg := *baseCfg
+		cfg.Recursive = recursive
+		files, err = processor.FindArchives(cfg, inputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	// Map each cover path to the archive it comes from. In per-directory mode the
+	// first archive in natural order wins, matching how readers sort the folder.
+	targets := make(map[string]string)
+	order := make([]string, 0, len(files))
+	for _, path := range files {
+		coverPath := filepath.Join(filepath.Dir(path), name)
+		if sidecar {
+			coverPath = strings.TrimSuffix(path, filepath.Ext(path)) + ".jpg"
+		}
+		if existing, ok := targets[coverPath]; ok && !cbz.NaturalLess(path, existing) {
+			continue
+		} else if !ok {
+			order = append(order, coverPath)
+		}
+		targets[coverPath] = path
+	}
+
+	reader := cbz.NewReader()
+	thumbnailer := processor.NewImageProcessor(size, quality, processor.ImageOptions{Limits: processor.DecodeLimitsFromConfig(*baseCfg)})
+	var written, skipped, failed int
+
+	for _, coverPath := range order {
+		source := targets[coverPath]
+
+		if !overwrite {
+			if _, err := os.Stat(coverPath); err == nil {
+				skipped++
+				continue
+			}
+		}
+
+		if dryRun {
+			fmt.Printf("%s <- %s\n", coverPath, filepath.Base(source))
+			written++
+			continue
+		}
+
+		if err := writeCover(reader, thumbnailer, quality, source, coverPath); err != nil {
+			fmt.Fprintf(os.Stderr, "[FAIL] %s: %v\n", source, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s <- %s\n", coverPath, filepath.Base(source))
+		written++
+	}
+
+	fmt.Printf("\nCovers written: %d, existing: %d, failed: %d\n", written, skipped, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeCover extracts the first page of an archive and writes it as a JPEG thumbnail
+func writeCover(reader *cbz.Reader, thumbnailer *processor.ImageProcessor, quality int, cbzPath, coverPath string) error {
+	page, err := reader.ReadFirstImage(cbzPath)
+	if err != nil {
+		return err
+	}
+
+	thumb, err := thumbnailer.Process(*page)
+	if err != nil {
+		return err
+	}
+
+	// A small page is kept as it was when a JPEG would be larger; a cover
+	// must be a JPEG whatever its size
+	data := thumb.Data
+	if fileclass.FormatOf(thumb.NewPath) != "jpeg" {
+		img, _, err := image.Decode(bytes.NewReader(thumb.Data))
+		if err != nil {
+			return fmt.Errorf("failed to decode %s: %w", page.Path, err)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return fmt.Errorf("failed to encode cover: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	return os.WriteFile(coverPath, data, 0644)
+}
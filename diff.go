@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"html/template"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/backup"
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+	"compress_comics/internal/fileclass"
+	"compress_comics/internal/processor"
+)
+
+// Layouts of the diff page
+const (
+	diffSide    = "side"    // Original and compressed next to each other
+	diffFlicker = "flicker" // Both stacked, alternating in place
+)
+
+// runDiff implements the diff subcommand
+func runDiff(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var (
+		compressedPath string
+		originalPath   string
+		backupDir      string
+		pagesSpec      string
+		mode           string
+		outPath        string
+	)
+	fs.StringVar(&compressedPath, "input", "", "Compressed CBZ (required)")
+	fs.StringVar(&compressedPath, "i", "", "Compressed CBZ (shorthand)")
+	fs.StringVar(&originalPath, "original", "", "Original CBZ (default: the file of the same name in the backup directory)")
+	fs.StringVar(&backupDir, "backup", baseCfg.BackupDir, "Backup directory to find the original in")
+	fs.StringVar(&pagesSpec, "pages", "1-3", "Pages to compare: N, N-M, N- or -M")
+	fs.StringVar(&mode, "mode", diffSide, "Layout: side (next to each other) or flicker (alternating in place; click to pause)")
+	fs.StringVar(&outPath, "out", "", "HTML file to write (default: <archive name>.diff.html in the current directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s diff -input <compressed.cbz> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Writes a self-contained HTML page comparing pages of a compressed archive with\n")
+		fmt.Fprintf(os.Stderr, "its original, side by side or flickering between the two at the same size.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if compressedPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input is required")
+		fs.Usage()
+		return 1
+	}
+	if mode != diffSide && mode != diffFlicker {
+		fmt.Fprintf(os.Stderr, "Error: unknown mode %q (must be side or flicker)\n", mode)
+		return 1
+	}
+	pages, err := cbz.ParsePageRange(pagesSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if originalPath == "" {
+		originalPath = filepath.Join(backupDir, backup.NameFor(compressedPath))
+		if _, err := os.Stat(originalPath); err != nil {
+			// Backed up before names carried the directory hash
+			originalPath = filepath.Join(backupDir, filepath.Base(compressedPath))
+		}
+	}
+	if outPath == "" {
+		outPath = strings.TrimSuffix(filepath.Base(compressedPath), filepath.Ext(compressedPath)) + ".diff.html"
+	}
+
+	reader := cbz.NewReader()
+	original, err := reader.Extract(originalPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: original: %v\n", err)
+		return 1
+	}
+	compressed, err := reader.Extract(compressedPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(original.Images) != len(compressed.Images) {
+		fmt.Fprintf(os.Stderr, "Warning: original has %d pages, compressed %d; pages are paired by position\n", len(original.Images), len(compressed.Images))
+	}
+	if err := original.SelectPages(pages); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: original: %v\n", err)
+		return 1
+	}
+	if err := compressed.SelectPages(pages); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	page := diffPage{
+		Title:      filepath.Base(compressedPath),
+		Original:   originalPath,
+		Compressed: compressedPath,
+		Flicker:    mode == diffFlicker,
+	}
+	for i := range min(len(original.Images), len(compressed.Images)) {
+		page.Pairs = append(page.Pairs, diffPair{
+			Number:     pages.First + i,
+			Original:   newDiffImage(original.Images[i]),
+			Compressed: newDiffImage(compressed.Images[i]),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := diffTemplate.Execute(&buf, page); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(outPath, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s (%d pages, %s)\n", outPath, len(page.Pairs), mode)
+	return 0
+}
+
+// diffPage is the data of the HTML template
+type diffPage struct {
+	Title      string
+	Original   string
+	Compressed string
+	Flicker    bool
+	Pairs      []diffPair
+}
+
+// diffPair is one page in both versions
+type diffPair struct {
+	Number     int
+	Original   diffImage
+	Compressed diffImage
+}
+
+// diffImage is a page embedded as a data URL
+type diffImage struct {
+	Name string
+	URL  template.URL
+	Size string
+	Dims string
+}
+
+// newDiffImage embeds a page and describes its size and dimensions
+func newDiffImage(img cbz.ImageEntry) diffImage {
+	mime := "image/" + fileclass.FormatOf(img.Path)
+	d := diffImage{
+		Name: img.Path,
+		URL:  template.URL("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(img.Data)),
+		Size: processor.FormatBytes(int64(len(img.Data))),
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data)); err == nil {
+		d.Dims = fmt.Sprintf("%dx%d", cfg.Width, cfg.Height)
+	}
+	return d
+}
+
+var diffTemplate = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; background: #222; color: #ddd; margin: 1em; }
+h2 { font-size: 1em; }
+.pair { display: flex; gap: 1em; margin-bottom: 2em; }
+.pair figure { flex: 1; margin: 0; }
+img { width: 100%; display: block; }
+.stack { position: relative; max-width: 900px; cursor: pointer; }
+.stack img.top { position: absolute; top: 0; left: 0; animation: flicker 1s steps(1) infinite; }
+.stack.paused img.top { animation-play-state: paused; }
+@keyframes flicker { 50% { opacity: 0; } }
+figcaption { font-size: 0.85em; margin: 0.3em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Original: {{.Original}}<br>Compressed: {{.Compressed}}</p>
+{{range .Pairs}}
+<h2>Page {{.Number}}</h2>
+{{if $.Flicker}}
+<p>Alternates every half second: compressed {{.Compressed.Name}} ({{.Compressed.Size}}, {{.Compressed.Dims}}) and original {{.Original.Name}} ({{.Original.Size}}, {{.Original.Dims}}). Click to pause.</p>
+<div class="stack" onclick="this.classList.toggle('paused')">
+<img src="{{.Original.URL}}" alt="original">
+<img class="top" src="{{.Compressed.URL}}" alt="compressed">
+</div>
+{{else}}
+<div class="pair">
+<figure><figcaption>Original: {{.Original.Name}} ({{.Original.Size}}, {{.Original.Dims}})</figcaption><img src="{{.Original.URL}}" alt="original"></figure>
+<figure><figcaption>Compressed: {{.Compressed.Name}} ({{.Compressed.Size}}, {{.Compressed.Dims}})</figcaption><img src="{{.Compressed.URL}}" alt="compressed"></figure>
+</div>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
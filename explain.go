@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+	"compress_comics/internal/override"
+	"compress_comics/internal/processor"
+)
+
+// runExplain implements the explain subcommand
+func runExplain(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	cfg := *baseCfg
+	var root string
+	fs.IntVar(&cfg.MaxDimension, "max-dim", cfg.MaxDimension, "Maximum image dimension")
+	fs.Float64Var(&cfg.ThresholdMBPage, "threshold", cfg.ThresholdMBPage, "MB per page threshold for processing")
+	fs.BoolVar(&cfg.IgnoreMarker, "ignore-marker", cfg.IgnoreMarker, "Re-evaluate archives a previous run marked as processed")
+	fs.BoolVar(&cfg.Force, "force", false, "Process even if the file appears optimized")
+	fs.BoolVar(&cfg.Flatten, "flatten", false, "Renumber pages in folders into the archive root")
+	fs.BoolVar(&cfg.Split, "split", false, "Write each chapter folder of a merged archive as its own CBZ")
+	fs.BoolVar(&cfg.JoinSplit, "join-split", false, "Rewrite split sets as one archive even when nothing else needs doing")
+	fs.StringVar(&root, "root", "", "Input directory of the run, for series_rules matching directories above the file's own")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s explain [options] <file.cbz>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Shows why a run would process or skip one archive: the rules and overrides\n")
+		fmt.Fprintf(os.Stderr, "that apply to it, every check of the skip heuristic with the pages that trip\n")
+		fmt.Fprintf(os.Stderr, "it, and the verdict. Pass the same options as the run being debugged.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Error: explain takes exactly one archive")
+		fs.Usage()
+		return 1
+	}
+	path := fs.Arg(0)
+	if err := cfg.SeriesRules.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := cfg.ComicInfoRules.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := explain(cfg, path, root); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// explain prints the decision a run with cfg makes for the archive at path,
+// following the order of the pipeline's checks
+func explain(cfg config.Config, path, root string) error {
+	sources, err := override.Sources(path, root, cfg.SeriesRules, cfg.ComicInfoRules)
+	if err != nil {
+		return err
+	}
+	ov, err := override.Resolve(path, root, cfg.SeriesRules, cfg.ComicInfoRules)
+	if err != nil {
+		return err
+	}
+	excluded := ""
+	if ov != nil {
+		cfg = ov.Apply(cfg)
+		excluded = ov.SkipReason()
+	}
+
+	// A run reads the parts of a split set joined, and so is it analyzed here
+	parts, err := cbz.SplitParts(path)
+	if err != nil {
+		return err
+	}
+	source := path
+	if parts != nil {
+		if source, err = joinForExplain(path, parts); err != nil {
+			return err
+		}
+		defer os.Remove(source)
+	}
+	a := analyzer.NewAnalyzer(cfg.MaxDimension, cfg.ThresholdMBPage, analyzer.Options{
+		IgnoreMarker:  cfg.IgnoreMarker,
+		FormatPolicy:  cfg.FormatPolicy,
+		Extensions:    cfg.ProcessExtensions,
+		ComposeAspect: cfg.ComposeAspect,
+		Webtoon:       cfg.Webtoon,
+		Excluded:      excluded,
+		KeepModern:    cfg.KeepModernKBPerMP,
+	})
+	result, err := a.AnalyzeAs(source, path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s, %d pages, %.2f MB/page, max %dx%d)\n", path, processor.FormatBytes(result.FileSize),
+		result.PageCount, result.MBPerPage, result.MaxWidth, result.MaxHeight)
+	if parts != nil {
+		fmt.Printf("Split set of %d archives, analyzed joined as a run reads it\n", len(parts)+1)
+	}
+	fmt.Println()
+
+	fmt.Println("Rules and overrides:")
+	if len(sources) == 0 {
+		fmt.Println("  none apply")
+	}
+	for _, source := range sources {
+		fmt.Printf("  %s: %s\n", source.Name, source.Settings)
+	}
+	fmt.Printf("  effective: max dimension %d, JPEG quality %d, threshold %.2f MB/page\n\n",
+		cfg.MaxDimension, cfg.JPEGQuality, cfg.ThresholdMBPage)
+
+	sizes := make(map[string]analyzer.PageInfo, len(result.Pages))
+	for _, page := range result.Pages {
+		sizes[page.Path] = page
+	}
+	fmt.Println("Checks, in the order a run applies them:")
+	explainCheck(excluded != "", "excluded: %s", orNone(excluded))
+	explainCheck(result.EncryptedEntries > 0, "encrypted entries: %d", result.EncryptedEntries)
+	explainCheck(result.PageCount == 0, "pages: %d", result.PageCount)
+	explainCheck(result.Marker.Valid && !cfg.IgnoreMarker, "marker: %s", markerState(result.Marker, cfg.IgnoreMarker))
+	explainCheck(result.HasOversized, "oversized pages (over %dpx): %d", cfg.MaxDimension, len(result.OversizedPages))
+	for _, name := range result.OversizedPages {
+		fmt.Printf("        %s %dx%d\n", name, sizes[name].Width, sizes[name].Height)
+	}
+	explainCheck(result.HasCMYK, "CMYK pages: %d", result.CMYKPages)
+	explainCheck(result.StripPages > 1, "strips to compose: %d", result.StripPages)
+	explainCheck(result.HasNonJPEG, "non-JPEG pages: %d", len(result.NonJPEGPages))
+	for _, name := range result.NonJPEGPages {
+		fmt.Printf("        %s\n", name)
+	}
+	explainCheck(result.MBPerPage > cfg.ThresholdMBPage, "MB/page: %.2f (threshold %.2f)", result.MBPerPage, cfg.ThresholdMBPage)
+
+	// The heuristic's verdict, settled the way a run settles it
+	heuristic := result.NeedsProcessing
+	rewrites := processor.Decide(cfg, path, excluded, result, len(parts))
+	fmt.Printf("  settings that rewrite anyway: %s\n\n", orNone(strings.Join(rewrites, ", ")))
+
+	if cfg.Force {
+		if reason, _ := processor.ForcedSkip(cfg, excluded, result.EncryptedEntries, result.Marker); reason != "" {
+			fmt.Printf("Verdict: SKIP - %s (-force doesn't override this)\n", reason)
+		} else {
+			fmt.Println("Verdict: PROCESS (-force skips the heuristics)")
+		}
+		return nil
+	}
+	switch {
+	case heuristic:
+		a.EstimateSavings(result)
+		fmt.Printf("Verdict: PROCESS - %s; ~%s (%.1f%%) to save\n", strings.Join(append(result.ProcessingReasons, rewrites...), ", "),
+			processor.FormatBytes(result.EstimatedSavingsBytes), result.EstimatedSavingsPct)
+	case result.NeedsProcessing:
+		fmt.Printf("Verdict: PROCESS - %s\n", strings.Join(rewrites, ", "))
+	default:
+		fmt.Printf("Verdict: SKIP - %s\n", result.SkipReason)
+	}
+	return nil
+}
+
+// joinForExplain joins the split set ended by head into a temporary
+// archive, returning its path
+func joinForExplain(head string, parts []string) (string, error) {
+	f, err := os.CreateTemp("", filepath.Base(head)+".joined.*.cbz")
+	if err != nil {
+		return "", fmt.Errorf("failed to join split set: %w", err)
+	}
+	f.Close()
+	if err := cbz.JoinSplit(parts, head, f.Name()); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to join split set: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// explainCheck prints one check, marked when it decided or can decide the verdict
+func explainCheck(fired bool, format string, args ...any) {
+	mark := "[ ]"
+	if fired {
+		mark = "[x]"
+	}
+	fmt.Printf("  %s %s\n", mark, fmt.Sprintf(format, args...))
+}
+
+// markerState describes a processing marker
+func markerState(marker cbz.Marker, ignored bool) string {
+	state := "none"
+	switch {
+	case marker.Valid:
+		state = fmt.Sprintf("valid (content hash %s)", marker.Hash)
+	case marker.Present:
+		state = "present but stale (the archive changed since it was written)"
+	}
+	if marker.Valid && ignored {
+		state += ", ignored (-ignore-marker)"
+	}
+	return state
+}
+
+// orNone returns s, or "none" if it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/config"
+	"compress_comics/internal/processor"
+)
+
+// Histogram bucket bounds, chosen around common tablet and scan resolutions
+var (
+	longEdgeBounds  = []float64{1200, 1600, 1800, 2048, 2400, 2732, 3200, 4096, 5000}
+	bppBounds       = []float64{0.5, 1, 1.5, 2, 3, 4, 6, 8, 12}
+	mbPerPageBounds = []float64{0.25, 0.5, 1, 1.5, 2, 3, 5, 10}
+)
+
+// histogramPercentiles are the percentiles listed for each distribution
+var histogramPercentiles = []float64{10, 25, 50, 75, 90, 99, 100}
+
+// runHistogram implements the histogram subcommand
+func runHistogram(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("histogram", flag.ExitOnError)
+	var (
+		inputPath string
+		maxDim    int
+		threshold float64
+		recursive bool
+		workers   int
+	)
+	fs.StringVar(&inputPath, "input", "", "Path to CBZ file or directory (required)")
+	fs.StringVar(&inputPath, "i", "", "Path to CBZ file or directory (shorthand)")
+	fs.IntVar(&maxDim, "max-dim", baseCfg.MaxDimension, "Max dimension to report the share of oversized pages for")
+	fs.Float64Var(&threshold, "threshold", baseCfg.ThresholdMBPage, "MB per page threshold to report the share of archives above")
+	fs.BoolVar(&recursive, "recursive", true, "Scan directories recursively")
+	fs.IntVar(&workers, "workers", runtime.NumCPU(), "Number of archives read in parallel")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s histogram -input <path> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Shows page dimension, bits-per-pixel and MB/page distributions across a library\n")
+		fmt.Fprintf(os.Stderr, "(reads image headers only), to choose -max-dim and -threshold values.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if inputPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input is required")
+		fs.Usage()
+		return 1
+	}
+	if workers < 1 {
+		fmt.Fprintln(os.Stderr, "Error: workers must be at least 1")
+		return 1
+	}
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot access %s: %v\n", inputPath, err)
+		return 1
+	}
+
+	files := []string{inputPath}
+	if info.IsDir() {
+		cfg := *baseCfg
+		cfg.Recursive = recursive
+		files, err = processor.FindArchives(cfg, inputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("No CBZ files found in %s\n", inputPath)
+		return 0
+	}
+
+	stats, failed := collectLibraryStats(files, maxDim, threshold, workers)
+
+	fmt.Println("=== Library ===")
+	fmt.Printf("Archives: %d (%s)\n", stats.Archives, processor.FormatBytes(stats.Bytes))
+	fmt.Printf("Pages:    %d\n", stats.Pages)
+	if failed > 0 {
+		fmt.Printf("Unreadable archives: %d\n", failed)
+	}
+	if stats.Pages == 0 {
+		return 0
+	}
+
+	fmt.Println()
+	fmt.Println("=== Percentiles ===")
+	printPercentileHeader()
+	printPercentiles("Long edge (px)", &stats.LongEdge, "%.0f")
+	printPercentiles("Short edge (px)", &stats.ShortEdge, "%.0f")
+	printPercentiles("Bits/pixel", &stats.BPP, "%.2f")
+	printPercentiles("MB/page", &stats.MBPerPage, "%.2f")
+
+	fmt.Println()
+	fmt.Println("=== Long Edge (pages) ===")
+	printHistogram(stats.LongEdge.Histogram(longEdgeBounds), stats.Pages, "%.0f")
+
+	fmt.Println()
+	fmt.Println("=== Bits per Pixel (pages) ===")
+	printHistogram(stats.BPP.Histogram(bppBounds), stats.Pages, "%.1f")
+
+	fmt.Println()
+	fmt.Println("=== MB per Page (archives) ===")
+	printHistogram(stats.MBPerPage.Histogram(mbPerPageBounds), stats.MBPerPage.Count(), "%.2f")
+
+	fmt.Println()
+	fmt.Println("=== At Current Settings ===")
+	fmt.Printf("%-40s %5.1f%%\n", fmt.Sprintf("Pages over max-dim %d:", maxDim), stats.LongEdge.FractionAbove(float64(maxDim))*100)
+	fmt.Printf("%-40s %5.1f%%\n", fmt.Sprintf("Archives over %.2f MB/page threshold:", threshold), stats.MBPerPage.FractionAbove(threshold)*100)
+
+	return 0
+}
+
+// collectLibraryStats analyzes files in parallel, returning the aggregate and
+// the number of archives that could not be read
+func collectLibraryStats(files []string, maxDim int, threshold float64, workers int) (*analyzer.LibraryStats, int) {
+	a := analyzer.NewAnalyzer(maxDim, threshold, analyzer.Options{IgnoreMarker: true})
+	stats := &analyzer.LibraryStats{}
+	failed := 0
+
+	jobs := make(chan string)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for range min(workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				result, err := a.Analyze(path)
+				mu.Lock()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+					failed++
+				} else {
+					stats.Add(result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range files {
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+
+	return stats, failed
+}
+
+func printPercentileHeader() {
+	fmt.Printf("%-16s", "")
+	for _, p := range histogramPercentiles {
+		label := fmt.Sprintf("p%.0f", p)
+		if p == 100 {
+			label = "max"
+		}
+		fmt.Printf(" %8s", label)
+	}
+	fmt.Println()
+}
+
+func printPercentiles(label string, d *analyzer.Distribution, format string) {
+	fmt.Printf("%-16s", label)
+	for _, p := range histogramPercentiles {
+		fmt.Printf(" %8s", fmt.Sprintf(format, d.Percentile(p)))
+	}
+	fmt.Println()
+}
+
+// printHistogram prints one bar per bucket, scaled to the fullest bucket
+func printHistogram(buckets []analyzer.HistogramBucket, total int, format string) {
+	const barWidth = 40
+
+	largest := 0
+	for _, b := range buckets {
+		largest = max(largest, b.Count)
+	}
+
+	for _, b := range buckets {
+		var label string
+		switch {
+		case b.Low == 0:
+			label = "< " + fmt.Sprintf(format, b.High)
+		case math.IsInf(b.High, 1):
+			label = ">= " + fmt.Sprintf(format, b.Low)
+		default:
+			label = fmt.Sprintf(format, b.Low) + "-" + fmt.Sprintf(format, b.High)
+		}
+
+		bar := 0
+		if largest > 0 {
+			bar = int(math.Round(float64(b.Count) / float64(largest) * barWidth))
+		}
+		pct := 0.0
+		if total > 0 {
+			pct = float64(b.Count) / float64(total) * 100
+		}
+		line := fmt.Sprintf("  %-12s %7d %5.1f%%  %s", label, b.Count, pct, strings.Repeat("#", bar))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
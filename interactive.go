@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/config"
+	"compress_comics/internal/processor"
+)
+
+// selectInteractively runs a dry-run analysis over files with the run's own
+// pipeline (so per-root settings apply as they will), then asks the user
+// which candidates to process. Returns the selected paths in their original
+// order.
+func selectInteractively(cfg config.Config, pipeline *processor.Pipeline, files []string, in io.Reader, out io.Writer) ([]string, error) {
+	batch, err := pipeline.DryRun().ProcessFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	// With -force every analyzable archive is a candidate, not just the ones the heuristics flag
+	var candidates []processor.Result
+	for _, result := range batch.Results {
+		if result.Analysis == nil {
+			continue
+		}
+		if cfg.Force || result.Analysis.NeedsProcessing {
+			candidates = append(candidates, result)
+		}
+	}
+
+	if len(candidates) == 0 {
+		fmt.Fprintln(out, "\nNothing to process.")
+		return nil, nil
+	}
+
+	fmt.Fprintf(out, "\n=== SELECT FILES (%d candidates) ===\n", len(candidates))
+	fmt.Fprintln(out, "y = process, n = skip, a = process this and all remaining, q = skip this and all remaining")
+
+	scanner := bufio.NewScanner(in)
+	selected := make([]string, 0, len(candidates))
+
+	for i, candidate := range candidates {
+		analysis := candidate.Analysis
+		reasons := strings.Join(analysis.ProcessingReasons, ", ")
+		if reasons == "" {
+			reasons = "forced"
+		}
+
+		answer := ""
+		for answer == "" {
+			fmt.Fprintf(out, "[%d/%d] %s (%s, %s) process? [y/n/a/q] ",
+				i+1, len(candidates), filepath.Base(candidate.SourcePath), processor.FormatBytes(analysis.FileSize), reasons)
+			if !scanner.Scan() {
+				// EOF: treat as quit so a closed stdin never processes anything unexpectedly
+				fmt.Fprintln(out)
+				return selected, scanner.Err()
+			}
+			answer = parseAnswer(scanner.Text())
+		}
+
+		switch answer {
+		case "y":
+			selected = append(selected, candidate.SourcePath)
+		case "a":
+			for _, rest := range candidates[i:] {
+				selected = append(selected, rest.SourcePath)
+			}
+			return selected, nil
+		case "q":
+			return selected, nil
+		}
+	}
+
+	return selected, nil
+}
+
+// parseAnswer normalizes a y/n/a/q answer; returns "" for anything unrecognized
+func parseAnswer(line string) string {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return "y"
+	case "n", "no":
+		return "n"
+	case "a", "all":
+		return "a"
+	case "q", "quit":
+		return "q"
+	}
+	return ""
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/color"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
@@ -13,31 +14,40 @@ import (
 	"path/filepath"
 	"strings"
 
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/codec"
+	"compress_comics/internal/fileclass"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 )
 
-// SupportedImageExtensions for filtering
-var supportedImageExtensions = map[string]bool{
-	".jpg":  true,
-	".jpeg": true,
-	".png":  true,
-	".gif":  true,
-	".webp": true,
-	".bmp":  true,
-}
-
 // AnalysisResult contains the quick scan results for a CBZ file
 type AnalysisResult struct {
-	FilePath        string
-	FileSize        int64   // Total file size in bytes
-	PageCount       int     // Number of images (pages)
-	MaxWidth        int     // Maximum image width found
-	MaxHeight       int     // Maximum image height found
-	MBPerPage       float64 // Megabytes per page
-	HasOversized    bool    // Any image exceeds max dimension
-	HasNonJPEG      bool    // Any image is not JPEG (PNG, GIF, etc.)
-	NeedsProcessing bool    // Final verdict: should this file be processed?
-	SkipReason      string  // Why it's being skipped (if NeedsProcessing is false)
+	FilePath          string
+	FileSize          int64      // Total file size in bytes
+	PageCount         int        // Number of images (pages)
+	MaxWidth          int        // Maximum image width found
+	MaxHeight         int        // Maximum image height found
+	MBPerPage         float64    // Megabytes per page
+	HasOversized      bool       // Any image exceeds max dimension
+	HasNonJPEG        bool       // Any image is not JPEG (PNG, GIF, etc.)
+	OversizedPages    []string   // Pages behind HasOversized
+	NonJPEGPages      []string   // Pages behind HasNonJPEG
+	HasCMYK           bool       // Any CMYK page the format policy does not keep
+	HighBitDepthPages int        // Pages with 16 bits per channel (typical of huge scans)
+	CMYKPages         int        // CMYK JPEG pages (print sources; many readers render them wrong)
+	StripPages        int        // Strip slices that would be composed into pages (compose mode only)
+	EncryptedEntries  int        // Encrypted entries (DRM); such archives can't be read and are skipped
+	Chapters          []string   // Chapter or volume folders of a merged archive (see cbz.Chapters)
+	Pages             []PageInfo // Header info of every decodable page, in archive order
+	Marker            cbz.Marker // Processing marker from a previous run (zip comment)
+	NeedsProcessing   bool       // Final verdict: should this file be processed?
+	SkipReason        string     // Why it's being skipped (if NeedsProcessing is false)
+	SkipKind          string     // SkipReason's category, for the batch summary (see SkipOptimized etc.)
+	DecidedBy         string     // Name of the Heuristic that reached the verdict ("" = the built-in checks)
+	heuristicReason   string     // Its reason, prefixed with its name
 
 	// Estimation fields (for dry-run report)
 	EstimatedSavingsBytes int64    // Projected bytes saved
@@ -45,24 +55,75 @@ type AnalysisResult struct {
 	ProcessingReasons     []string // Human-readable reasons for processing
 }
 
+// PageInfo describes one page from its header
+type PageInfo struct {
+	Path          string
+	Width, Height int
+	Size          int64 // Stored (encoded) size in bytes
+}
+
+// BitsPerPixel is the page's encoded size per pixel, a measure of how
+// heavily it is compressed (0 for empty pages)
+func (p PageInfo) BitsPerPixel() float64 {
+	pixels := p.Width * p.Height
+	if pixels == 0 {
+		return 0
+	}
+	return float64(p.Size) * 8 / float64(pixels)
+}
+
+// UnderModernBar reports whether a page of size bytes and width x height
+// pixels takes fewer than kbPerMP kilobytes per megapixel
+func UnderModernBar(size int64, width, height int, kbPerMP float64) bool {
+	megapixels := float64(width) * float64(height) / 1e6
+	return megapixels > 0 && float64(size)/1024/megapixels < kbPerMP
+}
+
+// Options holds optional analyzer behaviour beyond the core thresholds
+type Options struct {
+	IgnoreMarker  bool              // Re-evaluate archives even if a previous run marked them as processed
+	FormatPolicy  cbz.FormatPolicy  // Kept formats never trigger processing
+	Extensions    cbz.ExtensionList // Nor do pages with extensions not in the list (empty = all)
+	ComposeAspect float64           // Height/width of composed pages; strips trigger processing (0 = off)
+	Webtoon       bool              // Only widths over the max dimension count as oversized
+	Excluded      string            // Never process: reason from a per-archive override ("" = not excluded)
+	KeepModern    float64           // WebP/AVIF pages under this many KB per megapixel don't count as non-JPEG (0 = they do)
+	Heuristics    []Heuristic       // The caller's own skip/process rules (see Heuristic)
+}
+
+// IsStrip reports whether a width x height page is a slice of a vertical strip
+// rather than a page: shorter than a third of a page of the given height/width
+// aspect. The margin keeps double-page spreads (about 0.7) out at aspect 1.5.
+func IsStrip(width, height int, aspect float64) bool {
+	return aspect > 0 && width > 0 && float64(height)*3 < float64(width)*aspect
+}
+
 // Analyzer performs quick scans of CBZ files to determine if they need processing
 type Analyzer struct {
 	maxDimension    int
 	thresholdMBPage float64
+	opts            Options
 }
 
 // NewAnalyzer creates a new analyzer with the given settings
-func NewAnalyzer(maxDimension int, thresholdMBPage float64) *Analyzer {
+func NewAnalyzer(maxDimension int, thresholdMBPage float64, opts Options) *Analyzer {
 	return &Analyzer{
 		maxDimension:    maxDimension,
 		thresholdMBPage: thresholdMBPage,
+		opts:            opts,
 	}
 }
 
 // Analyze performs a quick scan of a CBZ file to determine if it needs processing
 func (a *Analyzer) Analyze(cbzPath string) (*AnalysisResult, error) {
+	return a.AnalyzeAs(cbzPath, cbzPath)
+}
+
+// AnalyzeAs is Analyze for a stand-in of the archive at name, such as a
+// local copy, so the result and heuristics see the archive's own path
+func (a *Analyzer) AnalyzeAs(cbzPath, name string) (*AnalysisResult, error) {
 	result := &AnalysisResult{
-		FilePath: cbzPath,
+		FilePath: name,
 	}
 
 	// Get file size
@@ -79,6 +140,9 @@ func (a *Analyzer) Analyze(cbzPath string) (*AnalysisResult, error) {
 	}
 	defer zipReader.Close()
 
+	result.Marker = cbz.MarkerOf(&zipReader.Reader)
+	result.EncryptedEntries = cbz.EncryptedEntries(&zipReader.Reader)
+
 	// Scan all images
 	for _, file := range zipReader.File {
 		if file.FileInfo().IsDir() {
@@ -86,41 +150,71 @@ func (a *Analyzer) Analyze(cbzPath string) (*AnalysisResult, error) {
 		}
 
 		// Skip hidden files
-		baseName := filepath.Base(file.Name)
-		if strings.HasPrefix(baseName, ".") || strings.Contains(file.Name, "__MACOSX") {
+		if fileclass.IsJunk(file.Name) {
 			continue
 		}
 
-		ext := strings.ToLower(filepath.Ext(file.Name))
-		if !supportedImageExtensions[ext] {
+		// Encrypted entries have no header to read and count by their name
+		if cbz.IsEncrypted(file) {
+			if fileclass.IsImage(file.Name) {
+				result.PageCount++
+			}
 			continue
 		}
 
+		// Pages are told by content (see fileclass.PageName); the header read
+		// for that is reused for the dimensions of named pages
+		limit := int64(fileclass.SniffLen)
+		if fileclass.IsImage(file.Name) {
+			limit = headerBytes
+		}
+		head, _ := readPrefix(file, limit) // A failed read leaves the name to decide, and fails again below
+		name := fileclass.PageName(file.Name, head)
+		if name == "" {
+			continue
+		}
 		result.PageCount++
 
-		// Check if non-JPEG
-		if ext != ".jpg" && ext != ".jpeg" {
-			result.HasNonJPEG = true
-		}
+		// Pages the format policy or extension list keeps are never changed, so they can't justify processing
+		kept := a.opts.FormatPolicy.Keeps(name) || !a.opts.Extensions.Allows(name)
 
 		// Decode image config (header only, not full image)
-		rc, err := file.Open()
-		if err != nil {
-			continue // Skip files we can't open
+		cfg, cmyk, err := readPageConfig(file, head)
+		if cmyk {
+			result.CMYKPages++
+			if !kept {
+				result.HasCMYK = true
+			}
 		}
 
-		// Read just enough for header
-		data, err := io.ReadAll(rc)
-		rc.Close()
+		// Check if non-JPEG; well-compressed WebP/AVIF pages are kept as they are
+		if !kept && fileclass.FormatOf(name) != "jpeg" {
+			modern := err == nil && fileclass.IsModern(name) && a.opts.KeepModern > 0 &&
+				UnderModernBar(int64(file.UncompressedSize64), cfg.Width, cfg.Height, a.opts.KeepModern)
+			if !modern {
+				result.HasNonJPEG = true
+				result.NonJPEGPages = append(result.NonJPEGPages, name)
+			}
+		}
 		if err != nil {
-			continue
+			continue // Skip files we can't open or decode
 		}
 
-		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
-		if err != nil {
-			continue // Skip files we can't decode
+		if !kept && IsStrip(cfg.Width, cfg.Height, a.opts.ComposeAspect) {
+			result.StripPages++
+		}
+
+		if is16Bit(cfg.ColorModel) {
+			result.HighBitDepthPages++
 		}
 
+		result.Pages = append(result.Pages, PageInfo{
+			Path:   name,
+			Width:  cfg.Width,
+			Height: cfg.Height,
+			Size:   int64(file.UncompressedSize64),
+		})
+
 		// Track max dimensions
 		if cfg.Width > result.MaxWidth {
 			result.MaxWidth = cfg.Width
@@ -130,11 +224,18 @@ func (a *Analyzer) Analyze(cbzPath string) (*AnalysisResult, error) {
 		}
 
 		// Check if oversized
-		if cfg.Width > a.maxDimension || cfg.Height > a.maxDimension {
+		if !kept && (cfg.Width > a.maxDimension || cfg.Height > a.maxHeight()) {
 			result.HasOversized = true
+			result.OversizedPages = append(result.OversizedPages, name)
 		}
 	}
 
+	names := make([]string, len(result.Pages))
+	for i, page := range result.Pages {
+		names[i] = page.Path
+	}
+	result.Chapters = cbz.Chapters(names)
+
 	// Calculate MB per page
 	if result.PageCount > 0 {
 		result.MBPerPage = float64(result.FileSize) / float64(result.PageCount) / (1024 * 1024)
@@ -146,13 +247,144 @@ func (a *Analyzer) Analyze(cbzPath string) (*AnalysisResult, error) {
 	return result, nil
 }
 
+// headerBytes is how much of each page is read for its dimensions. Pages
+// whose headers run past it (large embedded ICC profiles or EXIF thumbnails)
+// are read whole.
+const headerBytes = 64 << 10
+
+// readPageConfig decodes a page's dimensions and color model from the start
+// of the entry, so analysis doesn't inflate image data that processing will
+// read anyway. head is what has already been read of the entry; a shorter
+// one than headerBytes is read again. cmyk is reported even when the config
+// can't be decoded.
+func readPageConfig(file *zip.File, head []byte) (cfg image.Config, cmyk bool, err error) {
+	data := head
+	if len(data) < headerBytes && uint64(len(data)) < file.UncompressedSize64 {
+		if data, err = readPrefix(file, headerBytes); err != nil {
+			return image.Config{}, false, err
+		}
+	}
+	cfg, cmyk, err = pageConfig(data)
+	if err != nil && uint64(len(data)) < file.UncompressedSize64 {
+		if data, err = readPrefix(file, -1); err != nil {
+			return image.Config{}, false, err
+		}
+		cfg, cmyk, err = pageConfig(data)
+	}
+	return cfg, cmyk, err
+}
+
+// readPrefix reads up to limit bytes of an entry (all of it if limit < 0)
+func readPrefix(file *zip.File, limit int64) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if limit >= 0 {
+		r = io.LimitReader(rc, limit)
+	}
+	return io.ReadAll(r)
+}
+
+// pageConfig decodes the image header in data
+func pageConfig(data []byte) (image.Config, bool, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	info, jerr := codec.ParseJPEG(data)
+	if jerr != nil || !info.CMYK() {
+		return cfg, false, err
+	}
+	// Counted from the markers: Go rejects some CMYK layouts outright
+	if err != nil {
+		cfg = image.Config{Width: info.Width, Height: info.Height, ColorModel: color.CMYKModel}
+	}
+	return cfg, true, nil
+}
+
+// maxHeight is the page height that counts as oversized: the max dimension,
+// or in webtoon mode only what JPEG can hold
+func (a *Analyzer) maxHeight() int {
+	if a.opts.Webtoon {
+		return codec.MaxJPEGDimension
+	}
+	return a.maxDimension
+}
+
+// is16Bit reports whether a decoded image in this color model has 16 bits per channel
+func is16Bit(model color.Model) bool {
+	switch model {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		return true
+	}
+	return false
+}
+
+// Skip kinds group skip reasons, whose details vary per archive
+const (
+	SkipOptimized = "already optimized"
+	SkipMarker    = "marker present"
+	SkipEncrypted = "encrypted"
+	SkipExcluded  = "excluded" // By an override or rule
+	SkipNoPages   = "no pages" // Nothing in the archive is a page
+)
+
+// EncryptedReason is the skip reason of an archive with n encrypted entries
+func EncryptedReason(n int) string {
+	return fmt.Sprintf("encrypted/DRM (%d encrypted entries)", n)
+}
+
 // shouldProcess determines if a file needs processing based on analysis results
 func (a *Analyzer) shouldProcess(result *AnalysisResult) bool {
+	// Archives excluded by their override stay untouched, whatever else applies
+	if a.opts.Excluded != "" {
+		result.SkipReason = a.opts.Excluded
+		result.SkipKind = SkipExcluded
+		return false
+	}
+
+	// Encrypted entries can't be read, so the archive can't be rewritten
+	if result.EncryptedEntries > 0 {
+		result.SkipReason = EncryptedReason(result.EncryptedEntries)
+		result.SkipKind = SkipEncrypted
+		return false
+	}
+
+	// Nothing to compress, and a rewrite would fail verification
+	if result.PageCount == 0 {
+		result.SkipReason = SkipNoPages
+		result.SkipKind = SkipNoPages
+		return false
+	}
+
+	// Skip archives we produced that haven't changed since, whatever the heuristics say
+	if result.Marker.Valid && !a.opts.IgnoreMarker {
+		result.SkipReason = fmt.Sprintf("already processed (content hash %s)", result.Marker.Hash)
+		result.SkipKind = SkipMarker
+		return false
+	}
+
+	// The caller's heuristics come before the built-in ones
+	if decided, process := a.evaluate(result); decided {
+		return process
+	}
+
 	// Always process if has oversized images
 	if result.HasOversized {
 		return true
 	}
 
+	// Always process CMYK pages: converting them to RGB fixes their colors in readers
+	if result.HasCMYK {
+		return true
+	}
+
+	// Archives of strip slices are composed into pages whatever their size
+	if result.StripPages > 1 {
+		return true
+	}
+
 	// Always process if has non-JPEG images (PNG, GIF, etc.)
 	if result.HasNonJPEG {
 		return true
@@ -166,6 +398,7 @@ func (a *Analyzer) shouldProcess(result *AnalysisResult) bool {
 	// File appears optimized, skip it
 	result.SkipReason = fmt.Sprintf("already optimized (%.2f MB/page, max %dx%d)",
 		result.MBPerPage, result.MaxWidth, result.MaxHeight)
+	result.SkipKind = SkipOptimized
 	return false
 }
 
@@ -179,15 +412,27 @@ func (a *Analyzer) FormatAnalysis(result *AnalysisResult) string {
 		reason = " - " + result.SkipReason
 	} else {
 		reasons := []string{}
+		if result.heuristicReason != "" {
+			reasons = append(reasons, result.heuristicReason)
+		}
 		if result.HasOversized {
 			reasons = append(reasons, fmt.Sprintf("oversized images (max %dx%d)", result.MaxWidth, result.MaxHeight))
 		}
 		if result.HasNonJPEG {
 			reasons = append(reasons, "non-JPEG images")
 		}
+		if result.HighBitDepthPages > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d 16-bit pages", result.HighBitDepthPages))
+		}
+		if result.CMYKPages > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d CMYK pages", result.CMYKPages))
+		}
 		if result.MBPerPage > a.thresholdMBPage {
 			reasons = append(reasons, fmt.Sprintf("%.2f MB/page > %.2f threshold", result.MBPerPage, a.thresholdMBPage))
 		}
+		if result.StripPages > 1 {
+			reasons = append(reasons, fmt.Sprintf("%d strips to compose", result.StripPages))
+		}
 		if len(reasons) > 0 {
 			reason = " - " + strings.Join(reasons, ", ")
 		}
@@ -207,11 +452,13 @@ func (a *Analyzer) EstimateSavings(result *AnalysisResult) {
 	currentSize := float64(result.FileSize)
 	estimatedFinalSize := currentSize
 	reasons := []string{}
+	if result.heuristicReason != "" {
+		reasons = append(reasons, result.heuristicReason)
+	}
 
 	// Resize estimation: area reduction squared with margin
 	if result.HasOversized {
-		maxDim := float64(max(result.MaxWidth, result.MaxHeight))
-		scaleFactor := float64(a.maxDimension) / maxDim
+		scaleFactor := min(float64(a.maxDimension)/float64(result.MaxWidth), float64(a.maxHeight())/float64(result.MaxHeight))
 		if scaleFactor < 1.0 {
 			areaRatio := scaleFactor * scaleFactor
 			estimatedFinalSize *= areaRatio * 1.2 // 20% margin for JPEG overhead
@@ -224,6 +471,15 @@ func (a *Analyzer) EstimateSavings(result *AnalysisResult) {
 		estimatedFinalSize *= 0.65
 		reasons = append(reasons, "non-JPEG conversion")
 	}
+	if result.HighBitDepthPages > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d 16-bit pages", result.HighBitDepthPages))
+	}
+	if result.CMYKPages > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d CMYK pages", result.CMYKPages))
+	}
+	if result.StripPages > 1 {
+		reasons = append(reasons, fmt.Sprintf("%d strips to compose", result.StripPages))
+	}
 
 	// High MB/page re-encoding (only if no other triggers)
 	if result.MBPerPage > a.thresholdMBPage && !result.HasOversized && !result.HasNonJPEG {
@@ -0,0 +1,52 @@
+package analyzer
+
+import "fmt"
+
+// Verdict is what a Heuristic decides about an archive
+type Verdict int
+
+const (
+	Abstain Verdict = iota // No opinion: the next heuristic, or the built-in checks, decide
+	Process                // Process the archive, whatever the built-in checks say
+	Skip                   // Leave the archive as it is
+)
+
+// Decision is a Heuristic's verdict and why it was reached
+type Decision struct {
+	Verdict Verdict
+	Reason  string // Shown as the skip or processing reason
+}
+
+// Heuristic is a skip/process rule of the caller's own, e.g. one that
+// consults a database of archives already handled elsewhere. Heuristics
+// are asked in the order given, after the checks nothing can override
+// (excluded, encrypted, no pages) and the marker, and before the built-in
+// heuristics; the first that doesn't abstain decides. Evaluate may be
+// called from several goroutines at once.
+type Heuristic interface {
+	Name() string // Identifies the heuristic in reasons, and is the SkipKind of archives it skips
+	Evaluate(result AnalysisResult) Decision
+}
+
+// evaluate asks the configured heuristics about result, recording the
+// first decision reached, and reports whether there was one
+func (a *Analyzer) evaluate(result *AnalysisResult) (decided, process bool) {
+	for _, h := range a.opts.Heuristics {
+		decision := h.Evaluate(*result)
+		if decision.Verdict == Abstain {
+			continue
+		}
+		result.DecidedBy = h.Name()
+		result.heuristicReason = h.Name()
+		if decision.Reason != "" {
+			result.heuristicReason = fmt.Sprintf("%s: %s", h.Name(), decision.Reason)
+		}
+		if decision.Verdict == Skip {
+			result.SkipReason = result.heuristicReason
+			result.SkipKind = h.Name()
+			return true, false
+		}
+		return true, true
+	}
+	return false, false
+}
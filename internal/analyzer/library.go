@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+)
+
+// Distribution collects measurements for percentiles and histograms
+type Distribution struct {
+	values []float64
+	sorted bool
+}
+
+// Add records one measurement
+func (d *Distribution) Add(v float64) {
+	d.values = append(d.values, v)
+	d.sorted = false
+}
+
+// Count returns the number of measurements
+func (d *Distribution) Count() int {
+	return len(d.values)
+}
+
+// Percentile returns the p-th percentile (0-100) by nearest rank, 0 if empty
+func (d *Distribution) Percentile(p float64) float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+	if !d.sorted {
+		sort.Float64s(d.values)
+		d.sorted = true
+	}
+	rank := int(math.Ceil(p/100*float64(len(d.values)))) - 1
+	rank = max(0, min(rank, len(d.values)-1))
+	return d.values[rank]
+}
+
+// HistogramBucket counts the measurements in [Low, High)
+type HistogramBucket struct {
+	Low, High float64 // High is +Inf for the last bucket
+	Count     int
+}
+
+// Histogram counts measurements into buckets split at the given ascending
+// bounds: below bounds[0], between consecutive bounds, and from the last
+// bound up
+func (d *Distribution) Histogram(bounds []float64) []HistogramBucket {
+	buckets := make([]HistogramBucket, len(bounds)+1)
+	low := 0.0
+	for i := range buckets {
+		high := math.Inf(1)
+		if i < len(bounds) {
+			high = bounds[i]
+		}
+		buckets[i] = HistogramBucket{Low: low, High: high}
+		low = high
+	}
+	for _, v := range d.values {
+		i := sort.SearchFloat64s(bounds, v)
+		if i < len(bounds) && v == bounds[i] {
+			i++ // bounds are inclusive lower edges
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// FractionAbove returns the share (0-1) of measurements greater than limit
+func (d *Distribution) FractionAbove(limit float64) float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+	n := 0
+	for _, v := range d.values {
+		if v > limit {
+			n++
+		}
+	}
+	return float64(n) / float64(len(d.values))
+}
+
+// LibraryStats aggregates page measurements across many archives, to choose
+// max dimension and threshold settings from the actual collection
+type LibraryStats struct {
+	Archives  int
+	Pages     int
+	Bytes     int64
+	LongEdge  Distribution // Per page, pixels
+	ShortEdge Distribution // Per page, pixels
+	BPP       Distribution // Per page, encoded bits per pixel
+	MBPerPage Distribution // Per archive, as used by the skip threshold
+}
+
+// Add records the pages of one analyzed archive
+func (s *LibraryStats) Add(result *AnalysisResult) {
+	s.Archives++
+	s.Bytes += result.FileSize
+	if result.PageCount > 0 {
+		s.MBPerPage.Add(result.MBPerPage)
+	}
+	for _, page := range result.Pages {
+		s.Pages++
+		s.LongEdge.Add(float64(max(page.Width, page.Height)))
+		s.ShortEdge.Add(float64(min(page.Width, page.Height)))
+		s.BPP.Add(page.BitsPerPixel())
+	}
+}
@@ -1,72 +1,182 @@
 package backup
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+
+	"compress_comics/internal/fsutil"
+	"compress_comics/internal/trash"
+)
+
+// Mode selects where originals go before replacement
+type Mode string
+
+const (
+	ModeDir   Mode = "dir"   // Move originals into the backup directory
+	ModeTrash Mode = "trash" // Send originals to the OS trash / recycle bin
+	ModeStore Mode = "store" // Content-addressed store in the backup directory (deduplicated)
 )
 
+// ParseMode validates a backup mode name
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case ModeDir, ModeTrash, ModeStore:
+		return Mode(s), nil
+	case "":
+		return ModeDir, nil
+	}
+	return "", fmt.Errorf("unknown backup mode %q (want dir, trash or store)", s)
+}
+
 // Manager handles backup operations for original files
 type Manager struct {
 	backupDir string
+	mode      Mode
+	locations map[string]string // original path -> where it was moved (for restore)
+	reserved  map[string]bool   // Backup paths handed out but not yet written
 	mu        sync.Mutex
 }
 
-// NewManager creates a backup manager with the specified directory
-func NewManager(backupDir string) *Manager {
-	return &Manager{backupDir: backupDir}
+// NewManager creates a backup manager with the specified directory and mode
+func NewManager(backupDir string, mode Mode) *Manager {
+	if mode == "" {
+		mode = ModeDir
+	}
+	return &Manager{
+		backupDir: backupDir,
+		mode:      mode,
+		locations: make(map[string]string),
+		reserved:  make(map[string]bool),
+	}
 }
 
-// MoveToBackup moves the original file to the backup directory
-// Preserves the filename but flattens the path structure
-// Thread-safe: uses mutex to prevent TOCTOU race when finding unique paths
-func (m *Manager) MoveToBackup(originalPath string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// NameFor returns the backup file name for an original: its name with a
+// short hash of its directory, so same-named files from different series
+// ("01.cbz") don't collide
+func NameFor(originalPath string) string {
+	dir := filepath.Dir(originalPath)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	sum := sha256.Sum256([]byte(dir))
+	name := filepath.Base(originalPath)
+	ext := filepath.Ext(name)
+	return name[:len(name)-len(ext)] + "." + hex.EncodeToString(sum[:4]) + ext
+}
+
+// MoveToBackup moves the original file to the backup directory (or the trash)
+// and returns where it ended up.
+// Flattens the path structure; see NameFor for the name.
+// Thread-safe: the backup path is reserved before the move, so parallel
+// workers never pick the same name
+func (m *Manager) MoveToBackup(originalPath string) (string, error) {
+	if m.mode == ModeStore {
+		return m.moveToStore(originalPath)
+	}
+
+	if m.mode == ModeTrash {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		trashedPath, err := trash.Trash(originalPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to move %s to trash: %w", originalPath, err)
+		}
+		m.locations[originalPath] = trashedPath
+		return trashedPath, nil
+	}
 
 	// Ensure backup directory exists
 	if err := os.MkdirAll(m.backupDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup dir: %w", err)
+		return "", fmt.Errorf("failed to create backup dir: %w", err)
 	}
 
-	// Create backup path preserving filename
-	backupPath := filepath.Join(m.backupDir, filepath.Base(originalPath))
+	backupPath := m.Reserve(originalPath)
+	defer m.Release(backupPath)
 
-	// Handle duplicates by adding suffix (safe under lock)
-	if _, err := os.Stat(backupPath); err == nil {
-		backupPath = m.uniquePathLocked(backupPath)
+	// Move file
+	if err := fsutil.Rename(originalPath, backupPath); err != nil {
+		return "", fmt.Errorf("failed to move %s to backup: %w", originalPath, err)
 	}
 
-	// Move file
-	if err := os.Rename(originalPath, backupPath); err != nil {
-		return fmt.Errorf("failed to move %s to backup: %w", originalPath, err)
+	m.mu.Lock()
+	m.locations[originalPath] = backupPath
+	m.mu.Unlock()
+	return backupPath, nil
+}
+
+// Replace swaps replacement into originalPath in one step, leaving the
+// original at backupPath (from Reserve, which Replace releases). Only for
+// dir mode; on Windows the swap keeps the original's attributes and ACLs.
+func (m *Manager) Replace(originalPath, replacement, backupPath string) error {
+	defer m.Release(backupPath)
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup dir: %w", err)
+	}
+	if err := fsutil.Replace(originalPath, replacement, backupPath); err != nil {
+		return err
 	}
 
+	m.mu.Lock()
+	m.locations[originalPath] = backupPath
+	m.mu.Unlock()
 	return nil
 }
 
-// GetBackupPath returns the path where a file would be backed up
-func (m *Manager) GetBackupPath(originalPath string) string {
+// Reserve returns the path a file will be backed up to and holds it until
+// Release, so no other worker is handed the same path in the meantime
+func (m *Manager) Reserve(originalPath string) string {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	backupPath := filepath.Join(m.backupDir, filepath.Base(originalPath))
-	if _, err := os.Stat(backupPath); err == nil {
-		return m.uniquePathLocked(backupPath)
+	backupPath := filepath.Join(m.backupDir, NameFor(originalPath))
+	if !m.availableLocked(backupPath) {
+		backupPath = m.uniquePathLocked(backupPath)
 	}
+	m.reserved[backupPath] = true
 	return backupPath
 }
 
+// Release gives up a reservation. Once the backup is written its path is
+// taken anyway; if the move failed, the path is free again.
+func (m *Manager) Release(backupPath string) {
+	m.mu.Lock()
+	delete(m.reserved, backupPath)
+	m.mu.Unlock()
+}
+
 // RestoreFromBackup restores a file from backup (for error recovery)
 func (m *Manager) RestoreFromBackup(originalPath string) error {
-	backupPath := filepath.Join(m.backupDir, filepath.Base(originalPath))
+	m.mu.Lock()
+	backupPath, ok := m.locations[originalPath]
+	if ok {
+		delete(m.locations, originalPath)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		// Not moved by this manager: fall back to the un-suffixed backup name
+		backupPath = filepath.Join(m.backupDir, NameFor(originalPath))
+	}
+
+	switch m.mode {
+	case ModeTrash:
+		return trash.Restore(backupPath, originalPath)
+	case ModeStore:
+		if !ok {
+			return fmt.Errorf("no stored backup recorded for %s", originalPath)
+		}
+		return RestoreFromStore(backupPath, originalPath)
+	}
 
 	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
 		return fmt.Errorf("backup file not found: %s", backupPath)
 	}
 
-	return os.Rename(backupPath, originalPath)
+	return fsutil.Rename(backupPath, originalPath)
 }
 
 // uniquePathLocked generates a unique path by adding numeric suffix
@@ -77,13 +187,44 @@ func (m *Manager) uniquePathLocked(path string) string {
 
 	for i := 1; ; i++ {
 		newPath := fmt.Sprintf("%s_%d%s", base, i, ext)
-		if _, err := os.Stat(newPath); os.IsNotExist(err) {
+		if m.availableLocked(newPath) {
 			return newPath
 		}
 	}
 }
 
-// BackupDir returns the configured backup directory
+// availableLocked reports whether path is neither on disk nor reserved
+// Must be called with m.mu held
+func (m *Manager) availableLocked(path string) bool {
+	if m.reserved[path] {
+		return false
+	}
+	_, err := os.Stat(path)
+	return os.IsNotExist(err)
+}
+
+// Locations returns where each original moved this run is now (a file in
+// the backup directory, the store or the trash), by its path as given;
+// originals restored since are not included
+func (m *Manager) Locations() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	locations := make(map[string]string, len(m.locations))
+	for original, backupPath := range m.locations {
+		locations[original] = backupPath
+	}
+	return locations
+}
+
+// BackupDir returns the configured backup directory ("" in trash mode)
 func (m *Manager) BackupDir() string {
+	if m.mode == ModeTrash {
+		return ""
+	}
 	return m.backupDir
 }
+
+// Mode returns the configured backup mode
+func (m *Manager) Mode() Mode {
+	return m.mode
+}
@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"compress_comics/internal/fsutil"
+)
+
+// IndexFileName is the name index of the content-addressed store, inside the backup directory
+const IndexFileName = "index.jsonl"
+
+// IndexEntry maps an original file to its object in the content-addressed store
+type IndexEntry struct {
+	Time     time.Time `json:"time"`
+	Original string    `json:"original"` // Absolute path the file was backed up from
+	Hash     string    `json:"sha256"`
+	Size     int64     `json:"size"`
+	Object   string    `json:"object"`            // Path relative to the backup directory
+	Deduped  bool      `json:"deduped,omitempty"` // Object already existed; original was discarded
+}
+
+// hashFile returns the SHA-256 of a file's content
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// objectPath returns the store location for a hash: objects/ab/abcdef....cbz
+func objectPath(hash, ext string) string {
+	return filepath.Join("objects", hash[:2], hash+ext)
+}
+
+// moveToStore backs up originalPath into the content-addressed store. Identical
+// content already in the store is not stored twice: the original is just removed.
+func (m *Manager) moveToStore(originalPath string) (string, error) {
+	// Hash outside the lock; it is the slow part and touches only the original
+	hash, size, err := hashFile(originalPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", originalPath, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rel := objectPath(hash, filepath.Ext(originalPath))
+	object := filepath.Join(m.backupDir, rel)
+	if err := os.MkdirAll(filepath.Dir(object), 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup dir: %w", err)
+	}
+
+	deduped := false
+	if existing, err := os.Stat(object); err == nil && existing.Size() == size {
+		if err := os.Remove(originalPath); err != nil {
+			return "", fmt.Errorf("failed to remove %s (already in backup store): %w", originalPath, err)
+		}
+		deduped = true
+	} else if err := fsutil.Move(originalPath, object); err != nil {
+		return "", fmt.Errorf("failed to move %s to backup store: %w", originalPath, err)
+	}
+
+	absOriginal, _ := filepath.Abs(originalPath)
+	if err := m.appendIndexLocked(IndexEntry{
+		Time:     time.Now(),
+		Original: absOriginal,
+		Hash:     hash,
+		Size:     size,
+		Object:   filepath.ToSlash(rel),
+		Deduped:  deduped,
+	}); err != nil {
+		// Put the original back, since the caller keeps it when the backup
+		// fails; a deduplicated object belongs to earlier entries, so copy it
+		restore := fsutil.Move
+		if deduped {
+			restore = fsutil.Copy
+		}
+		if restoreErr := restore(object, originalPath); restoreErr != nil {
+			return "", fmt.Errorf("%w (the original is left at %s: %v)", err, object, restoreErr)
+		}
+		return "", err
+	}
+
+	m.locations[originalPath] = object
+	return object, nil
+}
+
+// appendIndexLocked records a stored original in the name index. Must be called with m.mu held
+func (m *Manager) appendIndexLocked(entry IndexEntry) error {
+	f, err := os.OpenFile(filepath.Join(m.backupDir, IndexFileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open backup index: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write backup index: %w", err)
+	}
+	return nil
+}
+
+// RestoreFromStore copies a stored object back to originalPath. The object stays
+// in the store because other index entries may share it.
+func RestoreFromStore(object, originalPath string) error {
+	return fsutil.Copy(object, originalPath)
+}
@@ -0,0 +1,43 @@
+package cbz
+
+import (
+	"sort"
+	"strings"
+)
+
+// MinChapterPages is the fewest pages a folder needs to count as a chapter
+// or volume of its own; smaller folders (covers, extras) belong to the
+// chapter after them
+const MinChapterPages = 4
+
+// ChapterOf returns the top-level folder of an entry ("" at the root)
+func ChapterOf(name string) string {
+	if i := strings.Index(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// Chapters lists the top-level folders of a merged multi-volume archive in
+// page order, given its page names. Archives with fewer than two folders of
+// at least MinChapterPages pages are not merged and get nil.
+func Chapters(pages []string) []string {
+	counts := make(map[string]int)
+	for _, page := range pages {
+		if chapter := ChapterOf(page); chapter != "" {
+			counts[chapter]++
+		}
+	}
+
+	var chapters []string
+	for chapter, n := range counts {
+		if n >= MinChapterPages {
+			chapters = append(chapters, chapter)
+		}
+	}
+	if len(chapters) < 2 {
+		return nil
+	}
+	sort.Slice(chapters, func(i, j int) bool { return NaturalLess(chapters[i], chapters[j]) })
+	return chapters
+}
@@ -0,0 +1,85 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ComicInfoFileName is the metadata entry comic readers look for at the archive root
+const ComicInfoFileName = "ComicInfo.xml"
+
+// ReadComicInfo returns the top-level elements of the archive's
+// ComicInfo.xml as name -> text (e.g. "Genre" -> "Action, Manga"), or nil
+// if it has none
+func ReadComicInfo(cbzPath string) (map[string]string, error) {
+	zr, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CBZ %s: %w", cbzPath, err)
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		if !strings.EqualFold(file.Name, ComicInfoFileName) {
+			continue
+		}
+		if IsEncrypted(file) {
+			return nil, nil // Unreadable; the analyzer skips the archive
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in %s: %w", file.Name, cbzPath, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in %s: %w", file.Name, cbzPath, err)
+		}
+		fields, err := parseComicInfo(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s in %s: %w", file.Name, cbzPath, err)
+		}
+		return fields, nil
+	}
+	return nil, nil
+}
+
+// parseComicInfo collects the text of the root element's children
+func parseComicInfo(data []byte) (map[string]string, error) {
+	fields := make(map[string]string)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		depth int
+		name  string
+		text  strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return fields, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				name = t.Name.Local
+				text.Reset()
+			}
+		case xml.CharData:
+			if depth == 2 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				fields[name] = strings.TrimSpace(text.String())
+			}
+			depth--
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cbz
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/flate"
+)
+
+// Zip compression levels (deflate): 0 stores blocks uncompressed, 9 is smallest
+const (
+	MinZipLevel     = 0
+	MaxZipLevel     = 9
+	DefaultZipLevel = 6
+)
+
+// ValidateZipLevel rejects levels deflate does not support
+func ValidateZipLevel(level int) error {
+	if level < MinZipLevel || level > MaxZipLevel {
+		return fmt.Errorf("zip level must be between %d and %d", MinZipLevel, MaxZipLevel)
+	}
+	return nil
+}
+
+// Zip methods of written archives
+const (
+	ZipMethodDeflate = "deflate" // Compress entries at the zip level
+	ZipMethodStore   = "store"   // Write entries uncompressed, which some e-readers page through faster
+)
+
+// ValidateZipMethod rejects methods the writer does not support
+func ValidateZipMethod(method string) error {
+	if method != ZipMethodDeflate && method != ZipMethodStore {
+		return fmt.Errorf("unknown zip method %q (must be %s or %s)", method, ZipMethodDeflate, ZipMethodStore)
+	}
+	return nil
+}
+
+// deflatePools reuse compressors per level; each one allocates about a megabyte
+var deflatePools [MaxZipLevel + 1]sync.Pool
+
+// registerDeflate makes zw compress entries with klauspost/compress's
+// deflate at level, which is considerably faster than compress/flate
+func registerDeflate(zw *zip.Writer, level int) {
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		pool := &deflatePools[level]
+		if fw, ok := pool.Get().(*flate.Writer); ok {
+			fw.Reset(w)
+			return &pooledDeflate{Writer: fw, pool: pool}, nil
+		}
+		fw, err := flate.NewWriter(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return &pooledDeflate{Writer: fw, pool: pool}, nil
+	})
+}
+
+// pooledDeflate returns its compressor to the pool once the entry is closed
+type pooledDeflate struct {
+	*flate.Writer
+	pool *sync.Pool
+}
+
+func (p *pooledDeflate) Close() error {
+	err := p.Writer.Close()
+	p.pool.Put(p.Writer)
+	return err
+}
@@ -0,0 +1,45 @@
+package cbz
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+
+	"compress_comics/internal/fileclass"
+)
+
+// ErrEncrypted marks an entry the zip reader cannot decrypt (store-bought
+// archives with DRM, or password-protected zips)
+var ErrEncrypted = errors.New("entry is encrypted (DRM or password-protected)")
+
+// methodAES is the compression method WinZip AES encryption records
+const methodAES = 99
+
+// IsEncrypted reports whether an entry is encrypted, with traditional
+// PKWARE encryption (general purpose flag bit 0) or WinZip AES
+func IsEncrypted(file *zip.File) bool {
+	return file.Flags&0x1 != 0 || file.Method == methodAES
+}
+
+// EncryptedEntries counts the encrypted files of an archive, leaving out
+// directories and hidden entries as Extract does
+func EncryptedEntries(zr *zip.Reader) int {
+	n := 0
+	for _, file := range zr.File {
+		if !file.FileInfo().IsDir() && !fileclass.IsJunk(file.Name) && IsEncrypted(file) {
+			n++
+		}
+	}
+	return n
+}
+
+// CountEncrypted opens an archive and counts its encrypted entries
+func CountEncrypted(cbzPath string) (int, error) {
+	zipReader, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open CBZ %s: %w", cbzPath, err)
+	}
+	defer zipReader.Close()
+
+	return EncryptedEntries(&zipReader.Reader), nil
+}
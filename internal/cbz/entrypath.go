@@ -0,0 +1,46 @@
+package cbz
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SafeEntryName returns name as a relative slash path that stays inside
+// the archive (zip-slip): backslashes become slashes, a drive letter and
+// leading slashes are dropped, and "." and ".." elements are resolved
+// without climbing above the root. ok is false when name had to change.
+func SafeEntryName(name string) (safe string, ok bool) {
+	safe = strings.ReplaceAll(name, `\`, "/")
+	if len(safe) >= 2 && safe[1] == ':' && isLetter(safe[0]) {
+		safe = safe[2:]
+	}
+	safe = strings.TrimPrefix(path.Clean("/"+safe), "/")
+	if safe == "" {
+		safe = "unnamed"
+	}
+	return safe, safe == name
+}
+
+// isLetter reports whether c is an ASCII letter
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// safeName is SafeEntryName for an entry being extracted. A normalized name
+// already taken by another entry gets a number ("page1 (2).jpg"), and every
+// change is recorded in unsafe as "original -> normalized".
+func safeName(entry string, taken map[string]bool, unsafe *[]string) string {
+	name, ok := SafeEntryName(entry)
+	if ok {
+		return name
+	}
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 2; taken[name]; n++ {
+		name = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+	}
+	taken[name] = true
+	*unsafe = append(*unsafe, entry+" -> "+name)
+	return name
+}
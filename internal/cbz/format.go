@@ -0,0 +1,114 @@
+package cbz
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"compress_comics/internal/fileclass"
+)
+
+// Policy says what happens to pages of one source format
+type Policy string
+
+const (
+	PolicyConvert Policy = "convert" // Resize and re-encode as JPEG (default)
+	PolicyKeep    Policy = "keep"    // Pass through untouched
+)
+
+// FormatPolicy maps format names (see fileclass.FormatOf) to a policy; unlisted formats are converted
+type FormatPolicy map[string]Policy
+
+// Keeps reports whether pages named like name pass through untouched
+func (fp FormatPolicy) Keeps(name string) bool {
+	return fp[fileclass.FormatOf(name)] == PolicyKeep
+}
+
+// Merge returns a copy of fp with the entries of other taking precedence
+func (fp FormatPolicy) Merge(other FormatPolicy) FormatPolicy {
+	if len(other) == 0 {
+		return fp
+	}
+	merged := make(FormatPolicy, len(fp)+len(other))
+	for format, policy := range fp {
+		merged[format] = policy
+	}
+	for format, policy := range other {
+		merged[format] = policy
+	}
+	return merged
+}
+
+// String lists the non-default policies, e.g. "gif=keep webp=keep"
+func (fp FormatPolicy) String() string {
+	var kept []string
+	for format, policy := range fp {
+		if policy != PolicyConvert {
+			kept = append(kept, format+"="+string(policy))
+		}
+	}
+	if len(kept) == 0 {
+		return "convert all"
+	}
+	sort.Strings(kept)
+	return strings.Join(kept, " ")
+}
+
+// ExtensionList is an allow-list of page extensions (".png"): pages with
+// other extensions pass through untouched. Empty allows every page.
+type ExtensionList []string
+
+// Allows reports whether pages named like name may be processed
+func (l ExtensionList) Allows(name string) bool {
+	if len(l) == 0 {
+		return true
+	}
+	return slices.Contains(l.normalized(), strings.ToLower(path.Ext(name)))
+}
+
+// String lists the extensions, or "all" for an empty list
+func (l ExtensionList) String() string {
+	if len(l) == 0 {
+		return "all"
+	}
+	return strings.Join(l.normalized(), " ")
+}
+
+// Validate rejects extensions that aren't pages (see fileclass.FormatOf)
+func (l ExtensionList) Validate() error {
+	for _, ext := range l.normalized() {
+		if !fileclass.IsImage("page" + ext) {
+			return fmt.Errorf("process_extensions: %q is not a page extension (known formats: %s)", ext, strings.Join(fileclass.Names(), ", "))
+		}
+	}
+	return nil
+}
+
+// normalized lowercases the extensions and adds missing leading dots
+func (l ExtensionList) normalized() []string {
+	exts := make([]string, len(l))
+	for i, ext := range l {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts[i] = ext
+	}
+	return exts
+}
+
+// Validate rejects unknown formats and policies
+func (fp FormatPolicy) Validate() error {
+	known := fileclass.Names()
+	for format, policy := range fp {
+		if !slices.Contains(known, format) {
+			return fmt.Errorf("unknown format %q in format_policy (known: %s)", format, strings.Join(known, ", "))
+		}
+		if policy != PolicyConvert && policy != PolicyKeep {
+			return fmt.Errorf("invalid policy %q for %s (must be convert or keep)", policy, format)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package cbz
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// markerPrefix starts the zip comment written on every archive this tool produces
+const markerPrefix = "cbz-compress v1 sha256:"
+
+// Marker describes the processing marker found in an archive's zip comment
+type Marker struct {
+	Present bool   // Archive carries a cbz-compress marker
+	Valid   bool   // Marker hash matches the current entries (archive unchanged since we wrote it)
+	Hash    string // Hash recorded in the marker
+}
+
+// contentHasher accumulates a short hash over entry names, CRCs and sizes.
+// These are all available from the central directory, so checking a marker
+// later never requires decompressing any entry.
+type contentHasher struct {
+	h hash.Hash
+}
+
+func newContentHasher() *contentHasher {
+	return &contentHasher{h: sha256.New()}
+}
+
+func (c *contentHasher) add(name string, crc uint32, size uint64) {
+	fmt.Fprintf(c.h, "%s\x00%08x\x00%d\n", name, crc, size)
+}
+
+// sum returns the first 16 hex characters of the hash
+func (c *contentHasher) sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))[:16]
+}
+
+// markerComment formats the zip comment for a content hash
+func markerComment(hash string) string {
+	return markerPrefix + hash
+}
+
+// MarkerOf parses the marker of an already opened archive and verifies it against the entries
+func MarkerOf(zr *zip.Reader) Marker {
+	if !strings.HasPrefix(zr.Comment, markerPrefix) {
+		return Marker{}
+	}
+
+	recorded := strings.TrimSpace(strings.TrimPrefix(zr.Comment, markerPrefix))
+	hasher := newContentHasher()
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		hasher.add(file.Name, file.CRC32, file.UncompressedSize64)
+	}
+
+	return Marker{
+		Present: true,
+		Valid:   hasher.sum() == recorded,
+		Hash:    recorded,
+	}
+}
+
+// ReadMarker opens an archive and returns its processing marker
+func ReadMarker(cbzPath string) (Marker, error) {
+	zipReader, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		return Marker{}, fmt.Errorf("failed to open CBZ %s: %w", cbzPath, err)
+	}
+	defer zipReader.Close()
+
+	return MarkerOf(&zipReader.Reader), nil
+}
@@ -0,0 +1,89 @@
+package cbz
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageRange selects a 1-based, inclusive range of pages in natural sort order.
+// A zero Last means "through the final page"; the zero value selects every page.
+type PageRange struct {
+	First int
+	Last  int
+}
+
+// ParsePageRange parses "N", "N-M", "N-" or "-M" into a PageRange
+func ParsePageRange(s string) (PageRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return PageRange{}, nil
+	}
+
+	var r PageRange
+	first, last, isRange := strings.Cut(s, "-")
+
+	var err error
+	if first != "" {
+		if r.First, err = strconv.Atoi(strings.TrimSpace(first)); err != nil {
+			return PageRange{}, fmt.Errorf("invalid page range %q: %w", s, err)
+		}
+	} else {
+		r.First = 1
+	}
+
+	switch {
+	case !isRange:
+		r.Last = r.First
+	case last != "":
+		if r.Last, err = strconv.Atoi(strings.TrimSpace(last)); err != nil {
+			return PageRange{}, fmt.Errorf("invalid page range %q: %w", s, err)
+		}
+	}
+
+	if r.First < 1 {
+		return PageRange{}, fmt.Errorf("invalid page range %q: pages start at 1", s)
+	}
+	if r.Last != 0 && r.Last < r.First {
+		return PageRange{}, fmt.Errorf("invalid page range %q: end before start", s)
+	}
+
+	return r, nil
+}
+
+// IsSet reports whether the range restricts pages at all
+func (r PageRange) IsSet() bool {
+	return r.First > 1 || r.Last > 0
+}
+
+// String formats the range the same way ParsePageRange accepts it
+func (r PageRange) String() string {
+	if !r.IsSet() {
+		return "all"
+	}
+	if r.Last == 0 {
+		return fmt.Sprintf("%d-", r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// SelectPages keeps only the images inside the range. Images must already be in
+// page order (Extract sorts them). Non-image files are left untouched.
+func (c *Contents) SelectPages(r PageRange) error {
+	if !r.IsSet() {
+		return nil
+	}
+
+	total := len(c.Images)
+	if r.First > total {
+		return fmt.Errorf("page range %s is outside archive with %d pages", r, total)
+	}
+
+	last := r.Last
+	if last == 0 || last > total {
+		last = total
+	}
+
+	c.Images = c.Images[r.First-1 : last]
+	return nil
+}
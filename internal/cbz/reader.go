@@ -2,17 +2,20 @@ package cbz
 
 import (
 	"archive/zip"
+	"errors"
 	"fmt"
 	"io"
-	"path/filepath"
+	"path"
 	"sort"
 	"strings"
 	"time"
+
+	"compress_comics/internal/fileclass"
 )
 
 // ImageEntry represents an image file within a CBZ
 type ImageEntry struct {
-	Path         string    // Full path within archive (e.g., "chapter1/page01.jpg")
+	Path         string    // Full path within archive (e.g., "chapter1/page01.jpg"), with the extension of its format if misnamed
 	OriginalSize int64     // Original file size in bytes
 	Data         []byte    // Raw image data
 	ModTime      time.Time // Preserve modification time
@@ -27,21 +30,38 @@ type OtherEntry struct {
 
 // Contents holds all extracted content from a CBZ file
 type Contents struct {
-	SourcePath string
-	Images     []ImageEntry
-	OtherFiles []OtherEntry
+	SourcePath  string
+	Images      []ImageEntry
+	OtherFiles  []OtherEntry
+	UnsafePaths []string // Absolute or traversing entry paths, as "original -> normalized" (see SafeEntryName)
+}
+
+// CorruptEntry is an entry whose data failed its CRC or did not decompress
+type CorruptEntry struct {
+	Path string
+	Data []byte // What could be read: all of it when only the CRC failed
+	Err  error
 }
 
-// SupportedImageExtensions for filtering
-var SupportedImageExtensions = map[string]bool{
-	".jpg":  true,
-	".jpeg": true,
-	".png":  true,
-	".gif":  true,
-	".webp": true,
-	".bmp":  true,
+// CorruptError is returned by Extract when entries are corrupt, naming
+// every one. Contents holds the intact entries, for salvaging.
+type CorruptError struct {
+	Contents *Contents
+	Entries  []CorruptEntry
 }
 
+func (e *CorruptError) Error() string {
+	names := make([]string, len(e.Entries))
+	for i, entry := range e.Entries {
+		names[i] = fmt.Sprintf("%s (%v)", entry.Path, entry.Err)
+	}
+	return fmt.Sprintf("%d corrupt entries: %s", len(e.Entries), strings.Join(names, ", "))
+}
+
+// OverrideFileName holds per-archive settings, inside an archive or next to it
+// (see internal/override)
+const OverrideFileName = fileclass.OverrideFileName
+
 // Reader handles CBZ extraction
 type Reader struct{}
 
@@ -50,7 +70,8 @@ func NewReader() *Reader {
 	return &Reader{}
 }
 
-// Extract opens a CBZ and returns all contents
+// Extract opens a CBZ and returns all contents. Entries that fail their
+// CRC or don't decompress are all read before it returns a *CorruptError.
 func (r *Reader) Extract(cbzPath string) (*Contents, error) {
 	zipReader, err := zip.OpenReader(cbzPath)
 	if err != nil {
@@ -64,31 +85,45 @@ func (r *Reader) Extract(cbzPath string) (*Contents, error) {
 		OtherFiles: make([]OtherEntry, 0),
 	}
 
+	var corrupt []CorruptEntry
+	taken := make(map[string]bool, len(zipReader.File))
+	for _, file := range zipReader.File {
+		taken[file.Name] = true
+	}
+
 	for _, file := range zipReader.File {
 		// Skip directories
 		if file.FileInfo().IsDir() {
 			continue
 		}
 
+		// Entries are only ever written back inside the archive
+		name := safeName(file.Name, taken, &contents.UnsafePaths)
+
 		// Skip hidden files (macOS resource forks, etc.)
-		baseName := filepath.Base(file.Name)
-		if strings.HasPrefix(baseName, ".") || strings.HasPrefix(baseName, "__MACOSX") {
+		if fileclass.IsJunk(name) {
 			continue
 		}
-		if strings.Contains(file.Name, "__MACOSX") {
-			continue
+
+		// Encrypted entries only fail once read, with an unhelpful checksum error
+		if IsEncrypted(file) {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, ErrEncrypted)
 		}
 
 		// Read file data
 		data, err := r.readFileFromZip(file)
-		if err != nil {
+		if errors.Is(err, zip.ErrAlgorithm) {
 			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
 		}
+		if err != nil {
+			corrupt = append(corrupt, CorruptEntry{Path: name, Data: data, Err: err})
+			continue
+		}
 
-		ext := strings.ToLower(filepath.Ext(file.Name))
-		if SupportedImageExtensions[ext] {
+		// Pages are told by content; misnamed ones get their format's extension
+		if page := pageName(name, data, taken); page != "" {
 			contents.Images = append(contents.Images, ImageEntry{
-				Path:         file.Name,
+				Path:         page,
 				OriginalSize: int64(len(data)),
 				Data:         data,
 				ModTime:      file.Modified,
@@ -96,7 +131,7 @@ func (r *Reader) Extract(cbzPath string) (*Contents, error) {
 		} else {
 			// Preserve non-image files (e.g., ComicInfo.xml)
 			contents.OtherFiles = append(contents.OtherFiles, OtherEntry{
-				Path:    file.Name,
+				Path:    name,
 				Data:    data,
 				ModTime: file.Modified,
 			})
@@ -105,12 +140,69 @@ func (r *Reader) Extract(cbzPath string) (*Contents, error) {
 
 	// Sort images by path for consistent page order
 	sort.Slice(contents.Images, func(i, j int) bool {
-		return naturalLess(contents.Images[i].Path, contents.Images[j].Path)
+		return NaturalLess(contents.Images[i].Path, contents.Images[j].Path)
 	})
 
+	if len(corrupt) > 0 {
+		return nil, &CorruptError{Contents: contents, Entries: corrupt}
+	}
 	return contents, nil
 }
 
+// pageName is fileclass.PageName, except that a page whose corrected name
+// is taken by another entry keeps its name with the extension added
+// ("page.png.jpg")
+func pageName(entry string, data []byte, taken map[string]bool) string {
+	name := fileclass.PageName(entry, data)
+	if name == "" || name == entry {
+		return name
+	}
+	if taken[name] {
+		name = entry + path.Ext(name)
+	}
+	taken[name] = true
+	return name
+}
+
+// ReadFirstImage returns the first page (in natural sort order) without extracting the rest
+func (r *Reader) ReadFirstImage(cbzPath string) (*ImageEntry, error) {
+	zipReader, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CBZ %s: %w", cbzPath, err)
+	}
+	defer zipReader.Close()
+
+	var first *zip.File
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() || fileclass.IsJunk(file.Name) {
+			continue
+		}
+		if !fileclass.IsImage(file.Name) {
+			continue
+		}
+		if first == nil || NaturalLess(file.Name, first.Name) {
+			first = file
+		}
+	}
+
+	if first == nil {
+		return nil, fmt.Errorf("no images in %s", cbzPath)
+	}
+
+	data, err := r.readFileFromZip(first)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", first.Name, err)
+	}
+
+	name, _ := SafeEntryName(first.Name)
+	return &ImageEntry{
+		Path:         name,
+		OriginalSize: int64(len(data)),
+		Data:         data,
+		ModTime:      first.Modified,
+	}, nil
+}
+
 func (r *Reader) readFileFromZip(file *zip.File) ([]byte, error) {
 	rc, err := file.Open()
 	if err != nil {
@@ -120,9 +212,20 @@ func (r *Reader) readFileFromZip(file *zip.File) ([]byte, error) {
 	return io.ReadAll(rc)
 }
 
-// naturalLess compares strings with natural number ordering
-// e.g., "page2" < "page10" (unlike lexicographic where "page10" < "page2")
-func naturalLess(a, b string) bool {
+// NaturalLess compares strings with natural number ordering
+// e.g., "page2" < "page10" (unlike lexicographic where "page10" < "page2").
+// Letters compare without case ("PAGE02" < "page03"); names equal but for
+// case fall back to byte order, so the order is still total.
+func NaturalLess(a, b string) bool {
+	if less, ok := naturalCompare(a, b); ok {
+		return less
+	}
+	return a < b
+}
+
+// naturalCompare is NaturalLess ignoring case; ok is false when a and b
+// only differ in case (or not at all)
+func naturalCompare(a, b string) (less, ok bool) {
 	ai, bi := 0, 0
 	for ai < len(a) && bi < len(b) {
 		// Check if both are at a digit
@@ -131,20 +234,31 @@ func naturalLess(a, b string) bool {
 			numA, endA := extractNumber(a, ai)
 			numB, endB := extractNumber(b, bi)
 			if numA != numB {
-				return numA < numB
+				return numA < numB, true
 			}
 			ai, bi = endA, endB
 		} else {
 			// Compare characters
-			ca, cb := a[ai], b[bi]
+			ca, cb := toLower(a[ai]), toLower(b[bi])
 			if ca != cb {
-				return ca < cb
+				return ca < cb, true
 			}
 			ai++
 			bi++
 		}
 	}
-	return len(a) < len(b)
+	if len(a) != len(b) {
+		return len(a) < len(b), true
+	}
+	return false, false
+}
+
+// toLower lowercases an ASCII letter
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
 }
 
 func isDigit(c byte) bool {
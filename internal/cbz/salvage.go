@@ -0,0 +1,297 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"compress_comics/internal/fileclass"
+)
+
+// Zip record signatures
+var (
+	localHeaderSig    = []byte("PK\x03\x04")
+	dataDescriptorSig = []byte("PK\x07\x08")
+)
+
+// localHeaderLen is the fixed part of a local file header
+const localHeaderLen = 30
+
+// SalvageResult lists what Salvage got out of a damaged archive
+type SalvageResult struct {
+	Contents  *Contents
+	Lost      []string // Entries found but unreadable, with the reason
+	Scanned   bool     // The central directory was unusable; entries were found by scanning local headers
+	Recovered int      // Entries read only by the scan (missing or broken in the central directory)
+}
+
+// Salvage reads every intact entry of a possibly corrupt or truncated
+// archive. Entries are taken from the central directory where it can be
+// read, and from a scan of the local file headers otherwise, so archives
+// with a damaged or missing central directory still give up their pages.
+// Only entries whose CRC checks out are returned. The whole archive is read
+// into memory.
+func Salvage(cbzPath string) (*SalvageResult, error) {
+	data, err := os.ReadFile(cbzPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", cbzPath, err)
+	}
+
+	result := &SalvageResult{Contents: &Contents{SourcePath: cbzPath}}
+	found := make(map[string][]byte)
+	lost := make(map[string]string)
+
+	// The central directory, when it parses, has the authoritative names
+	if zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		for _, file := range zr.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			content, err := readAll(file)
+			if err != nil {
+				lost[file.Name] = err.Error()
+				continue
+			}
+			found[file.Name] = content
+		}
+	} else {
+		result.Scanned = true
+	}
+
+	for _, entry := range scanLocalHeaders(data) {
+		if _, ok := found[entry.name]; ok || strings.HasSuffix(entry.name, "/") {
+			continue
+		}
+		if entry.err != nil {
+			if _, ok := lost[entry.name]; !ok {
+				lost[entry.name] = entry.err.Error()
+			}
+			continue
+		}
+		found[entry.name] = entry.data
+		delete(lost, entry.name)
+		if !result.Scanned {
+			result.Recovered++
+		}
+	}
+
+	taken := make(map[string]bool, len(found))
+	names := make([]string, 0, len(found))
+	for name := range found {
+		taken[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, entry := range names {
+		content := found[entry]
+		name := safeName(entry, taken, &result.Contents.UnsafePaths)
+		if fileclass.IsJunk(name) {
+			continue
+		}
+		if page := pageName(name, content, taken); page != "" {
+			result.Contents.Images = append(result.Contents.Images, ImageEntry{Path: page, OriginalSize: int64(len(content)), Data: content})
+		} else {
+			result.Contents.OtherFiles = append(result.Contents.OtherFiles, OtherEntry{Path: name, Data: content})
+		}
+	}
+	sort.Slice(result.Contents.Images, func(i, j int) bool {
+		return NaturalLess(result.Contents.Images[i].Path, result.Contents.Images[j].Path)
+	})
+	sort.Slice(result.Contents.OtherFiles, func(i, j int) bool {
+		return result.Contents.OtherFiles[i].Path < result.Contents.OtherFiles[j].Path
+	})
+
+	for name, reason := range lost {
+		result.Lost = append(result.Lost, fmt.Sprintf("%s (%s)", name, reason))
+	}
+	sort.Strings(result.Lost)
+
+	return result, nil
+}
+
+// readAll reads an entry to the end, which verifies its CRC
+func readAll(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// scannedEntry is an entry found by its local file header
+type scannedEntry struct {
+	name string
+	data []byte
+	err  error
+}
+
+// scanLocalHeaders walks the local file headers in data front to back,
+// resynchronizing on the next signature after a damaged entry
+func scanLocalHeaders(data []byte) []scannedEntry {
+	var entries []scannedEntry
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], localHeaderSig)
+		if i < 0 {
+			return entries
+		}
+		start := offset + i
+
+		entry, end := readLocalEntry(data, start)
+		if entry.name != "" {
+			entries = append(entries, entry)
+		}
+		if entry.err != nil {
+			end = start + len(localHeaderSig)
+		}
+		offset = end
+	}
+}
+
+// readLocalEntry decodes the entry whose local header starts at start,
+// returning it and the offset just past its data
+func readLocalEntry(data []byte, start int) (scannedEntry, int) {
+	if start+localHeaderLen > len(data) {
+		return scannedEntry{err: io.ErrUnexpectedEOF}, len(data)
+	}
+	header := data[start : start+localHeaderLen]
+	flags := binary.LittleEndian.Uint16(header[6:8])
+	method := binary.LittleEndian.Uint16(header[8:10])
+	crc := binary.LittleEndian.Uint32(header[14:18])
+	compressedSize := uint64(binary.LittleEndian.Uint32(header[18:22]))
+	nameLen := int(binary.LittleEndian.Uint16(header[26:28]))
+	extraLen := int(binary.LittleEndian.Uint16(header[28:30]))
+
+	bodyStart := start + localHeaderLen + nameLen + extraLen
+	if bodyStart > len(data) {
+		return scannedEntry{err: io.ErrUnexpectedEOF}, len(data)
+	}
+	entry := scannedEntry{name: string(data[start+localHeaderLen : start+localHeaderLen+nameLen])}
+	if !plausibleName(entry.name) {
+		// A signature inside compressed data, not a real header
+		return scannedEntry{err: errors.New("not a header")}, bodyStart
+	}
+
+	if flags&0x1 != 0 {
+		entry.err = errors.New("encrypted")
+		return entry, bodyStart
+	}
+
+	// Sizes come after the data when bit 3 is set, in a descriptor whose
+	// sizes take 8 bytes when the header has a zip64 extra field. Overflowing
+	// header sizes are in that field; sizes left unknown are found by reading
+	// the data itself.
+	zip64, zip64Sizes := localZip64(data[start+localHeaderLen+nameLen : bodyStart])
+	if compressedSize == 0xffffffff && len(zip64Sizes) >= 16 {
+		compressedSize = binary.LittleEndian.Uint64(zip64Sizes[8:16])
+	}
+	descriptor := flags&0x8 != 0
+	streamed := descriptor || compressedSize == 0xffffffff
+	var (
+		content []byte
+		end     int
+		err     error
+	)
+	switch method {
+	case zip.Store:
+		if streamed {
+			n := bytes.Index(data[bodyStart:], dataDescriptorSig)
+			if n < 0 {
+				n = bytes.Index(data[bodyStart:], localHeaderSig)
+			}
+			if n < 0 {
+				return entry.failed(io.ErrUnexpectedEOF), len(data)
+			}
+			end = bodyStart + n
+		} else {
+			end = bodyStart + int(compressedSize)
+			if end > len(data) {
+				return entry.failed(io.ErrUnexpectedEOF), len(data)
+			}
+		}
+		content = data[bodyStart:end]
+	case zip.Deflate:
+		// bytes.Reader is an io.ByteReader, so flate stops exactly at the end of the stream
+		r := bytes.NewReader(data[bodyStart:])
+		fr := flate.NewReader(r)
+		content, err = io.ReadAll(fr)
+		fr.Close()
+		if err != nil {
+			return entry.failed(err), len(data)
+		}
+		end = len(data) - r.Len()
+	default:
+		return entry.failed(fmt.Errorf("unsupported compression method %d", method)), bodyStart
+	}
+
+	if descriptor {
+		// Data descriptor: optional signature, then CRC (sizes follow)
+		d := data[end:]
+		if bytes.HasPrefix(d, dataDescriptorSig) {
+			d = d[len(dataDescriptorSig):]
+			end += len(dataDescriptorSig)
+		}
+		if len(d) < 4 {
+			return entry.failed(io.ErrUnexpectedEOF), len(data)
+		}
+		crc = binary.LittleEndian.Uint32(d[:4])
+		end += 12
+		if zip64 {
+			end += 8
+		}
+	}
+
+	if crc32.ChecksumIEEE(content) != crc {
+		return entry.failed(zip.ErrChecksum), end
+	}
+	entry.data = content
+	return entry, min(end, len(data))
+}
+
+// localZip64 finds the zip64 extra field among a local header's extra
+// fields, returning whether there is one and its data (uncompressed, then
+// compressed size)
+func localZip64(extra []byte) (bool, []byte) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		n := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+n > len(extra) {
+			break
+		}
+		if id == 0x0001 {
+			return true, extra[4 : 4+n]
+		}
+		extra = extra[4+n:]
+	}
+	return false, nil
+}
+
+// plausibleName rejects names no archiver writes, which mark a false
+// signature match
+func plausibleName(name string) bool {
+	if name == "" || !utf8.ValidString(name) {
+		return false
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// failed returns the entry with err set
+func (e scannedEntry) failed(err error) scannedEntry {
+	e.err = err
+	return e
+}
@@ -0,0 +1,47 @@
+package cbz
+
+import (
+	"archive/zip"
+	"fmt"
+	"hash/crc32"
+)
+
+// Source gives access to the compressed entries of the archive being
+// rewritten, so unchanged entries can be copied without recompressing them
+type Source struct {
+	zr    *zip.ReadCloser
+	files map[string]*zip.File
+}
+
+// OpenSource opens cbzPath for raw entry copies
+func OpenSource(cbzPath string) (*Source, error) {
+	zr, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CBZ %s: %w", cbzPath, err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	return &Source{zr: zr, files: files}, nil
+}
+
+// Entry returns the WriteEntry for data at path: a raw copy of the source
+// entry when it holds exactly data (same name, size and CRC), otherwise an
+// entry that compresses data afresh
+func (s *Source) Entry(path string, data []byte) WriteEntry {
+	return s.EntryAs(path, path, data)
+}
+
+// EntryAs is Entry for data from the source entry src, written under path
+func (s *Source) EntryAs(src, path string, data []byte) WriteEntry {
+	if f, ok := s.files[src]; ok && f.UncompressedSize64 == uint64(len(data)) && f.CRC32 == crc32.ChecksumIEEE(data) {
+		return WriteEntry{Path: path, Raw: f, Size: int64(len(data))}
+	}
+	return BytesEntry(path, data)
+}
+
+// Close releases the source archive
+func (s *Source) Close() error {
+	return s.zr.Close()
+}
@@ -0,0 +1,240 @@
+package cbz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Zip records a split set is stitched together from
+var (
+	splitSig         = []byte("PK\x07\x08") // Opens the first part of a split set
+	singleSegmentSig = []byte("PK00")       // Opens a set that was split into one part
+	centralDirSig    = []byte("PK\x01\x02")
+	endOfDirSig      = []byte("PK\x05\x06")
+)
+
+// Fixed lengths of the central directory records
+const (
+	centralHeaderLen = 46
+	endOfDirLen      = 22
+)
+
+// ErrZip64Split is returned for split sets whose sizes or offsets need
+// zip64 records, which JoinSplit does not rewrite
+var ErrZip64Split = errors.New("zip64 split sets are not supported")
+
+// SplitParts returns the other parts of the split set (name.z01, name.z02,
+// ...) that the archive at headPath ends, in order, or nil if it is a whole
+// archive. Stray parts next to a whole archive are ignored.
+func SplitParts(headPath string) ([]string, error) {
+	stem := strings.TrimSuffix(headPath, filepath.Ext(headPath))
+	if partPath(stem, 1) == "" {
+		return nil, nil
+	}
+	end, err := readEndOfDir(headPath)
+	if err != nil {
+		return nil, err
+	}
+	disks := int(binary.LittleEndian.Uint16(end[4:6]))
+	if disks == 0 {
+		return nil, nil
+	}
+
+	parts := make([]string, disks)
+	for i := range parts {
+		if parts[i] = partPath(stem, i+1); parts[i] == "" {
+			return nil, fmt.Errorf("split set is missing part %d of %d (%s.z%02d)", i+1, disks+1, stem, i+1)
+		}
+	}
+	return parts, nil
+}
+
+// partPath is the path of part n of the set named stem, in either case, or
+// "" if it doesn't exist
+func partPath(stem string, n int) string {
+	for _, ext := range []string{fmt.Sprintf(".z%02d", n), fmt.Sprintf(".Z%02d", n)} {
+		if info, err := os.Stat(stem + ext); err == nil && info.Mode().IsRegular() {
+			return stem + ext
+		}
+	}
+	return ""
+}
+
+// JoinSplit writes the split set made of parts and headPath (see
+// SplitParts) to out as one ordinary archive: the entries are copied
+// through unchanged and the central directory is rewritten with offsets
+// into the joined file.
+func JoinSplit(parts []string, headPath, out string) error {
+	files := make([]*os.File, 0, len(parts)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	base := make([]int64, 0, len(parts)+1) // Offset of each part in the joined stream
+	var total int64
+	for _, path := range slices.Concat(parts, []string{headPath}) {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open split part: %w", err)
+		}
+		files = append(files, f)
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		base = append(base, total)
+		total += info.Size()
+	}
+	stream := &partsReader{files: files, base: base, size: total}
+
+	end, err := readEndOfDir(headPath)
+	if err != nil {
+		return err
+	}
+	dirDisk := int(binary.LittleEndian.Uint16(end[6:8]))
+	entries := int(binary.LittleEndian.Uint16(end[10:12]))
+	dirSize := int64(binary.LittleEndian.Uint32(end[12:16]))
+	dirOffset := int64(binary.LittleEndian.Uint32(end[16:20]))
+	if entries == 0xffff || dirSize == 0xffffffff || dirOffset == 0xffffffff {
+		return ErrZip64Split
+	}
+	if dirDisk >= len(base) {
+		return fmt.Errorf("central directory is on part %d of %d", dirDisk+1, len(base))
+	}
+	dirStart := base[dirDisk] + dirOffset
+
+	// The first part opens with a marker that isn't part of any entry
+	var skip int64
+	lead := make([]byte, 4)
+	if _, err := stream.ReadAt(lead, 0); err == nil && (bytes.Equal(lead, splitSig) || bytes.Equal(lead, singleSegmentSig)) {
+		skip = int64(len(lead))
+	}
+
+	dir := make([]byte, dirSize)
+	if _, err := stream.ReadAt(dir, dirStart); err != nil {
+		return fmt.Errorf("failed to read central directory: %w", err)
+	}
+	for pos, n := 0, 0; n < entries; n++ {
+		if pos+centralHeaderLen > len(dir) || !bytes.Equal(dir[pos:pos+4], centralDirSig) {
+			return fmt.Errorf("central directory is damaged at entry %d", n+1)
+		}
+		record := dir[pos:]
+		disk := int(binary.LittleEndian.Uint16(record[34:36]))
+		offset := int64(binary.LittleEndian.Uint32(record[42:46]))
+		if disk == 0xffff || offset == 0xffffffff {
+			return ErrZip64Split
+		}
+		if disk >= len(base) {
+			return fmt.Errorf("entry %d is on part %d of %d", n+1, disk+1, len(base))
+		}
+		joined := base[disk] + offset - skip
+		if joined > 0xffffffff {
+			return ErrZip64Split
+		}
+		binary.LittleEndian.PutUint16(record[34:36], 0)
+		binary.LittleEndian.PutUint32(record[42:46], uint32(joined))
+		pos += centralHeaderLen +
+			int(binary.LittleEndian.Uint16(record[28:30])) +
+			int(binary.LittleEndian.Uint16(record[30:32])) +
+			int(binary.LittleEndian.Uint16(record[32:34]))
+	}
+
+	newDirOffset := dirStart - skip
+	if newDirOffset > 0xffffffff {
+		return ErrZip64Split
+	}
+	binary.LittleEndian.PutUint16(end[4:6], 0)
+	binary.LittleEndian.PutUint16(end[6:8], 0)
+	binary.LittleEndian.PutUint16(end[8:10], uint16(entries))
+	binary.LittleEndian.PutUint32(end[16:20], uint32(newDirOffset))
+
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("failed to create joined archive: %w", err)
+	}
+	_, err = io.Copy(f, io.NewSectionReader(stream, skip, dirStart-skip))
+	if err == nil {
+		_, err = f.Write(dir)
+	}
+	if err == nil {
+		_, err = f.Write(end)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		return fmt.Errorf("failed to write joined archive: %w", err)
+	}
+	return nil
+}
+
+// readEndOfDir returns the end of central directory record of the archive
+// at path, comment included
+func readEndOfDir(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// The record is at the end, followed by a comment of up to 64 KB
+	size := info.Size()
+	n := min(size, endOfDirLen+0xffff)
+	tail := make([]byte, n)
+	if _, err := f.ReadAt(tail, size-n); err != nil {
+		return nil, err
+	}
+	for i := len(tail) - endOfDirLen; i >= 0; i-- {
+		if bytes.Equal(tail[i:i+4], endOfDirSig) {
+			commentLen := int(binary.LittleEndian.Uint16(tail[i+20 : i+22]))
+			if i+endOfDirLen+commentLen <= len(tail) {
+				return tail[i : i+endOfDirLen+commentLen], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: no end of central directory record", path)
+}
+
+// partsReader reads the parts of a split set as one stream
+type partsReader struct {
+	files []*os.File
+	base  []int64
+	size  int64
+}
+
+// ReadAt reads across part boundaries
+func (r *partsReader) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for read < len(p) {
+		pos := off + int64(read)
+		if pos >= r.size {
+			return read, io.EOF
+		}
+		i := len(r.base) - 1
+		for i > 0 && r.base[i] > pos {
+			i--
+		}
+		n, err := r.files[i].ReadAt(p[read:], pos-r.base[i])
+		read += n
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+		if n == 0 {
+			return read, io.ErrUnexpectedEOF
+		}
+	}
+	return read, nil
+}
@@ -0,0 +1,312 @@
+package cbz
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+)
+
+// Zip64 records CheckStrict follows when the classic fields overflow
+var (
+	zip64EndSig     = []byte("PK\x06\x06")
+	zip64LocatorSig = []byte("PK\x06\x07")
+)
+
+// Lengths of the fixed parts of the records CheckStrict reads
+const (
+	zip64EndLen     = 56
+	zip64LocatorLen = 20
+)
+
+// strictEntry is an entry as the central directory describes it
+type strictEntry struct {
+	name           []byte
+	flags          uint16
+	method         uint16
+	crc            uint32
+	compressedSize uint64
+	size           uint64
+	offset         uint64
+	zip64          bool // Sizes or offset came from the zip64 extra field
+}
+
+// CheckStrict reads the archive at path with a parser stricter than
+// archive/zip, which tolerates inconsistencies some reading apps reject:
+// the central directory must end exactly where the end record starts, each
+// entry's local header must agree with its central directory record (name,
+// method, flags, and CRC and sizes or a matching data descriptor), entries
+// must not overlap, names must be unique, and every entry must decompress
+// to its recorded size and CRC.
+func CheckStrict(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	end, err := readEndOfDir(path)
+	if err != nil {
+		return err
+	}
+	endPos := size - int64(len(end))
+	sig := make([]byte, 4)
+	if _, err := f.ReadAt(sig, endPos); err != nil || !bytes.Equal(sig, endOfDirSig) {
+		return errors.New("data after the end of central directory record")
+	}
+	if binary.LittleEndian.Uint16(end[4:6]) != 0 || binary.LittleEndian.Uint16(end[6:8]) != 0 {
+		return errors.New("end of central directory names another disk")
+	}
+	onDisk := uint64(binary.LittleEndian.Uint16(end[8:10]))
+	entries := uint64(binary.LittleEndian.Uint16(end[10:12]))
+	dirSize := uint64(binary.LittleEndian.Uint32(end[12:16]))
+	dirOffset := uint64(binary.LittleEndian.Uint32(end[16:20]))
+	dirEnd := uint64(endPos)
+
+	if entries == 0xffff || dirSize == 0xffffffff || dirOffset == 0xffffffff {
+		locator := make([]byte, zip64LocatorLen)
+		if _, err := f.ReadAt(locator, endPos-zip64LocatorLen); err != nil || !bytes.Equal(locator[:4], zip64LocatorSig) {
+			return errors.New("zip64 end of central directory locator is missing")
+		}
+		recordPos := binary.LittleEndian.Uint64(locator[8:16])
+		record := make([]byte, zip64EndLen)
+		if _, err := f.ReadAt(record, int64(recordPos)); err != nil || !bytes.Equal(record[:4], zip64EndSig) {
+			return errors.New("zip64 end of central directory record is missing")
+		}
+		onDisk = binary.LittleEndian.Uint64(record[24:32])
+		entries = binary.LittleEndian.Uint64(record[32:40])
+		dirSize = binary.LittleEndian.Uint64(record[40:48])
+		dirOffset = binary.LittleEndian.Uint64(record[48:56])
+		dirEnd = recordPos
+	}
+	if onDisk != entries {
+		return fmt.Errorf("end of central directory counts %d entries on this disk but %d in total", onDisk, entries)
+	}
+	if dirOffset+dirSize != dirEnd {
+		return fmt.Errorf("central directory (offset %d, %d bytes) does not end where the end record starts (offset %d)", dirOffset, dirSize, dirEnd)
+	}
+
+	dir := make([]byte, dirSize)
+	if _, err := f.ReadAt(dir, int64(dirOffset)); err != nil {
+		return fmt.Errorf("failed to read central directory: %w", err)
+	}
+	list, err := parseCentralDir(dir, entries)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].offset < list[j].offset })
+	var next uint64 // Where the previous entry ended
+	for _, entry := range list {
+		if entry.offset < next {
+			return fmt.Errorf("%s: overlaps the entry before it", entry.name)
+		}
+		end, err := checkLocalEntry(f, entry)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.name, err)
+		}
+		if end > dirOffset {
+			return fmt.Errorf("%s: runs into the central directory", entry.name)
+		}
+		next = end
+	}
+	return nil
+}
+
+// parseCentralDir decodes the records of a central directory holding
+// entries entries, which must fill it exactly
+func parseCentralDir(dir []byte, entries uint64) ([]strictEntry, error) {
+	list := make([]strictEntry, 0, min(entries, uint64(len(dir)/centralHeaderLen)))
+	names := make(map[string]bool)
+	pos := 0
+	for n := uint64(0); n < entries; n++ {
+		if pos+centralHeaderLen > len(dir) || !bytes.Equal(dir[pos:pos+4], centralDirSig) {
+			return nil, fmt.Errorf("central directory is damaged at entry %d", n+1)
+		}
+		record := dir[pos:]
+		nameLen := int(binary.LittleEndian.Uint16(record[28:30]))
+		extraLen := int(binary.LittleEndian.Uint16(record[30:32]))
+		commentLen := int(binary.LittleEndian.Uint16(record[32:34]))
+		if centralHeaderLen+nameLen+extraLen+commentLen > len(record) {
+			return nil, fmt.Errorf("central directory entry %d is truncated", n+1)
+		}
+		entry := strictEntry{
+			name:           record[centralHeaderLen : centralHeaderLen+nameLen],
+			flags:          binary.LittleEndian.Uint16(record[8:10]),
+			method:         binary.LittleEndian.Uint16(record[10:12]),
+			crc:            binary.LittleEndian.Uint32(record[16:20]),
+			compressedSize: uint64(binary.LittleEndian.Uint32(record[20:24])),
+			size:           uint64(binary.LittleEndian.Uint32(record[24:28])),
+			offset:         uint64(binary.LittleEndian.Uint32(record[42:46])),
+		}
+		if binary.LittleEndian.Uint16(record[34:36]) != 0 {
+			return nil, fmt.Errorf("%s: entry is on another disk", entry.name)
+		}
+		if entry.flags&0x1 != 0 {
+			return nil, fmt.Errorf("%s: entry is encrypted", entry.name)
+		}
+		extra := record[centralHeaderLen+nameLen : centralHeaderLen+nameLen+extraLen]
+		if err := entry.readZip64(extra); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.name, err)
+		}
+		if names[string(entry.name)] {
+			return nil, fmt.Errorf("%s: name is used by two entries", entry.name)
+		}
+		names[string(entry.name)] = true
+		list = append(list, entry)
+		pos += centralHeaderLen + nameLen + extraLen + commentLen
+	}
+	if pos != len(dir) {
+		return nil, fmt.Errorf("central directory has %d bytes after its %d entries", len(dir)-pos, entries)
+	}
+	return list, nil
+}
+
+// readZip64 takes the sizes and offset that overflow their central
+// directory fields from the zip64 extra field, in its fixed order
+func (e *strictEntry) readZip64(extra []byte) error {
+	if e.size != 0xffffffff && e.compressedSize != 0xffffffff && e.offset != 0xffffffff {
+		return nil
+	}
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		n := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+n > len(extra) {
+			break
+		}
+		field := extra[4 : 4+n]
+		extra = extra[4+n:]
+		if id != 0x0001 {
+			continue
+		}
+		for _, v := range []*uint64{&e.size, &e.compressedSize, &e.offset} {
+			if *v != 0xffffffff {
+				continue
+			}
+			if len(field) < 8 {
+				return errors.New("zip64 extra field is too short")
+			}
+			*v = binary.LittleEndian.Uint64(field[:8])
+			field = field[8:]
+		}
+		e.zip64 = true
+		return nil
+	}
+	return errors.New("zip64 extra field is missing")
+}
+
+// checkLocalEntry compares the entry's local header (and data descriptor)
+// with its central directory record and decompresses its data, returning
+// the offset just past the entry
+func checkLocalEntry(f *os.File, e strictEntry) (uint64, error) {
+	header := make([]byte, localHeaderLen)
+	if _, err := f.ReadAt(header, int64(e.offset)); err != nil || !bytes.Equal(header[:4], localHeaderSig) {
+		return 0, errors.New("no local file header at the offset the central directory gives")
+	}
+	flags := binary.LittleEndian.Uint16(header[6:8])
+	method := binary.LittleEndian.Uint16(header[8:10])
+	nameLen := int64(binary.LittleEndian.Uint16(header[26:28]))
+	extraLen := int64(binary.LittleEndian.Uint16(header[28:30]))
+	name := make([]byte, nameLen)
+	if _, err := f.ReadAt(name, int64(e.offset)+localHeaderLen); err != nil {
+		return 0, fmt.Errorf("failed to read local header name: %w", err)
+	}
+	switch {
+	case !bytes.Equal(name, e.name):
+		return 0, fmt.Errorf("local header names it %q", name)
+	case method != e.method:
+		return 0, fmt.Errorf("local header method %d, central directory %d", method, e.method)
+	case flags != e.flags:
+		return 0, fmt.Errorf("local header flags %#04x, central directory %#04x", flags, e.flags)
+	}
+
+	// Without a data descriptor the local header carries the real values
+	// (sizes moving to its zip64 field when they overflow)
+	if flags&0x8 == 0 {
+		crc := binary.LittleEndian.Uint32(header[14:18])
+		compressedSize := uint64(binary.LittleEndian.Uint32(header[18:22]))
+		size := uint64(binary.LittleEndian.Uint32(header[22:26]))
+		if crc != e.crc || (compressedSize != e.compressedSize && compressedSize != 0xffffffff) || (size != e.size && size != 0xffffffff) {
+			return 0, errors.New("local header CRC or sizes differ from the central directory")
+		}
+	}
+
+	start := e.offset + localHeaderLen + uint64(nameLen) + uint64(extraLen)
+	if err := checkData(io.NewSectionReader(f, int64(start), int64(e.compressedSize)), e); err != nil {
+		return 0, err
+	}
+	end := start + e.compressedSize
+	if flags&0x8 == 0 {
+		return end, nil
+	}
+
+	// The data descriptor, with or without its optional signature
+	sizeLen := 4
+	if e.zip64 {
+		sizeLen = 8
+	}
+	descriptor := make([]byte, 4+4+2*sizeLen)
+	if _, err := f.ReadAt(descriptor, int64(end)); err != nil {
+		return 0, fmt.Errorf("failed to read data descriptor: %w", err)
+	}
+	if bytes.Equal(descriptor[:4], dataDescriptorSig) {
+		end += 4
+		descriptor = descriptor[4:]
+	} else {
+		descriptor = descriptor[:len(descriptor)-4]
+	}
+	crc := binary.LittleEndian.Uint32(descriptor[0:4])
+	var compressedSize, size uint64
+	if e.zip64 {
+		compressedSize = binary.LittleEndian.Uint64(descriptor[4:12])
+		size = binary.LittleEndian.Uint64(descriptor[12:20])
+	} else {
+		compressedSize = uint64(binary.LittleEndian.Uint32(descriptor[4:8]))
+		size = uint64(binary.LittleEndian.Uint32(descriptor[8:12]))
+	}
+	if crc != e.crc || compressedSize != e.compressedSize || size != e.size {
+		return 0, errors.New("data descriptor differs from the central directory")
+	}
+	return end + 4 + 2*uint64(sizeLen), nil
+}
+
+// checkData decompresses an entry's data and checks its size and CRC
+func checkData(data *io.SectionReader, e strictEntry) error {
+	var r io.Reader
+	switch e.method {
+	case 0: // Store
+		if e.compressedSize != e.size {
+			return fmt.Errorf("stored entry has %d bytes but a size of %d", e.compressedSize, e.size)
+		}
+		r = data
+	case 8: // Deflate
+		fr := flate.NewReader(data)
+		defer fr.Close()
+		r = fr
+	default:
+		return fmt.Errorf("unsupported compression method %d", e.method)
+	}
+
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(crc, r)
+	if err != nil {
+		return fmt.Errorf("data does not decompress: %w", err)
+	}
+	if uint64(n) != e.size {
+		return fmt.Errorf("data decompresses to %d bytes, central directory says %d", n, e.size)
+	}
+	if crc.Sum32() != e.crc {
+		return fmt.Errorf("CRC mismatch: data %08x, central directory %08x", crc.Sum32(), e.crc)
+	}
+	return nil
+}
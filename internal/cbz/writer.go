@@ -2,32 +2,73 @@ package cbz
 
 import (
 	"archive/zip"
+	"bytes"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
 	"path/filepath"
+
+	"compress_comics/internal/fsutil"
 )
 
-// WriteEntry represents a file to write into the CBZ
+// WriteEntry represents a file to write into the CBZ. Its content is read
+// from Reader, which must yield exactly Size bytes, unless Raw is set.
 type WriteEntry struct {
-	Path string
-	Data []byte
+	Path   string
+	Reader io.Reader
+	Size   int64
+	Raw    *zip.File // Copy this source entry's compressed bytes and header as-is (see Source.Entry)
+}
+
+// BytesEntry returns a WriteEntry for in-memory content
+func BytesEntry(path string, data []byte) WriteEntry {
+	return WriteEntry{Path: path, Reader: bytes.NewReader(data), Size: int64(len(data))}
+}
+
+// TempSuffix is appended to the source name for compressed archives awaiting verification
+const TempSuffix = ".compressed.tmp.cbz"
+
+// WriterOptions configures a Writer
+type WriterOptions struct {
+	TempDir string // Directory for temporary archives (empty = next to the source)
+	Durable bool   // Fsync the archive and its directory before reporting success
+	Level   int    // Deflate level 0-9 (see DefaultZipLevel)
+	Store   bool   // Write every entry uncompressed (zip method store); Level is then unused
 }
 
 // Writer handles CBZ creation with atomic writes
-type Writer struct{}
+type Writer struct {
+	opts WriterOptions
+}
 
 // NewWriter creates a new CBZ writer
-func NewWriter() *Writer {
-	return &Writer{}
+func NewWriter(opts WriterOptions) *Writer {
+	return &Writer{opts: opts}
 }
 
-// Create builds a new CBZ file from entries using atomic write pattern
-// Writes to temp file first, then renames to final path
-func (w *Writer) Create(outputPath string, entries []WriteEntry) error {
+// Archive is a CBZ being written entry by entry. Nothing appears at the
+// output path until Commit; Abort discards the partial archive.
+type Archive struct {
+	path     string // Final output path
+	tempPath string
+	durable  bool
+	file     *os.File
+	zip      *zip.Writer
+	hasher   *contentHasher
+	store    bool // Entries are stored uncompressed
+	complete bool // The archive gets a processing marker (see Unmarked)
+	reserved bool // path is a reserved placeholder that Abort removes too
+}
+
+// Begin starts a CBZ at outputPath using the atomic write pattern: entries
+// are streamed to a temp file that Commit renames to the final path
+// (fsyncing both when Durable)
+func (w *Writer) Begin(outputPath string) (*Archive, error) {
 	// Create parent directory if needed
 	dir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
 	// Create temporary file in same directory for atomic rename
@@ -35,57 +76,207 @@ func (w *Writer) Create(outputPath string, entries []WriteEntry) error {
 
 	f, err := os.Create(tempPath)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
 
-	zipWriter := zip.NewWriter(f)
+	zw := zip.NewWriter(f)
+	registerDeflate(zw, w.opts.Level)
 
-	for _, entry := range entries {
-		header := &zip.FileHeader{
-			Name:   entry.Path,
-			Method: zip.Deflate,
-		}
-		header.SetMode(0644)
+	return &Archive{
+		path:     outputPath,
+		tempPath: tempPath,
+		durable:  w.opts.Durable,
+		file:     f,
+		zip:      zw,
+		hasher:   newContentHasher(),
+		store:    w.opts.Store,
+		complete: true,
+	}, nil
+}
+
+// Path returns the path the archive is committed to
+func (a *Archive) Path() string {
+	return a.path
+}
 
-		writer, err := zipWriter.CreateHeader(header)
+// copyRaw copies a source entry's compressed bytes, under entry.Path when
+// the entry was renamed
+func (a *Archive) copyRaw(entry WriteEntry) error {
+	if entry.Path == entry.Raw.Name {
+		return a.zip.Copy(entry.Raw)
+	}
+	header := entry.Raw.FileHeader
+	header.Name = entry.Path
+	w, err := a.zip.CreateRaw(&header)
+	if err != nil {
+		return err
+	}
+	r, err := entry.Raw.OpenRaw()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Add streams one entry into the archive. On error the archive is aborted.
+// Absolute and traversing paths are written normalized (see SafeEntryName).
+func (a *Archive) Add(entry WriteEntry) error {
+	entry.Path, _ = SafeEntryName(entry.Path)
+
+	// A stored archive takes deflated source entries decompressed
+	if entry.Raw != nil && a.store && entry.Raw.Method != zip.Store {
+		rc, err := entry.Raw.Open()
 		if err != nil {
-			f.Close()
-			os.Remove(tempPath)
-			return fmt.Errorf("failed to create entry %s: %w", entry.Path, err)
+			a.Abort()
+			return fmt.Errorf("failed to read entry %s: %w", entry.Raw.Name, err)
 		}
+		defer rc.Close()
+		entry.Reader, entry.Raw = rc, nil
+	}
 
-		if _, err := writer.Write(entry.Data); err != nil {
-			f.Close()
-			os.Remove(tempPath)
-			return fmt.Errorf("failed to write entry %s: %w", entry.Path, err)
+	if entry.Raw != nil {
+		if err := a.copyRaw(entry); err != nil {
+			a.Abort()
+			return fmt.Errorf("failed to copy entry %s: %w", entry.Raw.Name, err)
 		}
+		a.hasher.add(entry.Path, entry.Raw.CRC32, entry.Raw.UncompressedSize64)
+		return nil
 	}
 
-	if err := zipWriter.Close(); err != nil {
-		f.Close()
-		os.Remove(tempPath)
+	header := &zip.FileHeader{
+		Name:   entry.Path,
+		Method: zip.Deflate,
+	}
+	if a.store {
+		header.Method = zip.Store
+	}
+	header.SetMode(0644)
+
+	writer, err := a.zip.CreateHeader(header)
+	if err != nil {
+		a.Abort()
+		return fmt.Errorf("failed to create entry %s: %w", entry.Path, err)
+	}
+
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(io.MultiWriter(writer, crc), entry.Reader)
+	if err == nil && n != entry.Size {
+		err = fmt.Errorf("got %d bytes, expected %d", n, entry.Size)
+	}
+	if err != nil {
+		a.Abort()
+		return fmt.Errorf("failed to write entry %s: %w", entry.Path, err)
+	}
+	a.hasher.add(entry.Path, crc.Sum32(), uint64(n))
+	return nil
+}
+
+// Unmarked leaves the archive without the processing marker, for archives
+// rewritten without being compressed or with pages that failed (later runs
+// must still process them)
+func (a *Archive) Unmarked() {
+	a.complete = false
+}
+
+// Commit finishes the archive and moves it to its output path
+func (a *Archive) Commit() error {
+	// Mark the archive as ours so later runs can skip it while its content is unchanged
+	if a.complete {
+		if err := a.zip.SetComment(markerComment(a.hasher.sum())); err != nil {
+			a.Abort()
+			return fmt.Errorf("failed to set archive comment: %w", err)
+		}
+	}
+
+	if err := a.zip.Close(); err != nil {
+		a.Abort()
 		return fmt.Errorf("failed to close zip writer: %w", err)
 	}
 
-	if err := f.Close(); err != nil {
-		os.Remove(tempPath)
+	// Flush file contents to disk so the rename can never expose an empty archive
+	if a.durable {
+		if err := a.file.Sync(); err != nil {
+			a.Abort()
+			return fmt.Errorf("failed to sync file: %w", err)
+		}
+	}
+
+	if err := a.file.Close(); err != nil {
+		os.Remove(a.tempPath)
 		return fmt.Errorf("failed to close file: %w", err)
 	}
 
 	// Atomically rename temp to final
-	if err := os.Rename(tempPath, outputPath); err != nil {
-		os.Remove(tempPath)
+	if err := fsutil.Rename(a.tempPath, a.path); err != nil {
+		os.Remove(a.tempPath)
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
+	if a.durable {
+		dir := filepath.Dir(a.path)
+		if err := fsutil.SyncDir(dir); err != nil {
+			return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+		}
+	}
+
 	return nil
 }
 
-// CreateTemp creates a CBZ at a temporary path (for verification before replacing original)
-func (w *Writer) CreateTemp(basePath string, entries []WriteEntry) (string, error) {
-	tempPath := basePath + ".compressed.tmp.cbz"
-	if err := w.Create(tempPath, entries); err != nil {
-		return "", err
+// Abort discards the partial archive
+func (a *Archive) Abort() {
+	a.file.Close()
+	os.Remove(a.tempPath)
+	if a.reserved {
+		os.Remove(a.path)
+	}
+}
+
+// Create builds a new CBZ file from entries (see Begin)
+func (w *Writer) Create(outputPath string, entries []WriteEntry) error {
+	archive, err := w.Begin(outputPath)
+	if err != nil {
+		return err
 	}
-	return tempPath, nil
+	for _, entry := range entries {
+		if err := archive.Add(entry); err != nil {
+			return err
+		}
+	}
+	return archive.Commit()
+}
+
+// BeginTemp starts an archive at a temporary path (for verification before
+// replacing the original). With a TempDir configured the archive is built
+// there under a unique name, so same-named archives from different
+// directories never collide.
+func (w *Writer) BeginTemp(basePath string) (*Archive, error) {
+	tempPath := basePath + TempSuffix
+
+	if w.opts.TempDir != "" {
+		f, err := os.CreateTemp(w.opts.TempDir, filepath.Base(basePath)+".*"+TempSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve temp file in %s: %w", w.opts.TempDir, err)
+		}
+		tempPath = f.Name()
+		f.Close()
+	}
+
+	archive, err := w.Begin(tempPath)
+	if err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+	archive.reserved = w.opts.TempDir != ""
+	return archive, nil
+}
+
+// Durable reports whether writes are fsynced before returning
+func (w *Writer) Durable() bool {
+	return w.opts.Durable
+}
+
+// TempDir returns the configured temp directory (empty = next to the source)
+func (w *Writer) TempDir() string {
+	return w.opts.TempDir
 }
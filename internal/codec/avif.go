@@ -0,0 +1,30 @@
+package codec
+
+import (
+	"image"
+	"io"
+)
+
+// AVIF is AV1 in the same ISO-BMFF container as HEIF, so headers are read
+// the same way. Pixels are decoded by libavif's avifdec, libheif's
+// heif-convert (built with an AV1 decoder) or ImageMagick.
+var avifBrands = []string{"avif", "avis"}
+
+var avifTools = append([]externalTool{
+	{name: "avifdec", args: func(in, out string) []string { return []string{in, out} }},
+	{name: "heif-convert", args: func(in, out string) []string { return []string{in, out} }},
+}, imageMagick...)
+
+func init() {
+	for _, brand := range avifBrands {
+		image.RegisterFormat("avif", "????ftyp"+brand, decodeAVIF, decodeHEIFConfig)
+	}
+}
+
+func decodeAVIF(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeExternal(data, ".avif", avifTools)
+}
@@ -0,0 +1,181 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoDecoder is returned when no external tool for a format is installed
+var ErrNoDecoder = errors.New("no external decoder installed")
+
+// ErrNoEncoder is returned when the external encoder for a format is not installed
+var ErrNoEncoder = errors.New("external encoder not installed")
+
+// externalTimeout bounds a single external decode
+const externalTimeout = 2 * time.Minute
+
+// externalTool converts an input file to PNG
+type externalTool struct {
+	name string
+	args func(in, out string) []string
+}
+
+// imageMagick covers most formats; IM7 ships `magick`, IM6 only `convert`
+var imageMagick = []externalTool{
+	{name: "magick", args: func(in, out string) []string { return []string{in, "png:" + out} }},
+	{name: "convert", args: func(in, out string) []string { return []string{in, "png:" + out} }},
+}
+
+// decodeExternal writes data to a temp file named with ext and converts it to
+// PNG with each installed tool in turn until one succeeds (e.g. an ImageMagick
+// build without the HEIF delegate falls through to heif-convert)
+func decodeExternal(data []byte, ext string, tools []externalTool) (image.Image, error) {
+	var dir string
+	var errs []error
+	for _, tool := range tools {
+		path, err := exec.LookPath(tool.name)
+		if err != nil {
+			continue
+		}
+
+		if dir == "" {
+			dir, err = os.MkdirTemp("", "cbz-decode-*")
+			if err != nil {
+				return nil, fmt.Errorf("failed to create decode dir: %w", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := os.WriteFile(filepath.Join(dir, "in"+ext), data, 0600); err != nil {
+				return nil, fmt.Errorf("failed to write decode input: %w", err)
+			}
+		}
+
+		img, err := runExternal(tool, path, filepath.Join(dir, "in"+ext), filepath.Join(dir, "out.png"))
+		if err == nil {
+			return img, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.name
+	}
+	return nil, fmt.Errorf("%w for %s (install one of %v)", ErrNoDecoder, ext, names)
+}
+
+// runExternal converts in to the PNG out with one tool and decodes the result
+func runExternal(tool externalTool, path, in, out string) (image.Image, error) {
+	os.Remove(out)
+
+	if err := runTool(tool, path, in, out); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s produced no output: %w", tool.name, err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s output: %w", tool.name, err)
+	}
+	return img, nil
+}
+
+// convertExternal runs tool on data (written with extension inExt) and
+// returns the bytes of the output file (extension outExt)
+func convertExternal(data []byte, inExt, outExt string, tool externalTool) ([]byte, error) {
+	path, err := exec.LookPath(tool.name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoEncoder, tool.name)
+	}
+
+	dir, err := os.MkdirTemp("", "cbz-convert-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create convert dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in"+inExt)
+	out := filepath.Join(dir, "out"+outExt)
+	if err := os.WriteFile(in, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write convert input: %w", err)
+	}
+	if err := runTool(tool, path, in, out); err != nil {
+		return nil, err
+	}
+
+	converted, err := os.ReadFile(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s produced no output: %w", tool.name, err)
+	}
+	return converted, nil
+}
+
+// runTool runs one tool invocation under the external timeout
+func runTool(tool externalTool, path, in, out string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), externalTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, tool.args(in, out)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", tool.name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// encodeExternal writes img as PNG to a temp file, runs tool to convert it
+// into a file with extension ext and returns the encoded bytes
+func encodeExternal(img image.Image, ext string, tool externalTool) ([]byte, error) {
+	path, err := exec.LookPath(tool.name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoEncoder, tool.name)
+	}
+
+	dir, err := os.MkdirTemp("", "cbz-encode-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encode dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out"+ext)
+	f, err := os.Create(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write encode input: %w", err)
+	}
+	// Speed over size: this is an intermediate the tool reads once
+	enc := png.Encoder{CompressionLevel: png.NoCompression}
+	if err := enc.Encode(f, img); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write encode input: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write encode input: %w", err)
+	}
+
+	if err := runTool(tool, path, in, out); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s produced no output: %w", tool.name, err)
+	}
+	return data, nil
+}
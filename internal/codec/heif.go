@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// HEIF/HEIC (e.g. iPhone scans) is an ISO-BMFF container recognised by its
+// ftyp brand. Dimensions come from the ispe boxes; pixels are decoded by
+// libheif's heif-convert or ImageMagick.
+var heifBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1"}
+
+var heifTools = append([]externalTool{{
+	name: "heif-convert",
+	args: func(in, out string) []string { return []string{in, out} },
+}}, imageMagick...)
+
+func init() {
+	for _, brand := range heifBrands {
+		image.RegisterFormat("heif", "????ftyp"+brand, decodeHEIF, decodeHEIFConfig)
+	}
+}
+
+func decodeHEIF(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeExternal(data, ".heic", heifTools)
+}
+
+var errHEIFHeader = errors.New("heif: image size not found")
+
+// decodeHEIFConfig walks meta > iprp > ipco and reports the largest image
+// spatial extent (ispe). Grid images list both tiles and the full canvas;
+// the canvas is the largest.
+func decodeHEIFConfig(r io.Reader) (image.Config, error) {
+	br := bufio.NewReader(r)
+	for {
+		boxType, body, err := nextBox(br)
+		if err != nil {
+			if err == errJP2Header {
+				err = errHEIFHeader
+			}
+			return image.Config{}, err
+		}
+		if boxType != "meta" {
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				return image.Config{}, err
+			}
+			continue
+		}
+
+		// meta is a FullBox: version and flags precede its children
+		if _, err := io.CopyN(io.Discard, body, 4); err != nil {
+			return image.Config{}, err
+		}
+		ipco, err := findBox(body, "iprp", "ipco")
+		if err != nil {
+			return image.Config{}, err
+		}
+
+		var width, height uint32
+		children := bufio.NewReader(ipco)
+		for {
+			childType, child, err := nextBox(children)
+			if err != nil {
+				break
+			}
+			if childType == "ispe" {
+				var ispe [12]byte // version/flags(4) width(4) height(4)
+				if _, err := io.ReadFull(child, ispe[:]); err == nil {
+					w, h := binary.BigEndian.Uint32(ispe[4:8]), binary.BigEndian.Uint32(ispe[8:12])
+					if uint64(w)*uint64(h) > uint64(width)*uint64(height) {
+						width, height = w, h
+					}
+				}
+			}
+			io.Copy(io.Discard, child)
+		}
+
+		if width == 0 || height == 0 {
+			return image.Config{}, errHEIFHeader
+		}
+		return image.Config{Width: int(width), Height: int(height), ColorModel: color.RGBAModel}, nil
+	}
+}
+
+// findBox descends through nested boxes by type, returning the innermost body
+func findBox(r io.Reader, path ...string) (io.Reader, error) {
+	for _, want := range path {
+		br := bufio.NewReader(r)
+		for {
+			boxType, body, err := nextBox(br)
+			if err != nil {
+				return nil, errHEIFHeader
+			}
+			if boxType == want {
+				r = body
+				break
+			}
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
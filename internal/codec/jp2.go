@@ -0,0 +1,142 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// JPEG 2000 comes as a JP2 container or a raw J2K codestream. Headers are
+// parsed here so analysis works without any tools; pixels are decoded by
+// ImageMagick or OpenJPEG.
+const (
+	jp2Magic = "\x00\x00\x00\x0cjP  \r\n\x87\n"
+	j2kMagic = "\xff\x4f\xff\x51"
+)
+
+var jp2Tools = append(append([]externalTool{}, imageMagick...), externalTool{
+	name: "opj_decompress",
+	args: func(in, out string) []string { return []string{"-i", in, "-o", out} },
+})
+
+func init() {
+	image.RegisterFormat("jp2", jp2Magic, decodeJP2, decodeJP2Config)
+	image.RegisterFormat("j2k", j2kMagic, decodeJ2K, decodeJ2KConfig)
+}
+
+func decodeJP2(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeExternal(data, ".jp2", jp2Tools)
+}
+
+func decodeJ2K(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeExternal(data, ".j2k", jp2Tools)
+}
+
+var errJP2Header = errors.New("jp2: image header not found")
+
+// decodeJP2Config finds the ihdr box inside the jp2h superbox
+func decodeJP2Config(r io.Reader) (image.Config, error) {
+	br := bufio.NewReader(r)
+	for {
+		boxType, body, err := nextBox(br)
+		if err != nil {
+			return image.Config{}, err
+		}
+		if boxType != "jp2h" {
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				return image.Config{}, err
+			}
+			continue
+		}
+
+		// Header superbox: ihdr is required to be its first child
+		childType, child, err := nextBox(bufio.NewReader(body))
+		if err != nil {
+			return image.Config{}, err
+		}
+		if childType != "ihdr" {
+			return image.Config{}, errJP2Header
+		}
+		var ihdr [11]byte // HEIGHT(4) WIDTH(4) NC(2) BPC(1)
+		if _, err := io.ReadFull(child, ihdr[:]); err != nil {
+			return image.Config{}, err
+		}
+		return image.Config{
+			Width:      int(binary.BigEndian.Uint32(ihdr[4:8])),
+			Height:     int(binary.BigEndian.Uint32(ihdr[0:4])),
+			ColorModel: jp2ColorModel(int(binary.BigEndian.Uint16(ihdr[8:10])), int(ihdr[10]&0x7f)+1),
+		}, nil
+	}
+}
+
+// decodeJ2KConfig reads the SIZ marker segment that follows SOC
+func decodeJ2KConfig(r io.Reader) (image.Config, error) {
+	// SOC(2) SIZ(2) Lsiz(2) Rsiz(2) Xsiz(4) Ysiz(4) XOsiz(4) YOsiz(4) XTsiz..YTOsiz(16) Csiz(2) Ssiz(1)
+	var siz [43]byte
+	if _, err := io.ReadFull(r, siz[:]); err != nil {
+		return image.Config{}, err
+	}
+	width := binary.BigEndian.Uint32(siz[8:12]) - binary.BigEndian.Uint32(siz[16:20])
+	height := binary.BigEndian.Uint32(siz[12:16]) - binary.BigEndian.Uint32(siz[20:24])
+	components := int(binary.BigEndian.Uint16(siz[40:42]))
+	return image.Config{
+		Width:      int(width),
+		Height:     int(height),
+		ColorModel: jp2ColorModel(components, int(siz[42]&0x7f)+1),
+	}, nil
+}
+
+// nextBox reads a box header and returns its type and a reader limited to its body
+func nextBox(r *bufio.Reader) (string, io.Reader, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF {
+			return "", nil, errJP2Header
+		}
+		return "", nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(hdr[0:4]))
+	boxType := string(hdr[4:8])
+
+	switch size {
+	case 0: // Box extends to end of file
+		return boxType, r, nil
+	case 1: // 64-bit extended length follows
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return "", nil, err
+		}
+		size = int64(binary.BigEndian.Uint64(ext[:])) - 16
+	default:
+		size -= 8
+	}
+	if size < 0 {
+		return "", nil, errJP2Header
+	}
+	return boxType, io.LimitReader(r, size), nil
+}
+
+// jp2ColorModel approximates the decoded color model from component count and bit depth
+func jp2ColorModel(components, bits int) color.Model {
+	switch {
+	case components == 1 && bits > 8:
+		return color.Gray16Model
+	case components == 1:
+		return color.GrayModel
+	case bits > 8:
+		return color.RGBA64Model
+	default:
+		return color.RGBAModel
+	}
+}
@@ -0,0 +1,137 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os/exec"
+)
+
+// JPEGInfo describes a JPEG from its markers, without decoding pixels
+type JPEGInfo struct {
+	Width, Height  int
+	Components     int  // 1 = gray, 3 = YCbCr/RGB, 4 = CMYK/YCCK
+	AdobeTransform int  // APP14 transform flag (-1 if no Adobe marker)
+	HasICC         bool // Embedded ICC profile (APP2)
+	Quality        int  // Estimated encoder quality from the luminance table (0 = unknown)
+}
+
+// CMYK reports whether the JPEG stores four-channel print colors
+func (i JPEGInfo) CMYK() bool {
+	return i.Components == 4
+}
+
+// MaxJPEGDimension is the largest width or height a JPEG frame header can hold
+const MaxJPEGDimension = 65535
+
+var errNoFrame = errors.New("jpeg: no frame header")
+
+// ParseJPEG walks the markers up to the frame header
+func ParseJPEG(data []byte) (JPEGInfo, error) {
+	info := JPEGInfo{AdobeTransform: -1}
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return info, errors.New("jpeg: missing SOI marker")
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return info, errNoFrame
+		}
+		marker := data[i+1]
+		if marker == 0xff { // Fill byte
+			i++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) { // No payload
+			i += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return info, errNoFrame
+		}
+		payload := data[i+4 : i+2+length]
+
+		switch {
+		case marker == 0xe2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")):
+			info.HasICC = true
+		case marker == 0xee && bytes.HasPrefix(payload, []byte("Adobe")) && len(payload) >= 12:
+			info.AdobeTransform = int(payload[11])
+		case marker == 0xdb:
+			if table, ok := readLuminanceTable(payload); ok {
+				info.Quality = estimateQuality(table)
+			}
+		case marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc:
+			// SOFn: precision(1) height(2) width(2) components(1)
+			if len(payload) < 6 {
+				return info, errNoFrame
+			}
+			info.Height = int(binary.BigEndian.Uint16(payload[1:3]))
+			info.Width = int(binary.BigEndian.Uint16(payload[3:5]))
+			info.Components = int(payload[5])
+			return info, nil
+		case marker == 0xda: // Start of scan before any frame header
+			return info, errNoFrame
+		}
+		i += 2 + length
+	}
+	return info, errNoFrame
+}
+
+// jpgicc (littleCMS) converts through the embedded profile to sRGB by default
+var jpgiccTool = externalTool{
+	name: "jpgicc",
+	args: func(in, out string) []string { return []string{"-q100", in, out} },
+}
+
+// DecodeCMYK decodes a CMYK JPEG to RGB. With an embedded ICC profile and
+// jpgicc installed, colors go through the profile; otherwise Go's decoder
+// is used (ImageMagick as fallback for layouts it rejects) and converted
+// with the device-independent formula.
+func DecodeCMYK(data []byte, info JPEGInfo) (image.Image, error) {
+	if info.HasICC {
+		if _, err := exec.LookPath(jpgiccTool.name); err == nil {
+			converted, err := convertExternal(data, ".jpg", ".jpg", jpgiccTool)
+			if err == nil {
+				if img, err := jpeg.Decode(bytes.NewReader(converted)); err == nil {
+					return img, nil
+				}
+			}
+		}
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		img, err = decodeExternal(data, ".jpg", imageMagick)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cmykToRGB(img), nil
+}
+
+// cmykToRGB converts a decoded CMYK image to NRGBA; other images pass through
+func cmykToRGB(img image.Image) image.Image {
+	src, ok := img.(*image.CMYK)
+	if !ok {
+		return img
+	}
+
+	b := src.Bounds()
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		si := src.PixOffset(b.Min.X, y)
+		di := dst.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl := color.CMYKToRGB(src.Pix[si], src.Pix[si+1], src.Pix[si+2], src.Pix[si+3])
+			dst.Pix[di], dst.Pix[di+1], dst.Pix[di+2], dst.Pix[di+3] = r, g, bl, 0xff
+			si += 4
+			di += 4
+		}
+	}
+	return dst
+}
@@ -0,0 +1,63 @@
+package codec
+
+// ijgLuminance is the IJG (libjpeg) base luminance quantization table in
+// zigzag order, the order DQT segments store it in
+var ijgLuminance = [64]int{
+	16, 11, 12, 14, 12, 10, 16, 14, 13, 14, 18, 17, 16, 19, 24, 40,
+	26, 24, 22, 22, 24, 49, 35, 37, 29, 40, 58, 51, 61, 60, 57, 51,
+	56, 55, 64, 72, 92, 78, 64, 68, 87, 69, 55, 56, 80, 109, 81, 87,
+	95, 98, 103, 104, 103, 62, 77, 113, 121, 112, 100, 120, 92, 101, 103, 99,
+}
+
+// estimateQuality returns the IJG quality (1-100) whose scaled luminance
+// table is closest to table. Nearly every encoder scales this table, so
+// the estimate is exact for them and close for the rest.
+func estimateQuality(table [64]int) int {
+	best, bestDiff := 0, -1
+	for q := 1; q <= 100; q++ {
+		scale := 200 - 2*q
+		if q < 50 {
+			scale = 5000 / q
+		}
+		diff := 0
+		for i, base := range ijgLuminance {
+			v := min(max((base*scale+50)/100, 1), 255)
+			d := v - table[i]
+			if d < 0 {
+				d = -d
+			}
+			diff += d
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = q, diff
+		}
+	}
+	return best
+}
+
+// readLuminanceTable finds table 0 in a DQT payload
+func readLuminanceTable(payload []byte) ([64]int, bool) {
+	var table [64]int
+	for len(payload) > 0 {
+		precision, id := payload[0]>>4, payload[0]&0x0f
+		size := 64
+		if precision == 1 {
+			size = 128
+		}
+		if len(payload) < 1+size {
+			return table, false
+		}
+		if id == 0 {
+			for i := range table {
+				if precision == 1 {
+					table[i] = int(payload[1+2*i])<<8 | int(payload[2+2*i])
+				} else {
+					table[i] = int(payload[1+i])
+				}
+			}
+			return table, true
+		}
+		payload = payload[1+size:]
+	}
+	return table, false
+}
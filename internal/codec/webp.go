@@ -0,0 +1,25 @@
+package codec
+
+import (
+	"image"
+	"os/exec"
+	"strconv"
+)
+
+// WebP decoding is native (golang.org/x/image/webp); encoding needs libwebp's cwebp
+
+// WebPEncoderAvailable reports whether cwebp is installed
+func WebPEncoderAvailable() bool {
+	_, err := exec.LookPath("cwebp")
+	return err == nil
+}
+
+// EncodeWebP encodes img as lossy WebP at quality (0-100)
+func EncodeWebP(img image.Image, quality int) ([]byte, error) {
+	return encodeExternal(img, ".webp", externalTool{
+		name: "cwebp",
+		args: func(in, out string) []string {
+			return []string{"-quiet", "-q", strconv.Itoa(quality), in, "-o", out}
+		},
+	})
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ComicInfoRule applies settings to archives whose ComicInfo.xml matches,
+// so decisions can follow metadata rather than file statistics:
+//
+//	comicinfo_rules:
+//	  - {field: Genre, contains: Artbook, skip: true}
+//	  - {field: Manga, equals: "Yes", grayscale: true}
+//
+// Rules are applied in order, later matches overriding earlier ones; an
+// override file for the archive takes precedence over all of them.
+type ComicInfoRule struct {
+	Field    string `yaml:"field"`    // ComicInfo.xml element, e.g. Genre, Manga, Publisher
+	Equals   string `yaml:"equals"`   // Matches the whole value, ignoring case
+	Contains string `yaml:"contains"` // Matches part of the value, ignoring case
+
+	Skip         bool   `yaml:"skip"`          // Never process matching archives
+	Reason       string `yaml:"reason"`        // Skip reason (default: the condition)
+	MaxDimension int    `yaml:"max_dimension"` // Maximum dimension in pixels
+	JPEGQuality  int    `yaml:"jpeg_quality"`  // JPEG quality 1-100
+	Grayscale    bool   `yaml:"grayscale"`     // Re-encode pages as grayscale
+}
+
+// ComicInfoRules is the comicinfo_rules list
+type ComicInfoRules []ComicInfoRule
+
+// Validate checks that every rule has a field, one condition and valid settings
+func (r ComicInfoRules) Validate() error {
+	for i, rule := range r {
+		switch {
+		case rule.Field == "":
+			return fmt.Errorf("comicinfo_rules[%d]: field is required", i)
+		case (rule.Equals == "") == (rule.Contains == ""):
+			return fmt.Errorf("comicinfo_rules[%d]: set exactly one of equals or contains", i)
+		case rule.JPEGQuality != 0 && (rule.JPEGQuality < 1 || rule.JPEGQuality > 100):
+			return fmt.Errorf("comicinfo_rules[%d]: jpeg_quality must be between 1 and 100", i)
+		case rule.MaxDimension < 0:
+			return fmt.Errorf("comicinfo_rules[%d]: max_dimension cannot be negative", i)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the rule's condition holds for the ComicInfo.xml
+// fields (element name -> text). A missing field never matches.
+func (rule ComicInfoRule) Matches(fields map[string]string) bool {
+	value, ok := fields[rule.Field]
+	if !ok {
+		return false
+	}
+	if rule.Equals != "" {
+		return strings.EqualFold(strings.TrimSpace(value), rule.Equals)
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(rule.Contains))
+}
+
+// String describes the condition, e.g. `Genre contains "Artbook"`
+func (rule ComicInfoRule) String() string {
+	if rule.Equals != "" {
+		return fmt.Sprintf("%s is %q", rule.Field, rule.Equals)
+	}
+	return fmt.Sprintf("%s contains %q", rule.Field, rule.Contains)
+}
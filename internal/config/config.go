@@ -3,7 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+
+	"compress_comics/internal/cbz"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,38 +22,118 @@ var embeddedDefaults *Config
 // Config holds all settings for compression
 type Config struct {
 	// Configurable via YAML file
-	MaxDimension    int      `yaml:"max_dimension"`         // Maximum dimension in pixels
-	JPEGQuality     int      `yaml:"jpeg_quality"`          // JPEG quality 1-100
-	BackupDir       string   `yaml:"backup_dir"`            // Where to move originals
-	ThresholdMBPage float64  `yaml:"threshold_mb_per_page"` // MB per page threshold for skip heuristic
-	SkipPatterns    []string `yaml:"skip_patterns"`         // Filename patterns to skip (e.g., "._*")
+	MaxDimension      int               `yaml:"max_dimension"`         // Maximum dimension in pixels
+	JPEGQuality       int               `yaml:"jpeg_quality"`          // JPEG quality 1-100
+	BackupDir         string            `yaml:"backup_dir"`            // Where to move originals
+	BackupMode        string            `yaml:"backup_mode"`           // "dir" (backup_dir), "trash" (OS trash) or "store" (deduplicated)
+	ThresholdMBPage   float64           `yaml:"threshold_mb_per_page"` // MB per page threshold for skip heuristic
+	SkipPatterns      []string          `yaml:"skip_patterns"`         // Filename patterns to skip (e.g., "._*")
+	ArchiveExtensions []string          `yaml:"archive_extensions"`    // File extensions treated as comic archives (e.g. .cbz, .zip)
+	ExcludeFile       string            `yaml:"exclude_file"`          // gitignore-style list of archives never to process (see also .cbzignore)
+	FormatPolicy      cbz.FormatPolicy  `yaml:"format_policy"`         // Per source format: convert (default) or keep
+	ProcessExtensions cbz.ExtensionList `yaml:"process_extensions"`    // Page extensions processed; others pass through (empty = all)
+
+	AutoLevelsDirs    []string       `yaml:"auto_levels_dirs"`         // Directory patterns whose archives always get auto-levels
+	LevelsClipPercent float64        `yaml:"auto_levels_clip_percent"` // Pixels ignored at each end of the histogram
+	Gamma             float64        `yaml:"gamma"`                    // Midtone gamma (1 = unchanged)
+	MaxMegapixels     float64        `yaml:"max_megapixels"`           // Refuse to decode larger pages (0 = unlimited)
+	MaxDecodeMB       int            `yaml:"max_decode_mb"`            // Refuse pages estimated to need more memory to decode (0 = unlimited)
+	ComposeAspect     float64        `yaml:"compose_aspect"`           // Stack strip slices into pages of this height/width (0 = off)
+	ZipLevel          int            `yaml:"zip_level"`                // Deflate level of written archives, 0-9
+	ZipMethod         string         `yaml:"zip_method"`               // deflate, or store to write entries uncompressed
+	QualityCurve      QualityCurve   `yaml:"quality_curve"`            // JPEG quality adjustment by scale factor (empty = constant quality)
+	Grayscale         bool           `yaml:"grayscale"`                // Re-encode every page as grayscale
+	KeepLowQuality    bool           `yaml:"keep_low_quality"`         // Pass through JPEGs saved below the target quality instead of re-encoding
+	KeepModernKBPerMP float64        `yaml:"keep_modern_kb_per_mp"`    // Pass through WebP/AVIF pages under this many KB per megapixel (0 = convert them)
+	ComicInfoRules    ComicInfoRules `yaml:"comicinfo_rules"`          // Per-archive settings chosen by ComicInfo.xml fields
+	SeriesRules       SeriesRules    `yaml:"series_rules"`             // Per-series settings chosen by directory name
+	DeviceProfiles    DeviceProfiles `yaml:"device_profiles"`          // Reading devices the report checks page dimensions against
+	MountLimits       MountLimits    `yaml:"mount_limits"`             // Most archives in flight per filesystem (unlisted = unlimited)
 
 	// Runtime flags (not in YAML)
-	Recursive bool // Process directories recursively
-	Force     bool // Process even if file appears optimized
-	DryRun    bool // Preview mode without changes
-	Verbose   bool // Detailed output
-	Workers   int  // Concurrent processing
+	Recursive    bool          // Process directories recursively
+	Force        bool          // Process even if file appears optimized
+	IgnoreMarker bool          // Reprocess archives a previous run marked as processed
+	DryRun       bool          // Preview mode without changes
+	Verbose      bool          // Detailed output
+	Workers      int           // Concurrent processing
+	MaxFailures  int           // Abort the batch after this many failed files (0 = unlimited)
+	Preflight    bool          // Check that every directory the batch writes to is writable before starting
+	RecentDays   int           // Process archives modified in the last N days before the rest (0 = scan order)
+	Strict       bool          // Fail an archive when any page fails, instead of keeping the page
+	Salvage      bool          // Keep the corrupt input pages that still decode and drop the rest, instead of failing the archive
+	RenameToCBZ  string        // What happens to archives without a .cbz extension: keep, replace or alongside
+	Pages        cbz.PageRange // Pages to keep (zero value = all pages)
+	Codecs       []string      // Codecs raced per page, smallest wins (empty = JPEG only)
+	Dither       bool          // Dither 16-bit pages down to 8 bits instead of rounding
+	AutoLevels   bool          // Stretch contrast of every page (see also AutoLevelsDirs)
+	Deskew       bool          // Straighten slightly rotated scans before resizing
+	Webtoon      bool          // Clamp only page width; vertical strips keep their height
+	Flatten      bool          // Renumber pages in folders inside archives into the archive root
+	Split        bool          // Write each chapter folder of a merged archive as its own CBZ
+	JoinSplit    bool          // Rewrite split sets (.z01, .z02, ... + .zip) as one archive even when nothing else needs doing
+
+	TempDir       string // Where temporary archives are built (empty = next to the source)
+	LocalCopy     bool   // Copy each archive to TempDir, process it there and copy the result back
+	TempBudgetMB  int    // Most temp space, in MB, the archives in flight may take; larger jobs wait (0 = unlimited)
+	MaxInFlightMB int    // Most MB of archive contents in memory at once, on top of Workers (0 = unlimited)
+	OutputDir     string // Write compressed archives here instead of replacing originals (empty = in place)
+	OutputName    string // Template for paths under OutputDir (empty = mirror the input tree)
+	OnCollision   string // What to do when an output archive exists: skip, overwrite or version
+	CopyWorkers   int    // Concurrent copies to and from the source volume with LocalCopy
+	Durable       bool   // Fsync archives and directories around every replacement
+	PreserveMTime bool   // Give replaced archives the original modification time
+	SampleDir     string // Where to export before/after page pairs (empty = disabled)
+	SampleCount   int    // Number of sample pairs per archive
 }
 
 // DefaultSkipPatterns contains common patterns to skip (macOS resource forks, etc.)
 var DefaultSkipPatterns = []string{"._*", ".DS_Store", "__MACOSX"}
 
+// DefaultArchiveExtensions are the file extensions processed when none are configured
+var DefaultArchiveExtensions = []string{".cbz"}
+
+// Decode guard defaults: generous for real scans, but stop decompression bombs
+const (
+	DefaultMaxMegapixels = 150
+	DefaultMaxDecodeMB   = 2048
+)
+
+// DefaultKeepModernKBPerMP is about 1.6 bits per pixel: WebP and AVIF pages
+// below it are already smaller than a JPEG of the same page at quality 90
+const DefaultKeepModernKBPerMP = 200
+
+// DefaultLevelsClipPercent ignores stray specks and dust when finding black/white points
+const DefaultLevelsClipPercent = 0.5
+
 // InitEmbedded initializes the embedded defaults from build-time YAML data.
 // This must be called before any other config functions.
 func InitEmbedded(data []byte) error {
 	cfg := &Config{
 		// Hardcoded fallbacks (should never be needed if embedded YAML is valid)
-		MaxDimension:    1800,
-		JPEGQuality:     90,
-		BackupDir:       "originals_backup",
-		ThresholdMBPage: 1.5,
-		SkipPatterns:    DefaultSkipPatterns,
+		MaxDimension:      1800,
+		JPEGQuality:       90,
+		BackupDir:         "originals_backup",
+		BackupMode:        "dir",
+		ThresholdMBPage:   1.5,
+		SkipPatterns:      DefaultSkipPatterns,
+		ArchiveExtensions: DefaultArchiveExtensions,
+		MaxMegapixels:     DefaultMaxMegapixels,
+		MaxDecodeMB:       DefaultMaxDecodeMB,
+		LevelsClipPercent: DefaultLevelsClipPercent,
+		Gamma:             1,
+		ZipLevel:          cbz.DefaultZipLevel,
+		ZipMethod:         cbz.ZipMethodDeflate,
+		KeepLowQuality:    true,
+		KeepModernKBPerMP: DefaultKeepModernKBPerMP,
 	}
 
 	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return fmt.Errorf("failed to parse embedded config: %w", err)
 	}
+	if err := cfg.normalizeArchiveExtensions(); err != nil {
+		return fmt.Errorf("invalid embedded config: %w", err)
+	}
 
 	embeddedDefaults = cfg
 	return nil
@@ -63,21 +148,54 @@ func DefaultConfig() Config {
 		DryRun:    false,
 		Verbose:   false,
 		Workers:   runtime.NumCPU(),
+
+		PreserveMTime: true,
 	}
 
 	if embeddedDefaults != nil {
 		cfg.MaxDimension = embeddedDefaults.MaxDimension
 		cfg.JPEGQuality = embeddedDefaults.JPEGQuality
 		cfg.BackupDir = embeddedDefaults.BackupDir
+		cfg.BackupMode = embeddedDefaults.BackupMode
 		cfg.ThresholdMBPage = embeddedDefaults.ThresholdMBPage
 		cfg.SkipPatterns = embeddedDefaults.SkipPatterns
+		cfg.ArchiveExtensions = embeddedDefaults.ArchiveExtensions
+		cfg.ExcludeFile = embeddedDefaults.ExcludeFile
+		cfg.FormatPolicy = embeddedDefaults.FormatPolicy
+		cfg.ProcessExtensions = embeddedDefaults.ProcessExtensions
+		cfg.AutoLevelsDirs = embeddedDefaults.AutoLevelsDirs
+		cfg.LevelsClipPercent = embeddedDefaults.LevelsClipPercent
+		cfg.Gamma = embeddedDefaults.Gamma
+		cfg.MaxMegapixels = embeddedDefaults.MaxMegapixels
+		cfg.MaxDecodeMB = embeddedDefaults.MaxDecodeMB
+		cfg.ComposeAspect = embeddedDefaults.ComposeAspect
+		cfg.ZipLevel = embeddedDefaults.ZipLevel
+		cfg.ZipMethod = embeddedDefaults.ZipMethod
+		cfg.QualityCurve = embeddedDefaults.QualityCurve
+		cfg.Grayscale = embeddedDefaults.Grayscale
+		cfg.KeepLowQuality = embeddedDefaults.KeepLowQuality
+		cfg.KeepModernKBPerMP = embeddedDefaults.KeepModernKBPerMP
+		cfg.ComicInfoRules = embeddedDefaults.ComicInfoRules
+		cfg.SeriesRules = embeddedDefaults.SeriesRules
+		cfg.DeviceProfiles = embeddedDefaults.DeviceProfiles
+		cfg.MountLimits = embeddedDefaults.MountLimits
 	} else {
 		// Hardcoded fallbacks
 		cfg.MaxDimension = 1800
 		cfg.JPEGQuality = 90
 		cfg.BackupDir = "originals_backup"
+		cfg.BackupMode = "dir"
 		cfg.ThresholdMBPage = 1.5
 		cfg.SkipPatterns = DefaultSkipPatterns
+		cfg.ArchiveExtensions = DefaultArchiveExtensions
+		cfg.MaxMegapixels = DefaultMaxMegapixels
+		cfg.MaxDecodeMB = DefaultMaxDecodeMB
+		cfg.LevelsClipPercent = DefaultLevelsClipPercent
+		cfg.Gamma = 1
+		cfg.ZipLevel = cbz.DefaultZipLevel
+		cfg.ZipMethod = cbz.ZipMethodDeflate
+		cfg.KeepLowQuality = true
+		cfg.KeepModernKBPerMP = DefaultKeepModernKBPerMP
 	}
 
 	return cfg
@@ -95,6 +213,9 @@ func LoadFromFile(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.normalizeArchiveExtensions(); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
@@ -113,32 +234,140 @@ func LoadWithDefaults() (*Config, error) {
 	return cfg, nil
 }
 
+// normalizeArchiveExtensions lowercases the configured extensions and adds
+// missing leading dots, rejecting an empty list
+func (c *Config) normalizeArchiveExtensions() error {
+	if len(c.ArchiveExtensions) == 0 {
+		return fmt.Errorf("archive_extensions cannot be empty")
+	}
+	normalized := make([]string, len(c.ArchiveExtensions))
+	for i, ext := range c.ArchiveExtensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if strings.Trim(ext, ".") == "" {
+			return fmt.Errorf("archive_extensions: invalid extension %q", c.ArchiveExtensions[i])
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized[i] = ext
+	}
+	c.ArchiveExtensions = normalized
+	return nil
+}
+
+// IsArchive reports whether name has one of the archive extensions
+func (c Config) IsArchive(name string) bool {
+	return slices.Contains(c.ArchiveExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
 // String returns a formatted string representation of the config
 func (c Config) String() string {
 	skipPatternsStr := "[]"
 	if len(c.SkipPatterns) > 0 {
 		skipPatternsStr = fmt.Sprintf("%v", c.SkipPatterns)
 	}
+	excludeFileStr := "(none)"
+	if c.ExcludeFile != "" {
+		excludeFileStr = c.ExcludeFile
+	}
+	outputDirStr := "(in place)"
+	if c.OutputDir != "" {
+		outputDirStr = c.OutputDir
+	}
 	return fmt.Sprintf(`Config:
   MaxDimension:    %d px
   JPEGQuality:     %d
+  QualityCurve:    %s
+  Grayscale:       %t
+  KeepLowQuality:  %t
+  KeepModern:      %.0f KB/MP
+  ComicInfoRules:  %d
+  SeriesRules:     %d
+  DeviceProfiles:  %d
   BackupDir:       %s
+  BackupMode:      %s
   ThresholdMBPage: %.2f MB
   SkipPatterns:    %s
+  Extensions:      %v
+  ExcludeFile:     %s
+  FormatPolicy:    %s
+  ProcessExts:     %s
+  MaxMegapixels:   %.0f MP
+  MaxDecodeMB:     %d MB
   Recursive:       %t
   Force:           %t
+  IgnoreMarker:    %t
   DryRun:          %t
   Verbose:         %t
-  Workers:         %d`,
+  Workers:         %d
+  MountLimits:     %s
+  TempBudgetMB:    %d MB
+  MaxInFlightMB:   %d MB
+  MaxFailures:     %d
+  RecentDays:      %d
+  Strict:          %t
+  Salvage:         %t
+  RenameToCBZ:     %s
+  OutputDir:       %s
+  OutputName:      %s
+  OnCollision:     %s
+  Pages:           %s
+  AutoLevels:      %t
+  Gamma:           %.2f
+  Deskew:          %t
+  ComposeAspect:   %.2f
+  Webtoon:         %t
+  Flatten:         %t
+  Split:           %t
+  JoinSplit:       %t
+  ZipLevel:        %d
+  ZipMethod:       %s`,
 		c.MaxDimension,
 		c.JPEGQuality,
+		c.QualityCurve,
+		c.Grayscale,
+		c.KeepLowQuality,
+		c.KeepModernKBPerMP,
+		len(c.ComicInfoRules),
+		len(c.SeriesRules),
+		len(c.DeviceProfiles),
 		c.BackupDir,
+		c.BackupMode,
 		c.ThresholdMBPage,
 		skipPatternsStr,
+		c.ArchiveExtensions,
+		excludeFileStr,
+		c.FormatPolicy,
+		c.ProcessExtensions,
+		c.MaxMegapixels,
+		c.MaxDecodeMB,
 		c.Recursive,
 		c.Force,
+		c.IgnoreMarker,
 		c.DryRun,
 		c.Verbose,
 		c.Workers,
+		c.MountLimits,
+		c.TempBudgetMB,
+		c.MaxInFlightMB,
+		c.MaxFailures,
+		c.RecentDays,
+		c.Strict,
+		c.Salvage,
+		c.RenameToCBZ,
+		outputDirStr,
+		c.OutputName,
+		c.OnCollision,
+		c.Pages,
+		c.AutoLevels,
+		c.Gamma,
+		c.Deskew,
+		c.ComposeAspect,
+		c.Webtoon,
+		c.Flatten,
+		c.Split,
+		c.JoinSplit,
+		c.ZipLevel,
+		c.ZipMethod,
 	)
 }
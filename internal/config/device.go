@@ -0,0 +1,42 @@
+package config
+
+import "fmt"
+
+// DeviceProfile is a reading device's screen in portrait orientation, for
+// reporting whether compressed pages fit it without further downscaling
+// (by the device, or by a server such as Komga or Kavita):
+//
+//	device_profiles:
+//	  - {name: Kindle Paperwhite, width: 1236, height: 1648}
+type DeviceProfile struct {
+	Name   string `yaml:"name"`
+	Width  int    `yaml:"width"`  // Short side in pixels
+	Height int    `yaml:"height"` // Long side in pixels
+}
+
+// DeviceProfiles is the device_profiles list
+type DeviceProfiles []DeviceProfile
+
+// Validate checks that every profile has a unique name and a resolution
+func (d DeviceProfiles) Validate() error {
+	seen := make(map[string]bool, len(d))
+	for i, profile := range d {
+		switch {
+		case profile.Name == "":
+			return fmt.Errorf("device_profiles[%d]: name is required", i)
+		case seen[profile.Name]:
+			return fmt.Errorf("device_profiles[%d]: duplicate name %q", i, profile.Name)
+		case profile.Width <= 0 || profile.Height <= 0:
+			return fmt.Errorf("device_profiles[%d] (%s): width and height must be positive", i, profile.Name)
+		}
+		seen[profile.Name] = true
+	}
+	return nil
+}
+
+// Fits reports whether a page whose sides are at most short x long pixels
+// fits the screen in its own orientation (spreads against the landscape
+// screen)
+func (p DeviceProfile) Fits(short, long int) bool {
+	return short <= min(p.Width, p.Height) && long <= max(p.Width, p.Height)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables LoadEnv reads
+const EnvPrefix = "CBZ_"
+
+// LoadEnv applies CBZ_<KEY> variables from environ (as os.Environ returns
+// it) over cfg, where KEY is a config file key in upper case:
+// CBZ_MAX_DIMENSION=1600 sets max_dimension. Values are YAML, so lists are
+// written as CBZ_ARCHIVE_EXTENSIONS='[.cbz, .zip]'. It is strict: a CBZ_
+// variable that is neither a config key nor in reserved (variables the
+// caller reads itself) is an error, as is a value of the wrong type.
+func LoadEnv(cfg *Config, environ []string, reserved map[string]bool) error {
+	known := yamlKeys()
+	var (
+		pairs   []*yaml.Node // One key: value mapping per variable
+		unknown []string
+	)
+
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) || reserved[name] {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(name, EnvPrefix))
+		if !known[key] {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(value), &node); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+		if len(node.Content) > 0 {
+			valueNode = node.Content[0]
+		}
+		pairs = append(pairs, &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: key}, valueNode,
+		}})
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown environment variables: %s", strings.Join(unknown, ", "))
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	// Decode one variable at a time so a type error names it
+	for _, pair := range pairs {
+		if err := pair.Decode(cfg); err != nil {
+			return fmt.Errorf("%s%s: %w", EnvPrefix, strings.ToUpper(pair.Content[0].Value), err)
+		}
+	}
+	return cfg.normalizeArchiveExtensions()
+}
+
+// yamlKeys returns the config file keys of Config
+func yamlKeys() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MountLimits caps how many archives are processed at once on a filesystem.
+// Keys are any path on it, usually the mount point; archives on the same
+// device share the limit, and filesystems not listed are unlimited:
+//
+//	mount_limits:
+//	  /mnt/nas: 2
+type MountLimits map[string]int
+
+// String lists the limits as PATH=N, or "none"
+func (m MountLimits) String() string {
+	if len(m) == 0 {
+		return "none"
+	}
+	specs := make([]string, 0, len(m))
+	for path, limit := range m {
+		specs = append(specs, fmt.Sprintf("%s=%d", path, limit))
+	}
+	sort.Strings(specs)
+	return strings.Join(specs, ", ")
+}
+
+// Validate checks that every path exists and every limit allows at least
+// one archive
+func (m MountLimits) Validate() error {
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if m[path] < 1 {
+			return fmt.Errorf("mount_limits: %s: limit must be at least 1", path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("mount_limits: %w", err)
+		}
+	}
+	return nil
+}
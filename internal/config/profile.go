@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileFlag names the flag that layers a profile over the config file
+const ProfileFlag = "config"
+
+// Profile holds the settings the calibrate command recommends
+type Profile struct {
+	MaxDimension    int     `yaml:"max_dimension"`
+	JPEGQuality     int     `yaml:"jpeg_quality"`
+	ThresholdMBPage float64 `yaml:"threshold_mb_per_page"`
+}
+
+// ProfileArg returns the value of -config in args ("" if absent). Profiles
+// set flag defaults, so they must be found before the flags are parsed.
+func ProfileArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if value, ok := strings.CutPrefix(name, ProfileFlag+"="); ok {
+			return value
+		}
+		if name == ProfileFlag && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// LoadProfile overlays the YAML settings in path onto cfg. Any config file
+// key may appear; keys not in the profile keep their value.
+func LoadProfile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse profile %s: %w", path, err)
+	}
+	if err := cfg.normalizeArchiveExtensions(); err != nil {
+		return fmt.Errorf("invalid profile %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteProfile writes p to path as YAML, preceded by comment lines
+func WriteProfile(path string, p Profile, comment []string) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	for _, line := range comment {
+		sb.WriteString("# " + line + "\n")
+	}
+	if len(comment) > 0 {
+		sb.WriteString("\n")
+	}
+	sb.Write(data)
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write profile: %w", err)
+	}
+	return nil
+}
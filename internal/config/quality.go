@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// QualityCurve adjusts JPEG quality by how far a page is scaled down. Keys
+// are scale factors (output long edge / source long edge, 1 = not resized),
+// values are added to the configured quality. Between keys the adjustment
+// is interpolated linearly; beyond the outermost keys it stays constant.
+//
+// Pages scaled down hard have already lost detail to resampling and show
+// artifacts more, while pages barely resized keep their grain and hide them:
+//
+//	quality_curve: {0.4: 3, 0.7: 0, 1.0: -3}
+type QualityCurve map[float64]int
+
+// Validate checks that scales are in (0, 1] and adjustments in [-99, 99]
+func (c QualityCurve) Validate() error {
+	for scale, delta := range c {
+		if scale <= 0 || scale > 1 {
+			return fmt.Errorf("quality_curve: scale %g must be in (0, 1]", scale)
+		}
+		if delta < -99 || delta > 99 {
+			return fmt.Errorf("quality_curve: adjustment %d for scale %g must be between -99 and 99", delta, scale)
+		}
+	}
+	return nil
+}
+
+// Quality returns base adjusted for a page scaled by scale, within 1-100
+func (c QualityCurve) Quality(scale float64, base int) int {
+	if len(c) == 0 {
+		return base
+	}
+
+	scales := c.scales()
+	var delta float64
+	i := sort.SearchFloat64s(scales, scale)
+	switch {
+	case i == 0:
+		delta = float64(c[scales[0]])
+	case i == len(scales):
+		delta = float64(c[scales[len(scales)-1]])
+	default:
+		lo, hi := scales[i-1], scales[i]
+		t := (scale - lo) / (hi - lo)
+		delta = float64(c[lo]) + t*float64(c[hi]-c[lo])
+	}
+
+	return max(1, min(100, base+int(math.Round(delta))))
+}
+
+// String lists the points in scale order, e.g. "0.4:+3 1:-3"
+func (c QualityCurve) String() string {
+	if len(c) == 0 {
+		return "(none)"
+	}
+	scales := c.scales()
+	points := make([]string, len(scales))
+	for i, s := range scales {
+		points[i] = fmt.Sprintf("%g:%+d", s, c[s])
+	}
+	return strings.Join(points, " ")
+}
+
+// scales returns the curve's scale factors in ascending order
+func (c QualityCurve) scales() []float64 {
+	scales := make([]float64, 0, len(c))
+	for s := range c {
+		scales = append(scales, s)
+	}
+	sort.Float64s(scales)
+	return scales
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SeriesRule applies settings to archives under directories whose name
+// matches a glob, so one run over a whole library honors per-series
+// preferences:
+//
+//	series_rules:
+//	  "*One Piece*": {max_dimension: 1440}
+//	  "*Artbooks*": skip
+//
+// Directories above the input directory don't count, so a pattern can't
+// match every archive through the library's own path. Rules are applied in
+// the order written, later matches overriding earlier ones; comicinfo_rules
+// and override files take precedence over all of them.
+type SeriesRule struct {
+	Pattern string `yaml:"-"` // Glob on a directory name, ignoring case
+
+	Skip            bool    `yaml:"skip"`                  // Never process matching archives
+	Reason          string  `yaml:"reason"`                // Skip reason (default: the pattern)
+	MaxDimension    int     `yaml:"max_dimension"`         // Maximum dimension in pixels
+	JPEGQuality     int     `yaml:"jpeg_quality"`          // JPEG quality 1-100
+	ThresholdMBPage float64 `yaml:"threshold_mb_per_page"` // MB per page threshold for skip heuristic
+	Grayscale       bool    `yaml:"grayscale"`             // Re-encode pages as grayscale
+}
+
+// SeriesRules is the series_rules mapping, in the order written
+type SeriesRules []SeriesRule
+
+// UnmarshalYAML reads the mapping of pattern to settings, keeping its
+// order. A rule's settings may be the word skip instead of a mapping.
+func (r *SeriesRules) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("series_rules: line %d: expected a mapping of directory pattern to settings", node.Line)
+	}
+	rules := make(SeriesRules, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		rule := SeriesRule{Pattern: key.Value}
+		switch {
+		case value.Kind == yaml.ScalarNode && value.Value == "skip":
+			rule.Skip = true
+		case value.Kind == yaml.MappingNode:
+			if err := value.Decode(&rule); err != nil {
+				return fmt.Errorf("series_rules: %s: %w", key.Value, err)
+			}
+		default:
+			return fmt.Errorf("series_rules: %s: line %d: expected settings or skip", key.Value, value.Line)
+		}
+		rules = append(rules, rule)
+	}
+	*r = rules
+	return nil
+}
+
+// Validate checks that every pattern is a valid glob and every rule has valid settings
+func (r SeriesRules) Validate() error {
+	for _, rule := range r {
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+			return fmt.Errorf("series_rules: invalid directory pattern %q", rule.Pattern)
+		}
+		switch {
+		case rule.JPEGQuality != 0 && (rule.JPEGQuality < 1 || rule.JPEGQuality > 100):
+			return fmt.Errorf("series_rules: %s: jpeg_quality must be between 1 and 100", rule.Pattern)
+		case rule.MaxDimension < 0:
+			return fmt.Errorf("series_rules: %s: max_dimension cannot be negative", rule.Pattern)
+		case rule.ThresholdMBPage < 0:
+			return fmt.Errorf("series_rules: %s: threshold_mb_per_page cannot be negative", rule.Pattern)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a directory the archive at cbzPath is in matches
+// the rule's pattern: its own, and those above it up to and including root,
+// the input directory it was found under ("" for the archive's own only)
+func (rule SeriesRule) Matches(cbzPath, root string) bool {
+	dir := absDir(filepath.Dir(cbzPath))
+	if root != "" {
+		root = absDir(root)
+	}
+	pattern := strings.ToLower(rule.Pattern)
+	for {
+		if ok, _ := filepath.Match(pattern, strings.ToLower(filepath.Base(dir))); ok {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if root == "" || dir == root || parent == dir || !strings.HasPrefix(dir, root) {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// absDir returns dir made absolute, or cleaned if it can't be
+func absDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return filepath.Clean(dir)
+}
@@ -0,0 +1,224 @@
+// Package fileclass classifies archive entries: which are pages and in
+// what format, and which are OS junk to drop. Every package that looks
+// inside archives goes through it, so a format or junk pattern is added in
+// one place.
+package fileclass
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Format describes a page format for RegisterFormat
+type Format struct {
+	Name         string   // Name used in format_policy, e.g. "jxl"
+	Extensions   []string // Entry extensions with the dot, e.g. ".jxl"
+	Magic        string   // Leading bytes identifying the format ("?" matches any byte); needed with Decode
+	Decode       func(io.Reader) (image.Image, error)
+	DecodeConfig func(io.Reader) (image.Config, error) // Header only; the analyzer reads nothing else
+}
+
+// formatsMu guards formatByExtension against registrations during a run
+var formatsMu sync.RWMutex
+
+// formatByExtension maps page extensions to the format names used in
+// format_policy. It is the one list of page formats: the reader, analyzer,
+// lint and salvage all go through FormatOf or IsImage.
+var formatByExtension = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".jpe":  "jpeg",
+	".jfif": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+	".webp": "webp",
+	".bmp":  "bmp",
+	".tif":  "tiff",
+	".tiff": "tiff",
+	".jp2":  "jp2",
+	".j2k":  "jp2",
+	".heic": "heif",
+	".heif": "heif",
+	".avif": "avif",
+}
+
+// formatExtension is the extension a misnamed page gets once its format
+// is sniffed (see PageName)
+var formatExtension = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+	"webp": ".webp",
+	"bmp":  ".bmp",
+	"tiff": ".tif",
+	"jp2":  ".jp2",
+	"heif": ".heic",
+	"avif": ".avif",
+}
+
+// formatMagic lists the leading bytes of each format, "?" matching any
+// byte. ISO media files (HEIF, AVIF) are told apart by their brand.
+var formatMagic = []struct{ format, magic string }{
+	{"jpeg", "\xff\xd8\xff"},
+	{"png", "\x89PNG\r\n\x1a\n"},
+	{"gif", "GIF87a"},
+	{"gif", "GIF89a"},
+	{"webp", "RIFF????WEBP"},
+	{"bmp", "BM????\x00\x00\x00\x00"}, // Reserved fields, so text starting "BM" isn't taken for one
+	{"tiff", "II*\x00"},
+	{"tiff", "MM\x00*"},
+	{"jp2", "\x00\x00\x00\x0cjP  \r\n\x87\n"},
+	{"jp2", "\xff\x4f\xff\x51"},
+	{"avif", "????ftypavif"},
+	{"avif", "????ftypavis"},
+	{"heif", "????ftypheic"},
+	{"heif", "????ftypheix"},
+	{"heif", "????ftypmif1"},
+}
+
+// SniffLen is how much of an entry Sniff looks at
+const SniffLen = 16
+
+// modernFormats are page formats from codecs that compress better than
+// JPEG; re-encoding a well-compressed page in one of them as JPEG makes it
+// bigger and worse
+var modernFormats = map[string]bool{
+	"webp": true,
+	"avif": true,
+}
+
+// FormatOf returns the source format of a page by extension ("" if unsupported)
+func FormatOf(name string) string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return formatByExtension[strings.ToLower(filepath.Ext(name))]
+}
+
+// IsModern reports whether a page is in one of the modern formats
+func IsModern(name string) bool {
+	return modernFormats[FormatOf(name)]
+}
+
+// IsImage reports whether an entry is a page in a known format
+func IsImage(name string) bool {
+	return FormatOf(name) != ""
+}
+
+// Sniff returns the format of a page from its leading bytes ("" if none
+// matches). Only the first SniffLen bytes are looked at.
+func Sniff(data []byte) string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, m := range formatMagic {
+		if matchMagic(data, m.magic) {
+			return m.format
+		}
+	}
+	return ""
+}
+
+// matchMagic reports whether data starts with magic, "?" matching any byte
+func matchMagic(data []byte, magic string) bool {
+	if len(data) < len(magic) {
+		return false
+	}
+	for i := range len(magic) {
+		if magic[i] != '?' && magic[i] != data[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// PageName returns the name an entry is handled under as a page, or "" if
+// it isn't one. Pages are told by their content, head being the entry's
+// first bytes, so pages without an extension, with a non-page one (001.dat,
+// 001.tmp) or with the wrong one (a JPEG named .png) are recognized and get
+// the extension of their format, which readers that go by extension need.
+// Entries with a page extension whose content isn't recognized keep their
+// name (the decoder gets the last word); others aren't pages.
+func PageName(name string, head []byte) string {
+	format := Sniff(head)
+	named := FormatOf(name)
+	switch {
+	case format == "":
+		if named != "" {
+			return name
+		}
+		return ""
+	case format == named, isoMedia[format] && isoMedia[named]:
+		return name
+	}
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return strings.TrimSuffix(name, path.Ext(name)) + formatExtension[format]
+}
+
+// isoMedia are the formats in ISO media files, whose brands overlap (AVIF
+// files may carry only the HEIF brand), so sniffing doesn't rename between
+// them
+var isoMedia = map[string]bool{
+	"heif": true,
+	"avif": true,
+}
+
+// RegisterFormat adds a page format, typically from an init function of a
+// program using the library. Entries with its extensions then count as
+// pages everywhere, format_policy accepts its name, and with Decode set its
+// pages decode through image.Decode like the built-in formats (without it,
+// the decoder must be registered with the image package separately).
+// Extensions can't be taken from another format.
+func RegisterFormat(f Format) error {
+	if f.Name == "" || len(f.Extensions) == 0 {
+		return fmt.Errorf("format needs a name and at least one extension")
+	}
+	if f.Decode != nil && (f.Magic == "" || f.DecodeConfig == nil) {
+		return fmt.Errorf("format %s: Decode needs Magic and DecodeConfig", f.Name)
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for _, ext := range f.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("format %s: extension %q must start with a dot", f.Name, ext)
+		}
+		if owner, ok := formatByExtension[strings.ToLower(ext)]; ok && owner != f.Name {
+			return fmt.Errorf("format %s: extension %s already belongs to %s", f.Name, ext, owner)
+		}
+	}
+	for _, ext := range f.Extensions {
+		formatByExtension[strings.ToLower(ext)] = f.Name
+	}
+	if _, ok := formatExtension[f.Name]; !ok {
+		formatExtension[f.Name] = strings.ToLower(f.Extensions[0])
+	}
+	if f.Magic != "" && len(f.Magic) <= SniffLen {
+		formatMagic = append(formatMagic, struct{ format, magic string }{f.Name, f.Magic})
+	}
+	if f.Decode != nil {
+		image.RegisterFormat(f.Name, f.Magic, f.Decode, f.DecodeConfig)
+	}
+	return nil
+}
+
+// Names lists the known format names, sorted
+func Names() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	seen := make(map[string]bool)
+	var names []string
+	for _, format := range formatByExtension {
+		if !seen[format] {
+			seen[format] = true
+			names = append(names, format)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
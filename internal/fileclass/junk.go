@@ -0,0 +1,71 @@
+package fileclass
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// OverrideFileName is the per-archive settings file (see internal/override),
+// kept although it is hidden
+const OverrideFileName = ".cbz-compress.override.yaml"
+
+// JunkRules say which archive entries are OS junk: neither pages nor
+// anything worth carrying into the compressed archive
+type JunkRules struct {
+	Patterns []string // Globs matched against the entry's base name (path.Match syntax)
+	Dirs     []string // Folders whose whole contents are junk, at any depth
+	Keep     []string // Entries kept even when a pattern matches
+}
+
+// DefaultJunkRules drop hidden files (including macOS "._" resource forks)
+// and macOS __MACOSX folders, but keep per-archive override settings
+var DefaultJunkRules = JunkRules{
+	Patterns: []string{".*"},
+	Dirs:     []string{"__MACOSX"},
+	Keep:     []string{OverrideFileName},
+}
+
+var (
+	junkMu sync.RWMutex
+	junk   = DefaultJunkRules
+)
+
+// AddJunkPattern adds a base-name glob to the junk rules, e.g. "Thumbs.db"
+func AddJunkPattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid junk pattern %q: %w", pattern, err)
+	}
+	junkMu.Lock()
+	defer junkMu.Unlock()
+	junk.Patterns = append(append([]string{}, junk.Patterns...), pattern)
+	return nil
+}
+
+// IsJunk reports whether an archive entry is OS junk under the current
+// rules. Names may use either slash; some Windows archivers write
+// backslashes.
+func IsJunk(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	base := path.Base(name)
+
+	junkMu.RLock()
+	defer junkMu.RUnlock()
+	for _, keep := range junk.Keep {
+		if name == keep {
+			return false
+		}
+	}
+	for _, dir := range junk.Dirs {
+		if strings.HasPrefix(name, dir+"/") || strings.Contains(name, "/"+dir+"/") {
+			return true
+		}
+	}
+	for _, pattern := range junk.Patterns {
+		if matched, _ := path.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+//go:build !unix && !windows
+
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DeviceOf is the volume name where devices can't be told apart
+func DeviceOf(path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.VolumeName(abs), nil
+}
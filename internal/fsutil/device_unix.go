@@ -0,0 +1,22 @@
+//go:build unix
+
+package fsutil
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// DeviceOf identifies the filesystem path is on; paths with the same
+// result share a device (and a mount)
+func DeviceOf(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return strconv.FormatUint(uint64(st.Dev), 10), nil
+	}
+	return "", nil
+}
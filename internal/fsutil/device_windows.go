@@ -0,0 +1,32 @@
+//go:build windows
+
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// DeviceOf identifies the filesystem path is on by its volume mount point
+// (a drive letter, a mounted folder or a \\server\share root)
+func DeviceOf(path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	name, err := windows.UTF16PtrFromString(abs)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	if err := windows.GetVolumePathName(name, &buf[0], uint32(len(buf))); err != nil {
+		return strings.ToLower(filepath.VolumeName(abs)), nil
+	}
+	return strings.ToLower(windows.UTF16ToString(buf)), nil
+}
@@ -0,0 +1,29 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// CopyMetadata applies the permission bits, ownership and (optionally) the
+// modification time recorded in info to path. Ownership changes that need
+// privileges we don't have are skipped silently: only root can give a file away.
+func CopyMetadata(info os.FileInfo, path string, preserveMTime bool) error {
+	if err := os.Chmod(path, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if err := copyOwner(info, path); err != nil {
+		return fmt.Errorf("failed to set owner: %w", err)
+	}
+
+	if preserveMTime {
+		// Zero access time leaves it unchanged
+		if err := os.Chtimes(path, time.Time{}, info.ModTime()); err != nil {
+			return fmt.Errorf("failed to set modification time: %w", err)
+		}
+	}
+
+	return nil
+}
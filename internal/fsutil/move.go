@@ -0,0 +1,90 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Move renames src to dst, falling back to copy-and-delete when they live on
+// different volumes. The fallback copies into a temp file in dst's directory,
+// syncs it, and renames it into place, so dst is never observed half-written.
+func Move(src, dst string) error {
+	err := Rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	tempPath := dst + ".move.tmp"
+	if err := copyFile(src, tempPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to copy %s across volumes: %w", src, err)
+	}
+
+	if err := Rename(tempPath, dst); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to move %s into place: %w", filepath.Base(dst), err)
+	}
+
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("moved %s but failed to remove source: %w", src, err)
+	}
+
+	return nil
+}
+
+// Copy copies src to dst via a synced temp file in dst's directory, so dst
+// either keeps its old content or has the complete new content
+func Copy(src, dst string) error {
+	tempPath := dst + ".copy.tmp"
+	if err := copyFile(src, tempPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := Rename(tempPath, dst); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// copyFile copies src to dst and fsyncs dst before returning
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// isCrossDevice reports whether a rename failed because src and dst are on different volumes
+func isCrossDevice(err error) bool {
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return false
+	}
+	return errors.Is(linkErr.Err, syscall.EXDEV) || isCrossDevicePlatform(linkErr.Err)
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package fsutil
+
+func isCrossDevicePlatform(err error) bool {
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package fsutil
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE, returned by MoveFile across volumes
+const errorNotSameDevice = syscall.Errno(17)
+
+func isCrossDevicePlatform(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
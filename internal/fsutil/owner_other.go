@@ -0,0 +1,10 @@
+//go:build !unix
+
+package fsutil
+
+import "os"
+
+// copyOwner is a no-op where files have no uid/gid (Windows keeps ACLs on replace)
+func copyOwner(info os.FileInfo, path string) error {
+	return nil
+}
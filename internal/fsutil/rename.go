@@ -0,0 +1,57 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Retry schedule for transiently locked files: 7 attempts, waiting 50ms
+// doubling to 1.6s (about 3 seconds in all)
+const (
+	renameAttempts = 7
+	renameBackoff  = 50 * time.Millisecond
+)
+
+// Rename is os.Rename, retried with backoff while the file is transiently
+// locked. On Windows, antivirus scanners and the search indexer briefly
+// open new files, and renames then fail with sharing violations; elsewhere
+// it is a single attempt.
+func Rename(src, dst string) error {
+	return retry(func() error { return os.Rename(src, dst) })
+}
+
+// Replace puts replacement at target and the old target at backup. On
+// Windows this is one ReplaceFile call, which gives replacement the
+// target's attributes and ACLs (see ReplacePreservesAttributes); elsewhere,
+// and across volumes, it is two moves, undone if the second fails.
+func Replace(target, replacement, backup string) error {
+	if err := retry(func() error { return replaceFile(target, replacement, backup) }); !isUnsupportedReplace(err) {
+		return err
+	}
+
+	if err := Move(target, backup); err != nil {
+		return err
+	}
+	if err := Rename(replacement, target); err != nil {
+		if restoreErr := Move(backup, target); restoreErr != nil {
+			return fmt.Errorf("CRITICAL: replace failed and restore failed: %w (restore: %v)", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// retry runs op until it succeeds, fails with an error that isn't
+// transient, or runs out of attempts
+func retry(op func() error) error {
+	wait := renameBackoff
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt == renameAttempts || !isTransient(err) {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package fsutil
+
+import "errors"
+
+// ReplacePreservesAttributes reports whether Replace keeps the replaced
+// file's attributes and ACLs by itself (callers copy them otherwise)
+const ReplacePreservesAttributes = false
+
+// isTransient reports whether a rename error may go away on retry
+func isTransient(err error) bool {
+	return false
+}
+
+func replaceFile(target, replacement, backup string) error {
+	return errors.ErrUnsupported
+}
+
+func isUnsupportedReplace(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported)
+}
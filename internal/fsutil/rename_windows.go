@@ -0,0 +1,83 @@
+//go:build windows
+
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ReplacePreservesAttributes reports whether Replace keeps the replaced
+// file's attributes and ACLs by itself (callers copy them otherwise)
+const ReplacePreservesAttributes = true
+
+// Errors from renames of files another process holds open
+const (
+	errorAccessDenied     = syscall.Errno(5)
+	errorSharingViolation = syscall.Errno(32)
+	errorLockViolation    = syscall.Errno(33)
+)
+
+// ReplaceFile failures (winerror.h)
+const (
+	errorUnableToMoveReplacement  = syscall.Errno(1176) // Nothing moved
+	errorUnableToMoveReplacement2 = syscall.Errno(1177) // Target moved to backup, replacement not moved
+	errorUnableToRemoveReplaced   = syscall.Errno(1175) // Nothing moved
+)
+
+// replaceFileIgnoreMergeErrors is REPLACEFILE_IGNORE_MERGE_ERRORS: attributes
+// that can't be carried over don't fail the replacement
+const replaceFileIgnoreMergeErrors = 0x2
+
+var procReplaceFileW = syscall.NewLazyDLL("kernel32.dll").NewProc("ReplaceFileW")
+
+// isTransient reports whether a rename error may go away on retry: the
+// file is open in another process, typically an antivirus scanner or the
+// search indexer
+func isTransient(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation) ||
+		errors.Is(err, errorAccessDenied) || errors.Is(err, errorUnableToMoveReplacement) ||
+		errors.Is(err, errorUnableToRemoveReplaced)
+}
+
+// replaceFile calls ReplaceFileW. If the target was moved to backup but the
+// replacement could not take its place, the target is moved back.
+func replaceFile(target, replacement, backup string) error {
+	targetPtr, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	replacementPtr, err := syscall.UTF16PtrFromString(replacement)
+	if err != nil {
+		return err
+	}
+	backupPtr, err := syscall.UTF16PtrFromString(backup)
+	if err != nil {
+		return err
+	}
+
+	ok, _, callErr := procReplaceFileW.Call(
+		uintptr(unsafe.Pointer(targetPtr)),
+		uintptr(unsafe.Pointer(replacementPtr)),
+		uintptr(unsafe.Pointer(backupPtr)),
+		replaceFileIgnoreMergeErrors, 0, 0)
+	if ok != 0 {
+		return nil
+	}
+
+	if errors.Is(callErr, errorUnableToMoveReplacement2) {
+		if restoreErr := os.Rename(backup, target); restoreErr != nil {
+			return fmt.Errorf("CRITICAL: replace failed and restore failed: %w (restore: %v)", callErr, restoreErr)
+		}
+	}
+	return &os.LinkError{Op: "replace", Old: replacement, New: target, Err: callErr}
+}
+
+// isUnsupportedReplace reports whether Replace must fall back to two moves:
+// ReplaceFile cannot move the target to a backup on another volume
+func isUnsupportedReplace(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package fsutil
+
+import "os"
+
+// SyncDir fsyncs a directory so a preceding create or rename inside it survives power loss
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
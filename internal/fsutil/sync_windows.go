@@ -0,0 +1,9 @@
+//go:build windows
+
+package fsutil
+
+// SyncDir is a no-op on Windows: directories cannot be opened for fsync and
+// NTFS journals metadata updates such as renames
+func SyncDir(dir string) error {
+	return nil
+}
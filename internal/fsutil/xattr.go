@@ -0,0 +1,5 @@
+package fsutil
+
+// Xattrs holds a file's extended attributes (Finder tags, Linux user.* attributes,
+// or the Windows Zone.Identifier stream), keyed by attribute name
+type Xattrs map[string][]byte
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !windows
+
+package fsutil
+
+// ReadXattrs returns no attributes on platforms without xattr support
+func ReadXattrs(path string) (Xattrs, error) {
+	return Xattrs{}, nil
+}
+
+// WriteXattrs is a no-op on platforms without xattr support
+func WriteXattrs(path string, attrs Xattrs) error {
+	return nil
+}
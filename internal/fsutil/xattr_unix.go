@@ -0,0 +1,80 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package fsutil
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReadXattrs returns all extended attributes of path. Filesystems without
+// xattr support yield an empty set rather than an error.
+func ReadXattrs(path string) (Xattrs, error) {
+	names, err := listXattrs(path)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			return Xattrs{}, nil
+		}
+		return nil, err
+	}
+
+	attrs := make(Xattrs, len(names))
+	for _, name := range names {
+		value, err := getXattr(path, name)
+		if err != nil {
+			// Attribute vanished or is unreadable (e.g. security.* as non-root)
+			continue
+		}
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+// WriteXattrs sets attrs on path. Attributes the filesystem or our privileges
+// don't allow (trusted.*, security.* as non-root) are skipped.
+func WriteXattrs(path string, attrs Xattrs) error {
+	for name, value := range attrs {
+		err := unix.Setxattr(path, name, value, 0)
+		if err != nil && !errors.Is(err, unix.EPERM) && !errors.Is(err, unix.ENOTSUP) && !errors.Is(err, unix.EACCES) {
+			return err
+		}
+	}
+	return nil
+}
+
+func listXattrs(path string) ([]string, error) {
+	size, err := unix.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	size, err := unix.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Getxattr(path, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
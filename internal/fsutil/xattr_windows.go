@@ -0,0 +1,33 @@
+//go:build windows
+
+package fsutil
+
+import (
+	"errors"
+	"os"
+)
+
+// zoneIdentifier is the alternate data stream holding "downloaded from the internet" information
+const zoneIdentifier = "Zone.Identifier"
+
+// ReadXattrs returns the alternate data streams we preserve (currently Zone.Identifier)
+func ReadXattrs(path string) (Xattrs, error) {
+	data, err := os.ReadFile(path + ":" + zoneIdentifier)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Xattrs{}, nil
+		}
+		return nil, err
+	}
+	return Xattrs{zoneIdentifier: data}, nil
+}
+
+// WriteXattrs writes the preserved alternate data streams to path
+func WriteXattrs(path string, attrs Xattrs) error {
+	for name, value := range attrs {
+		if err := os.WriteFile(path+":"+name, value, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
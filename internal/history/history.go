@@ -0,0 +1,112 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"compress_comics/internal/processor"
+)
+
+// FileName is the history database's name inside the backup directory
+const FileName = "history.jsonl"
+
+// Entry records one successfully compressed archive
+type Entry struct {
+	Time           time.Time `json:"time"`
+	Path           string    `json:"path"`
+	Series         string    `json:"series"` // Parent directory
+	OriginalSize   int64     `json:"original_size"`
+	CompressedSize int64     `json:"compressed_size"`
+	MaxDimension   int       `json:"max_dimension"`
+	JPEGQuality    int       `json:"jpeg_quality"`
+}
+
+// Saved returns the bytes reclaimed by this entry
+func (e Entry) Saved() int64 {
+	return e.OriginalSize - e.CompressedSize
+}
+
+// DefaultPath returns the history path for a backup directory
+func DefaultPath(backupDir string) string {
+	return filepath.Join(backupDir, FileName)
+}
+
+// FromBatch returns an entry for every archive the batch compressed in
+// place. Copies (-output-dir, -pages) are left out: the original keeps its
+// space, and a page subset would show as savings it never made.
+func FromBatch(batch *processor.BatchResult, maxDimension, jpegQuality int) []Entry {
+	now := time.Now()
+	var entries []Entry
+	for _, result := range batch.Results {
+		if result.Skipped || result.OutputPath == "" || result.Copy {
+			continue
+		}
+		path := result.SourcePath
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		entries = append(entries, Entry{
+			Time:           now,
+			Path:           path,
+			Series:         filepath.Dir(path),
+			OriginalSize:   result.OriginalSize,
+			CompressedSize: result.CompressedSize,
+			MaxDimension:   maxDimension,
+			JPEGQuality:    jpegQuality,
+		})
+	}
+	return entries
+}
+
+// Append adds entries to the history file, creating it if needed
+func Append(path string, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history dir: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// Load reads all entries from a history file. A missing file has no entries;
+// unreadable lines are ignored.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,129 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"compress_comics/internal/config"
+	"compress_comics/internal/processor"
+)
+
+// SessionDir is the directory inside the backup directory that holds one
+// session file per run
+const SessionDir = "sessions"
+
+// Session records one run, so a backup file can be traced back to the
+// archive and settings it came from long after the run
+type Session struct {
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Args     []string      `json:"args"`     // Command line, without the program name
+	Settings config.Config `json:"settings"` // Effective configuration
+	Files    []SessionFile `json:"files"`
+	// Originals backed up alongside an archive rather than for it, such as
+	// the .z01, .z02, ... parts of a split set: original -> backup
+	OtherBackups map[string]string `json:"other_backups,omitempty"`
+}
+
+// SessionFile is one archive of the run
+type SessionFile struct {
+	Path           string   `json:"path"`
+	Status         string   `json:"status"` // processed, skipped or failed
+	Reason         string   `json:"reason,omitempty"`
+	OriginalSize   int64    `json:"original_size"`
+	CompressedSize int64    `json:"compressed_size,omitempty"`
+	Output         string   `json:"output,omitempty"` // Where the compressed archive went, when not in place
+	Parts          []string `json:"parts,omitempty"`  // Per-chapter archives with -split
+	Backup         string   `json:"backup,omitempty"` // Where the original is now
+}
+
+// NewSession describes a finished batch; backups maps each replaced
+// original to its backup (see processor.Pipeline.Backups) and is consumed
+func NewSession(batch *processor.BatchResult, cfg config.Config, args []string, backups map[string]string) *Session {
+	finished := time.Now()
+	s := &Session{
+		Started:  finished.Add(-batch.TotalDuration),
+		Finished: finished,
+		Args:     args,
+		Settings: cfg,
+		Files:    make([]SessionFile, 0, len(batch.Results)),
+	}
+	for _, result := range batch.Results {
+		file := SessionFile{
+			Path:           absPath(result.SourcePath),
+			Status:         "processed",
+			OriginalSize:   result.OriginalSize,
+			CompressedSize: result.CompressedSize,
+			Parts:          result.Parts,
+		}
+		switch {
+		case len(result.Errors) > 0 && result.OutputPath == "":
+			file.Status, file.Reason = "failed", result.Errors[0].Error()
+		case result.Skipped:
+			file.Status, file.Reason = "skipped", result.SkipReason
+		}
+		if result.OutputPath != "" && absPath(result.OutputPath) != file.Path {
+			file.Output = absPath(result.OutputPath)
+		}
+		if backup, ok := backups[result.SourcePath]; ok {
+			file.Backup = absPath(backup)
+			delete(backups, result.SourcePath)
+		}
+		s.Files = append(s.Files, file)
+	}
+	for original, backup := range backups {
+		if s.OtherBackups == nil {
+			s.OtherBackups = make(map[string]string)
+		}
+		s.OtherBackups[absPath(original)] = absPath(backup)
+	}
+	return s
+}
+
+// WriteSession writes s into the sessions directory of backupDir, named by
+// the time the run finished, and returns its path
+func WriteSession(backupDir string, s *Session) (string, error) {
+	dir := filepath.Join(backupDir, SessionDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create session dir: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	stem := filepath.Join(dir, s.Finished.Format("2006-01-02T150405"))
+	for n := 1; ; n++ {
+		path := stem + ".json"
+		if n > 1 {
+			path = fmt.Sprintf("%s-%d.json", stem, n)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create session file: %w", err)
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write session file: %w", err)
+		}
+		return path, nil
+	}
+}
+
+// absPath returns an absolute path, falling back to path as given
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,86 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Bucket aggregates entries sharing a key (month, settings or series)
+type Bucket struct {
+	Key            string
+	Files          int
+	OriginalSize   int64
+	CompressedSize int64
+}
+
+// Saved returns the bytes reclaimed in this bucket
+func (b Bucket) Saved() int64 {
+	return b.OriginalSize - b.CompressedSize
+}
+
+// SavingsPercent returns the share of original bytes reclaimed
+func (b Bucket) SavingsPercent() float64 {
+	if b.OriginalSize == 0 {
+		return 0
+	}
+	return float64(b.Saved()) / float64(b.OriginalSize) * 100
+}
+
+// Stats summarizes a history
+type Stats struct {
+	Total      Bucket
+	ByMonth    []Bucket // Chronological
+	BySettings []Bucket // Ordered by savings %, best first
+	BySeries   []Bucket // Ordered by bytes saved, most first
+}
+
+// Summarize aggregates entries into totals and per-month, per-settings and per-series buckets
+func Summarize(entries []Entry) Stats {
+	stats := Stats{Total: Bucket{Key: "total"}}
+	months := make(map[string]*Bucket)
+	settings := make(map[string]*Bucket)
+	series := make(map[string]*Bucket)
+
+	for _, e := range entries {
+		stats.Total.add(e)
+		addTo(months, e.Time.Local().Format("2006-01"), e)
+		addTo(settings, fmt.Sprintf("max-dim %d, quality %d", e.MaxDimension, e.JPEGQuality), e)
+		addTo(series, e.Series, e)
+	}
+
+	stats.ByMonth = sortedBuckets(months, func(a, b Bucket) bool { return a.Key < b.Key })
+	stats.BySettings = sortedBuckets(settings, func(a, b Bucket) bool {
+		return a.SavingsPercent() > b.SavingsPercent()
+	})
+	stats.BySeries = sortedBuckets(series, func(a, b Bucket) bool {
+		if a.Saved() != b.Saved() {
+			return a.Saved() > b.Saved()
+		}
+		return a.Key < b.Key
+	})
+	return stats
+}
+
+func (b *Bucket) add(e Entry) {
+	b.Files++
+	b.OriginalSize += e.OriginalSize
+	b.CompressedSize += e.CompressedSize
+}
+
+func addTo(buckets map[string]*Bucket, key string, e Entry) {
+	b, ok := buckets[key]
+	if !ok {
+		b = &Bucket{Key: key}
+		buckets[key] = b
+	}
+	b.add(e)
+}
+
+func sortedBuckets(buckets map[string]*Bucket, less func(a, b Bucket) bool) []Bucket {
+	out := make([]Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return less(out[i], out[j]) })
+	return out
+}
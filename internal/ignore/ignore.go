@@ -0,0 +1,158 @@
+// Package ignore excludes archives from processing with gitignore-style
+// pattern files: .cbzignore files anywhere in the library, plus an optional
+// global exclude file.
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the per-directory exclusion file, read while walking a library
+const FileName = ".cbzignore"
+
+// rule is one pattern line, relative to the directory of the file it came from
+type rule struct {
+	base     string // Directory the pattern is relative to
+	pattern  string // Slash-separated glob, without leading "/" or trailing "/"
+	negate   bool   // "!pattern" re-includes a path an earlier rule excluded
+	dirOnly  bool   // "pattern/" matches directories only
+	anchored bool   // Pattern contains a "/": matched against the path from base, not the name
+}
+
+// Matcher holds the rules collected so far. Later rules take precedence, so
+// load files from the root downwards (as a directory walk does).
+type Matcher struct {
+	rules []rule
+}
+
+// New returns a matcher with the rules of excludeFile ("" for none), whose
+// patterns are relative to root
+func New(root, excludeFile string) (*Matcher, error) {
+	m := &Matcher{}
+	if excludeFile == "" {
+		return m, nil
+	}
+	if err := m.loadFile(excludeFile, root); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("exclude file %s not found", excludeFile)
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// LoadDir adds the rules of dir's .cbzignore, if it has one
+func (m *Matcher) LoadDir(dir string) error {
+	err := m.loadFile(filepath.Join(dir, FileName), dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (m *Matcher) loadFile(file, base string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseRule(scanner.Text(), base); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return nil
+}
+
+// parseRule parses one gitignore line; blank lines and comments yield no rule
+func parseRule(line, base string) (rule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	r := rule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // "\#name" and "\!name" are literal
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+	r.pattern = line
+	return r, true
+}
+
+// Excluded reports whether path (a file or, with isDir, a directory) is
+// excluded. The last matching rule decides.
+func (m *Matcher) Excluded(p string, isDir bool) bool {
+	excluded := false
+	for _, r := range m.rules {
+		if r.match(p, isDir) {
+			excluded = !r.negate
+		}
+	}
+	return excluded
+}
+
+func (r rule) match(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, p)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// stands for any number of segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
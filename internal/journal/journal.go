@@ -0,0 +1,217 @@
+package journal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"compress_comics/internal/fsutil"
+)
+
+// FileName is the journal's name inside the backup directory
+const FileName = "journal.jsonl"
+
+// State is the progress of one replace operation
+type State string
+
+const (
+	StateBegin      State = "begin"       // Compressed archive staged; original untouched
+	StateBackedUp   State = "backed-up"   // Original moved to backup; target path is empty
+	StateCommitted  State = "committed"   // Compressed archive renamed into place
+	StateRolledBack State = "rolled-back" // Original restored; operation abandoned
+)
+
+// Terminal reports whether an operation in this state needs no recovery
+func (s State) Terminal() bool {
+	return s == StateCommitted || s == StateRolledBack
+}
+
+// Record is one journal line. Each state change appends a new record with the same ID.
+type Record struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	State      State     `json:"state"`
+	Target     string    `json:"target"`           // Original archive path, replaced in place
+	Output     string    `json:"output,omitempty"` // Where the compressed archive goes when renamed (empty = Target)
+	Temp       string    `json:"temp"`             // Staged compressed archive
+	Backup     string    `json:"backup,omitempty"` // Where the original was moved
+	BackupMode string    `json:"backup_mode,omitempty"`
+}
+
+// Destination returns where the compressed archive ends up
+func (r Record) Destination() string {
+	if r.Output != "" {
+		return r.Output
+	}
+	return r.Target
+}
+
+// DefaultPath returns the journal path for a backup directory
+func DefaultPath(backupDir string) string {
+	return filepath.Join(backupDir, FileName)
+}
+
+// Journal is an append-only log of replace operations, safe for concurrent use
+type Journal struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+}
+
+// New returns a journal at path; the file is created on first append
+func New(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// Path returns the journal file path
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// NewID returns a random operation ID
+func NewID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Append writes a record and fsyncs it, so it survives a crash right after
+func (j *Journal) Append(rec Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+			return fmt.Errorf("failed to create journal dir: %w", err)
+		}
+		f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open journal: %w", err)
+		}
+		j.f = f
+	}
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal record: %w", err)
+	}
+
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal and drops operations that completed, keeping only
+// ones that still need recovery
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	if err != nil {
+		return err
+	}
+	return Compact(j.path)
+}
+
+// Load reads all records from a journal file. A missing journal has no records.
+// A torn final line (crash mid-write) is ignored.
+func Load(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// Pending returns the latest record of every operation that did not reach a terminal state
+func Pending(records []Record) []Record {
+	latest := make(map[string]Record)
+	var order []string
+	for _, rec := range records {
+		if _, ok := latest[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		latest[rec.ID] = rec
+	}
+
+	var pending []Record
+	for _, id := range order {
+		if rec := latest[id]; !rec.State.Terminal() {
+			pending = append(pending, rec)
+		}
+	}
+	return pending
+}
+
+// Compact rewrites the journal with only pending operations, removing it when none remain
+func Compact(path string) error {
+	records, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	pending := Pending(records)
+	if len(pending) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	tempPath := path + ".tmp"
+	f, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, rec := range pending {
+		if err := enc.Encode(rec); err != nil {
+			f.Close()
+			os.Remove(tempPath)
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return fsutil.Rename(tempPath, path)
+}
@@ -0,0 +1,153 @@
+package journal
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"time"
+
+	"compress_comics/internal/fsutil"
+	"compress_comics/internal/trash"
+)
+
+// Outcome describes how a pending operation was resolved
+type Outcome string
+
+const (
+	OutcomeCommitted  Outcome = "committed"   // Compressed archive is in place
+	OutcomeRolledBack Outcome = "rolled back" // Original archive is in place
+)
+
+// Recover resolves one pending operation. By default an interrupted replacement
+// is finished when the staged archive is intact; with rollback the original is
+// always restored instead. The resolution is appended to the journal.
+func (j *Journal) Recover(rec Record, rollback bool) (Outcome, error) {
+	outcome, err := resolve(rec, rollback)
+	if err != nil {
+		return "", err
+	}
+
+	final := rec
+	final.Time = time.Time{}
+	final.State = StateCommitted
+	if outcome == OutcomeRolledBack {
+		final.State = StateRolledBack
+	}
+	if err := j.Append(final); err != nil {
+		return outcome, err
+	}
+	return outcome, nil
+}
+
+func resolve(rec Record, rollback bool) (Outcome, error) {
+	switch rec.State {
+	case StateBegin:
+		// A one-step replace got as far as moving the original
+		if rec.Backup != "" && exists(rec.Backup) {
+			rec.State = StateBackedUp
+			return resolve(rec, rollback)
+		}
+		// Original never moved: discard the staged archive
+		if err := removeIfExists(rec.Temp); err != nil {
+			return "", err
+		}
+		return OutcomeRolledBack, nil
+
+	case StateBackedUp:
+		dest := rec.Destination()
+		destExists := exists(dest)
+
+		if !rollback {
+			// Rename happened but the commit record didn't make it to disk
+			if destExists && !exists(rec.Temp) {
+				return OutcomeCommitted, nil
+			}
+			if !destExists && validArchive(rec.Temp) {
+				if err := fsutil.Move(rec.Temp, dest); err != nil {
+					return "", fmt.Errorf("failed to finish replacing %s: %w", rec.Target, err)
+				}
+				return OutcomeCommitted, nil
+			}
+		}
+
+		// Roll back: put the original back where it was, under its old name.
+		// Without its backup the original can only be in place already (a
+		// crash after restoring it); dest is never removed before a restore
+		// source is confirmed, as it may be the only copy.
+		if !backupPresent(rec) {
+			if !exists(rec.Target) {
+				return "", fmt.Errorf("cannot restore %s: backup %s is missing", rec.Target, rec.Backup)
+			}
+			if dest != rec.Target {
+				if err := removeIfExists(dest); err != nil {
+					return "", err
+				}
+			}
+			if err := removeIfExists(rec.Temp); err != nil {
+				return "", err
+			}
+			return OutcomeRolledBack, nil
+		}
+		if destExists {
+			if err := os.Remove(dest); err != nil {
+				return "", fmt.Errorf("failed to remove %s before restore: %w", dest, err)
+			}
+		}
+		if err := restoreBackup(rec); err != nil {
+			return "", fmt.Errorf("failed to restore %s from %s: %w", rec.Target, rec.Backup, err)
+		}
+		if err := removeIfExists(rec.Temp); err != nil {
+			return "", err
+		}
+		return OutcomeRolledBack, nil
+	}
+
+	return "", fmt.Errorf("operation %s is already %s", rec.ID, rec.State)
+}
+
+func restoreBackup(rec Record) error {
+	switch rec.BackupMode {
+	case "trash":
+		return trash.Restore(rec.Backup, rec.Target)
+	case "store":
+		// Stored objects may be shared by other originals, so copy rather than move
+		return fsutil.Copy(rec.Backup, rec.Target)
+	}
+	return fsutil.Move(rec.Backup, rec.Target)
+}
+
+// backupPresent reports whether the original's backup is where rec says,
+// as the file its mode leaves: a file in the backup directory, a trashed
+// file or a store object
+func backupPresent(rec Record) bool {
+	if rec.Backup == "" {
+		return false
+	}
+	info, err := os.Stat(rec.Backup)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// validArchive reports whether path is a readable zip with at least one entry
+func validArchive(path string) bool {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+	return len(zr.File) > 0
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func removeIfExists(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
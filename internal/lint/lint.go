@@ -0,0 +1,256 @@
+// Package lint checks archives for structural problems without changing them
+package lint
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"compress_comics/internal/cbz"
+	_ "compress_comics/internal/codec" // JPEG 2000 and HEIF headers
+	"compress_comics/internal/fileclass"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// Issue kinds
+const (
+	KindCorrupt      = "corrupt"       // Unreadable archive, entry or image header
+	KindNumbering    = "numbering"     // Gaps, duplicates or a late start in page numbers
+	KindMixedFormats = "mixed-formats" // Pages in more than one image format
+	KindNoComicInfo  = "no-comicinfo"  // No ComicInfo.xml metadata
+	KindPageCount    = "page-count"    // Too few pages, or a count ComicInfo.xml disagrees with
+	KindUnsafePath   = "unsafe-path"   // Entry paths that are absolute or climb out with ..
+)
+
+// Kinds lists every issue kind, for validating -ignore
+var Kinds = []string{KindCorrupt, KindNumbering, KindMixedFormats, KindNoComicInfo, KindPageCount, KindUnsafePath}
+
+// Issue is one problem found in an archive
+type Issue struct {
+	Kind    string
+	Message string
+}
+
+// Options controls which checks run
+type Options struct {
+	MinPages int             // Archives with fewer pages are flagged (0 = no minimum)
+	Ignore   map[string]bool // Issue kinds not to report
+}
+
+// comicInfoName is the metadata file readers look for at the archive root
+const comicInfoName = "comicinfo.xml"
+
+// maxListed caps how many pages or numbers one issue message names
+const maxListed = 5
+
+// Check reads every entry of the archive at cbzPath and reports its issues.
+// Reading everything is what finds corrupt entries: the zip reader checks
+// each entry's CRC once it has been read to the end.
+func Check(cbzPath string, opts Options) []Issue {
+	zr, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		return filter([]Issue{{Kind: KindCorrupt, Message: fmt.Sprintf("cannot open archive: %v", err)}}, opts)
+	}
+	defer zr.Close()
+
+	var (
+		issues    []Issue
+		pages     []string
+		formats   = make(map[string]int)
+		comicInfo []byte
+		corrupt   []string
+		unsafe    []string
+	)
+	for _, file := range zr.File {
+		entry, ok := cbz.SafeEntryName(strings.TrimSuffix(file.Name, "/"))
+		if !ok {
+			unsafe = append(unsafe, file.Name)
+		}
+		if file.FileInfo().IsDir() || fileclass.IsJunk(file.Name) {
+			continue
+		}
+
+		data, err := readEntry(file)
+		if err != nil {
+			corrupt = append(corrupt, fmt.Sprintf("%s (%v)", file.Name, err))
+			continue
+		}
+
+		if strings.EqualFold(file.Name, comicInfoName) {
+			comicInfo = data
+			continue
+		}
+
+		name := fileclass.PageName(entry, data)
+		if name == "" {
+			continue
+		}
+		format := fileclass.FormatOf(name)
+		pages = append(pages, name)
+		formats[format]++
+
+		if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+			corrupt = append(corrupt, fmt.Sprintf("%s (unreadable %s header: %v)", file.Name, format, err))
+		}
+	}
+
+	if len(corrupt) > 0 {
+		issues = append(issues, Issue{Kind: KindCorrupt, Message: fmt.Sprintf("corrupt entries (%d): %s", len(corrupt), listed(corrupt))})
+	}
+	if len(unsafe) > 0 {
+		issues = append(issues, Issue{Kind: KindUnsafePath, Message: fmt.Sprintf("unsafe entry paths (%d): %s", len(unsafe), listed(unsafe))})
+	}
+	issues = append(issues, checkNumbering(pages)...)
+
+	if len(formats) > 1 {
+		names := make([]string, 0, len(formats))
+		for name := range formats {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if formats[names[i]] != formats[names[j]] {
+				return formats[names[i]] > formats[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		counts := make([]string, len(names))
+		for i, name := range names {
+			counts[i] = fmt.Sprintf("%s (%d)", name, formats[name])
+		}
+		issues = append(issues, Issue{Kind: KindMixedFormats, Message: "mixed page formats: " + strings.Join(counts, ", ")})
+	}
+
+	switch {
+	case len(pages) == 0:
+		issues = append(issues, Issue{Kind: KindPageCount, Message: "no pages"})
+	case len(pages) < opts.MinPages:
+		issues = append(issues, Issue{Kind: KindPageCount, Message: fmt.Sprintf("only %d pages", len(pages))})
+	}
+
+	if comicInfo == nil {
+		issues = append(issues, Issue{Kind: KindNoComicInfo, Message: "no ComicInfo.xml"})
+	} else {
+		var info struct {
+			PageCount int `xml:"PageCount"`
+		}
+		if err := xml.Unmarshal(comicInfo, &info); err != nil {
+			issues = append(issues, Issue{Kind: KindCorrupt, Message: fmt.Sprintf("ComicInfo.xml does not parse: %v", err)})
+		} else if info.PageCount > 0 && info.PageCount != len(pages) {
+			issues = append(issues, Issue{Kind: KindPageCount, Message: fmt.Sprintf("ComicInfo.xml lists %d pages, archive has %d", info.PageCount, len(pages))})
+		}
+	}
+
+	return filter(issues, opts)
+}
+
+// readEntry reads an entry to the end, so its CRC is verified
+func readEntry(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// pageNumber matches the last number in a page name, with an optional
+// second number for two-page spreads ("p04-05")
+var pageNumber = regexp.MustCompile(`(\d+)(?:[-_](\d+))?\D*$`)
+
+// checkNumbering looks for gaps and duplicates in the page numbers of each
+// directory. Pages without a number (cover.jpg) are left out.
+func checkNumbering(pages []string) []Issue {
+	byDir := make(map[string][]int)
+	for _, page := range pages {
+		stem := strings.TrimSuffix(path.Base(page), path.Ext(page))
+		m := pageNumber.FindStringSubmatch(stem)
+		if m == nil {
+			continue
+		}
+		first, _ := strconv.Atoi(m[1])
+		last := first
+		if m[2] != "" {
+			if second, _ := strconv.Atoi(m[2]); second == first+1 {
+				last = second // A spread covers both numbers
+			} else {
+				first, last = second, second // "Comic_2023_001": the last number is the page
+			}
+		}
+		dir := path.Dir(page)
+		for n := first; n <= last; n++ {
+			byDir[dir] = append(byDir[dir], n)
+		}
+	}
+
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return cbz.NaturalLess(dirs[i], dirs[j]) })
+
+	var issues []Issue
+	for _, dir := range dirs {
+		numbers := byDir[dir]
+		sort.Ints(numbers)
+
+		var missing, duplicate []string
+		for i := 1; i < len(numbers); i++ {
+			switch {
+			case numbers[i] == numbers[i-1]:
+				duplicate = append(duplicate, strconv.Itoa(numbers[i]))
+			case numbers[i] > numbers[i-1]+1:
+				for n := numbers[i-1] + 1; n < numbers[i] && len(missing) <= maxListed; n++ {
+					missing = append(missing, strconv.Itoa(n))
+				}
+			}
+		}
+
+		where := ""
+		if dir != "." {
+			where = " in " + dir
+		}
+		if numbers[0] > 1 {
+			issues = append(issues, Issue{Kind: KindNumbering, Message: fmt.Sprintf("page numbers%s start at %d", where, numbers[0])})
+		}
+		if len(missing) > 0 {
+			issues = append(issues, Issue{Kind: KindNumbering, Message: fmt.Sprintf("page numbers missing%s: %s", where, listed(missing))})
+		}
+		if len(duplicate) > 0 {
+			issues = append(issues, Issue{Kind: KindNumbering, Message: fmt.Sprintf("page numbers used twice%s: %s", where, listed(duplicate))})
+		}
+	}
+	return issues
+}
+
+// listed joins up to maxListed items, noting when there are more
+func listed(items []string) string {
+	if len(items) <= maxListed {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and more", strings.Join(items[:maxListed], ", "))
+}
+
+// filter drops the kinds opts ignores
+func filter(issues []Issue, opts Options) []Issue {
+	kept := issues[:0]
+	for _, issue := range issues {
+		if !opts.Ignore[issue.Kind] {
+			kept = append(kept, issue)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,297 @@
+// Package override reads per-archive settings that take precedence over the
+// run's configuration for one archive, e.g. to keep an artbook lossless.
+package override
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Override holds the settings for one archive. Zero values inherit the run's
+// configuration.
+type Override struct {
+	Skip            bool             `yaml:"skip"`                  // Never process this archive
+	Reason          string           `yaml:"reason"`                // Shown as the skip reason
+	MaxDimension    int              `yaml:"max_dimension"`         // Maximum dimension in pixels
+	JPEGQuality     int              `yaml:"jpeg_quality"`          // JPEG quality 1-100
+	ThresholdMBPage float64          `yaml:"threshold_mb_per_page"` // MB per page threshold for skip heuristic
+	FormatPolicy    cbz.FormatPolicy `yaml:"format_policy"`         // Merged over the configured policy
+	Grayscale       bool             `yaml:"grayscale"`             // Re-encode pages as grayscale
+}
+
+// Load returns the override for cbzPath, or nil if it has none. The archive
+// may carry one as a cbz.OverrideFileName entry; an entry for the archive's
+// file name in a cbz.OverrideFileName next to it takes precedence field by
+// field:
+//
+//	Artbook.cbz:
+//	  skip: true
+//	  reason: keep lossless
+func Load(cbzPath string) (*Override, error) {
+	inner, err := loadEmbedded(cbzPath)
+	if err != nil {
+		return nil, err
+	}
+	outer, err := loadSidecar(cbzPath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case inner == nil:
+		return outer, nil
+	case outer == nil:
+		return inner, nil
+	}
+	inner.merge(outer)
+	return inner, nil
+}
+
+// Resolve returns the settings for cbzPath from the series_rules its
+// directories match, then the comicinfo_rules its ComicInfo.xml matches,
+// with its override (see Load) applied over them, or nil if none applies.
+// root is the input directory the archive was found under, if any.
+func Resolve(cbzPath, root string, series config.SeriesRules, rules config.ComicInfoRules) (*Override, error) {
+	sources, err := Sources(cbzPath, root, series, rules)
+	if err != nil || len(sources) == 0 {
+		return nil, err
+	}
+	o := sources[0].Settings
+	for _, source := range sources[1:] {
+		o.merge(source.Settings)
+	}
+	return o, nil
+}
+
+// Source is one of the settings Resolve merges for an archive
+type Source struct {
+	Name     string // Where the settings come from, e.g. `series_rules "*Artbooks*"`
+	Settings *Override
+}
+
+// Sources returns the settings Resolve merges for cbzPath, in the order
+// they are applied
+func Sources(cbzPath, root string, series config.SeriesRules, rules config.ComicInfoRules) ([]Source, error) {
+	explicit, err := Load(cbzPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []Source
+	for _, rule := range series {
+		if rule.Matches(cbzPath, root) {
+			sources = append(sources, Source{fmt.Sprintf("series_rules %q", rule.Pattern), fromSeriesRule(rule)})
+		}
+	}
+	if len(rules) > 0 {
+		fields, err := cbz.ReadComicInfo(cbzPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if rule.Matches(fields) {
+				sources = append(sources, Source{"comicinfo_rules " + rule.String(), fromRule(rule)})
+			}
+		}
+	}
+	if explicit != nil {
+		sources = append(sources, Source{cbz.OverrideFileName, explicit})
+	}
+	return sources, nil
+}
+
+// fromSeriesRule returns the settings a matching series rule applies
+func fromSeriesRule(rule config.SeriesRule) *Override {
+	o := &Override{
+		Skip:            rule.Skip,
+		Reason:          rule.Reason,
+		MaxDimension:    rule.MaxDimension,
+		JPEGQuality:     rule.JPEGQuality,
+		ThresholdMBPage: rule.ThresholdMBPage,
+		Grayscale:       rule.Grayscale,
+	}
+	if o.Skip && o.Reason == "" {
+		o.Reason = fmt.Sprintf("series rule %q", rule.Pattern)
+	}
+	return o
+}
+
+// fromRule returns the settings a matching rule applies
+func fromRule(rule config.ComicInfoRule) *Override {
+	o := &Override{
+		Skip:         rule.Skip,
+		Reason:       rule.Reason,
+		MaxDimension: rule.MaxDimension,
+		JPEGQuality:  rule.JPEGQuality,
+		Grayscale:    rule.Grayscale,
+	}
+	if o.Skip && o.Reason == "" {
+		o.Reason = "ComicInfo " + rule.String()
+	}
+	return o
+}
+
+// loadSidecar reads the entry for cbzPath from the override file in its directory
+func loadSidecar(cbzPath string) (*Override, error) {
+	path := filepath.Join(filepath.Dir(cbzPath), cbz.OverrideFileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read override file: %w", err)
+	}
+
+	var entries map[string]*Override
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	o := entries[filepath.Base(cbzPath)]
+	if o == nil {
+		return nil, nil
+	}
+	if err := o.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", path, filepath.Base(cbzPath), err)
+	}
+	return o, nil
+}
+
+// loadEmbedded reads the override entry at the root of the archive
+func loadEmbedded(cbzPath string) (*Override, error) {
+	zr, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CBZ %s: %w", cbzPath, err)
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		if file.Name != cbz.OverrideFileName || cbz.IsEncrypted(file) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in %s: %w", file.Name, cbzPath, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in %s: %w", file.Name, cbzPath, err)
+		}
+
+		o := &Override{}
+		if err := yaml.Unmarshal(data, o); err != nil {
+			return nil, fmt.Errorf("failed to parse %s in %s: %w", file.Name, cbzPath, err)
+		}
+		if err := o.Validate(); err != nil {
+			return nil, fmt.Errorf("%s in %s: %w", file.Name, cbzPath, err)
+		}
+		return o, nil
+	}
+	return nil, nil
+}
+
+// Validate checks the override's values
+func (o *Override) Validate() error {
+	if o.JPEGQuality != 0 && (o.JPEGQuality < 1 || o.JPEGQuality > 100) {
+		return fmt.Errorf("jpeg_quality must be between 1 and 100")
+	}
+	if o.MaxDimension < 0 {
+		return fmt.Errorf("max_dimension cannot be negative")
+	}
+	if o.ThresholdMBPage < 0 {
+		return fmt.Errorf("threshold_mb_per_page cannot be negative")
+	}
+	return o.FormatPolicy.Validate()
+}
+
+// merge overlays the fields set in other
+func (o *Override) merge(other *Override) {
+	if other.Skip {
+		o.Skip = true
+	}
+	if other.Reason != "" {
+		o.Reason = other.Reason
+	}
+	if other.MaxDimension != 0 {
+		o.MaxDimension = other.MaxDimension
+	}
+	if other.JPEGQuality != 0 {
+		o.JPEGQuality = other.JPEGQuality
+	}
+	if other.ThresholdMBPage != 0 {
+		o.ThresholdMBPage = other.ThresholdMBPage
+	}
+	if other.Grayscale {
+		o.Grayscale = true
+	}
+	o.FormatPolicy = o.FormatPolicy.Merge(other.FormatPolicy)
+}
+
+// Apply returns cfg with the override's settings in place
+func (o *Override) Apply(cfg config.Config) config.Config {
+	if o.MaxDimension != 0 {
+		cfg.MaxDimension = o.MaxDimension
+	}
+	if o.JPEGQuality != 0 {
+		cfg.JPEGQuality = o.JPEGQuality
+	}
+	if o.ThresholdMBPage != 0 {
+		cfg.ThresholdMBPage = o.ThresholdMBPage
+	}
+	if o.Grayscale {
+		cfg.Grayscale = true
+	}
+	cfg.FormatPolicy = cfg.FormatPolicy.Merge(o.FormatPolicy)
+	return cfg
+}
+
+// String lists the settings the override sets, e.g. "max_dimension 1440, grayscale"
+func (o *Override) String() string {
+	var parts []string
+	if o.Skip {
+		parts = append(parts, "skip")
+	}
+	if o.Reason != "" {
+		parts = append(parts, fmt.Sprintf("reason %q", o.Reason))
+	}
+	if o.MaxDimension != 0 {
+		parts = append(parts, fmt.Sprintf("max_dimension %d", o.MaxDimension))
+	}
+	if o.JPEGQuality != 0 {
+		parts = append(parts, fmt.Sprintf("jpeg_quality %d", o.JPEGQuality))
+	}
+	if o.ThresholdMBPage != 0 {
+		parts = append(parts, fmt.Sprintf("threshold_mb_per_page %g", o.ThresholdMBPage))
+	}
+	if o.Grayscale {
+		parts = append(parts, "grayscale")
+	}
+	if len(o.FormatPolicy) > 0 {
+		parts = append(parts, "format_policy")
+	}
+	if len(parts) == 0 {
+		return "no settings"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SkipReason describes why the archive is skipped, or "" if it is not
+func (o *Override) SkipReason() string {
+	if o == nil || !o.Skip {
+		return ""
+	}
+	if o.Reason != "" {
+		return "excluded by override: " + o.Reason
+	}
+	return "excluded by override"
+}
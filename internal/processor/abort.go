@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrAborted is returned for an archive whose swap was stopped by Abort
+var ErrAborted = errors.New("run interrupted")
+
+// swapGate tracks the originals being swapped for their compressed
+// archives, so a shutdown can wait for them instead of leaving one in the
+// backup directory with nothing in its place
+type swapGate struct {
+	mu       sync.Mutex
+	aborting bool
+	active   sync.WaitGroup
+}
+
+// enter registers a swap, or reports false once the run is aborting
+func (g *swapGate) enter() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.aborting {
+		return false
+	}
+	g.active.Add(1)
+	return true
+}
+
+// leave ends a swap registered with enter
+func (g *swapGate) leave() {
+	g.active.Done()
+}
+
+// aborted reports whether Abort has been called
+func (g *swapGate) aborted() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.aborting
+}
+
+// Abort stops the run from replacing any more originals. A swap that has
+// already moved its original to backup puts it back instead of finishing,
+// and Abort waits up to timeout for those in progress. It reports whether
+// they all finished; any that didn't are left to the recover command.
+func (p *Pipeline) Abort(timeout time.Duration) bool {
+	p.swaps.mu.Lock()
+	p.swaps.aborting = true
+	p.swaps.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.swaps.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
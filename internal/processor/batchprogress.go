@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"sync"
+	"time"
+)
+
+// batchProgressInterval is the minimum time between OnBatchProgress reports
+// while pages are encoding; finishing an archive always reports
+const batchProgressInterval = 2 * time.Second
+
+// batchProgress tracks how many bytes of the batch's archives have been
+// processed and reports it through OnBatchProgress. Archives still encoding
+// count by the share of their pages done, so one huge archive doesn't hold
+// the figure still. All methods are safe on a nil tracker.
+type batchProgress struct {
+	mu       sync.Mutex
+	reporter ProgressReporter // Must be safe for concurrent use with parallel workers
+	start    time.Time
+	last     time.Time
+	sizes    map[string]int64 // Archive -> size on disk
+	total    int64
+	done     int64            // Bytes of finished archives
+	partial  map[string]int64 // Archive -> bytes of it done so far, while it encodes
+}
+
+// newBatchProgress starts tracking files, or returns nil without a reporter
+func newBatchProgress(reporter ProgressReporter, files []string) *batchProgress {
+	if reporter == nil {
+		return nil
+	}
+	b := &batchProgress{
+		reporter: reporter,
+		start:    time.Now(),
+		sizes:    make(map[string]int64, len(files)),
+		partial:  make(map[string]int64),
+	}
+	for _, path := range files {
+		size := fileSize(path)
+		b.sizes[path] = size
+		b.total += size
+	}
+	return b
+}
+
+// page records how far encoding the archive at path has got, reporting at
+// most every batchProgressInterval
+func (b *batchProgress) page(path string, progress PageProgress) {
+	if b == nil || progress.BytesTotal <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.partial[path] = b.sizes[path] * progress.BytesDone / progress.BytesTotal
+	if time.Since(b.last) >= batchProgressInterval {
+		b.report()
+	}
+}
+
+// finish counts the archive at path as processed, whatever its outcome
+func (b *batchProgress) finish(path string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.partial, path)
+	b.done += b.sizes[path]
+	b.report()
+}
+
+// report sends the current figures; callers hold b.mu
+func (b *batchProgress) report() {
+	processed := b.done
+	for _, n := range b.partial {
+		processed += n
+	}
+	b.last = time.Now()
+	b.reporter.OnBatchProgress(processed, b.total, b.last.Sub(b.start))
+}
+
+// EstimateRemaining extrapolates the time left in a batch from the rate so
+// far, or returns 0 when there's nothing to go on yet
+func EstimateRemaining(processedBytes, totalBytes int64, elapsed time.Duration) time.Duration {
+	if processedBytes <= 0 || totalBytes <= processedBytes {
+		return 0
+	}
+	rate := float64(elapsed) / float64(processedBytes)
+	return time.Duration(rate * float64(totalBytes-processedBytes)).Round(time.Second)
+}
@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+)
+
+// isHighBitDepth reports whether img carries more than 8 bits per channel
+// (16-bit PNG and TIFF scans decode to these types)
+func isHighBitDepth(img image.Image) bool {
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// reduceBitDepth converts a 16-bit image to 8 bits per channel. Plain
+// conversion rounds each sample; dithering diffuses the rounding error
+// (Floyd–Steinberg), which avoids banding in smooth gradients.
+func reduceBitDepth(img image.Image, dither bool) image.Image {
+	b := img.Bounds()
+	_, gray := img.(*image.Gray16)
+
+	var out image.Image
+	var set func(x, y int, c [4]uint8)
+	if gray {
+		g := image.NewGray(b)
+		out = g
+		set = func(x, y int, c [4]uint8) { g.SetGray(x, y, color.Gray{Y: c[0]}) }
+	} else {
+		n := image.NewNRGBA(b)
+		out = n
+		set = func(x, y int, c [4]uint8) { n.SetNRGBA(x, y, color.NRGBA{R: c[0], G: c[1], B: c[2], A: c[3]}) }
+	}
+
+	// Error rows for the current and next scanline, 4 channels per pixel
+	width := b.Dx()
+	cur := make([]float32, (width+2)*4)
+	next := make([]float32, (width+2)*4)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			samples := [4]uint16{c.R, c.G, c.B, c.A}
+
+			var px [4]uint8
+			i := (x - b.Min.X + 1) * 4
+			for ch := 0; ch < 4; ch++ {
+				v := float32(samples[ch])
+				if dither {
+					v += cur[i+ch]
+				}
+				q := clamp8((v + 128.5) / 257)
+				px[ch] = q
+				if dither {
+					e := v - float32(q)*257
+					cur[i+4+ch] += e * 7 / 16
+					next[i-4+ch] += e * 3 / 16
+					next[i+ch] += e * 5 / 16
+					next[i+4+ch] += e * 1 / 16
+				}
+			}
+			set(x, y, px)
+		}
+		if dither {
+			cur, next = next, cur
+			clear(next)
+		}
+	}
+	return out
+}
+
+// HighBitDepthPages counts 16-bit pages across the batch (from analysis in dry runs)
+func (b BatchResult) HighBitDepthPages() int {
+	var pages int
+	for _, result := range b.Results {
+		if result.Analysis != nil {
+			pages += result.Analysis.HighBitDepthPages
+		} else {
+			pages += result.HighBitDepth
+		}
+	}
+	return pages
+}
+
+// CMYKPages counts CMYK JPEG pages across the batch (from analysis in dry runs)
+func (b BatchResult) CMYKPages() int {
+	var pages int
+	for _, result := range b.Results {
+		if result.Analysis != nil {
+			pages += result.Analysis.CMYKPages
+		} else {
+			pages += result.CMYKPages
+		}
+	}
+	return pages
+}
+
+func clamp8(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return uint8(v)
+	}
+}
+
+// LowQualityPages totals the JPEG pages kept because they were saved below the target quality
+func (b BatchResult) LowQualityPages() int {
+	var pages int
+	for _, result := range b.Results {
+		pages += result.LowQualityPages
+	}
+	return pages
+}
+
+// ModernPages totals the WebP/AVIF pages kept because they were already compressed below keep_modern_kb_per_mp
+func (b BatchResult) ModernPages() int {
+	var pages int
+	for _, result := range b.Results {
+		pages += result.ModernPages
+	}
+	return pages
+}
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"archive/zip"
+	"sync"
+
+	"compress_comics/internal/cbz"
+)
+
+// byteBudget holds archives back while the bytes the ones in flight are
+// weighted with (temp space, memory) would exceed a limit. Archives are let
+// through in order, so a large one waits for room rather than being
+// overtaken forever; one that alone exceeds the limit runs by itself.
+type byteBudget struct {
+	mu       sync.Mutex
+	limit    int64
+	used     int64
+	reserved map[string]int64 // Archive in flight -> its weight
+	freed    chan struct{}    // Signaled when room frees up
+}
+
+// newByteBudget creates a budget of limitMB megabytes, or nil for 0
+// (unlimited)
+func newByteBudget(limitMB int) *byteBudget {
+	if limitMB <= 0 {
+		return nil
+	}
+	return &byteBudget{
+		limit:    int64(limitMB) << 20,
+		reserved: make(map[string]int64),
+		freed:    make(chan struct{}, 1),
+	}
+}
+
+// acquire reserves n bytes for the archive at path, blocking until they fit
+// (or nothing else is in flight). It returns false when stop is closed. A
+// nil budget lets everything through.
+func (b *byteBudget) acquire(path string, n int64, stop <-chan struct{}) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		b.mu.Lock()
+		if b.used == 0 || b.used+n <= b.limit {
+			b.used += n
+			b.reserved[path] = n
+			b.mu.Unlock()
+			return true
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.freed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// release frees what was reserved for a finished archive
+func (b *byteBudget) release(path string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	n, ok := b.reserved[path]
+	if ok {
+		delete(b.reserved, path)
+		b.used -= n
+	}
+	b.mu.Unlock()
+
+	if ok {
+		select {
+		case b.freed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// tempEstimate is the most temp space compressing the archive at path is
+// expected to take: the new archive, which is rarely larger than the
+// original, plus the local copy with LocalCopy and the joined archive of a
+// split set
+func (p *Pipeline) tempEstimate(path string) int64 {
+	size := fileSize(path)
+	copies := int64(1)
+	if parts, err := cbz.SplitParts(path); err == nil && parts != nil {
+		for _, part := range parts {
+			size += fileSize(part)
+		}
+		copies++
+	}
+	if p.copySlots != nil {
+		copies++
+	}
+	return size * copies
+}
+
+// memoryEstimate is how much memory compressing the archive at path holds:
+// every entry is extracted into memory, so the sum of their uncompressed
+// sizes (the file size when the directory can't be read, as in split sets)
+func memoryEstimate(path string) int64 {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fileSize(path)
+	}
+	defer zr.Close()
+	var size int64
+	for _, file := range zr.File {
+		size += int64(file.UncompressedSize64)
+	}
+	return size
+}
@@ -0,0 +1,199 @@
+package processor
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+
+	"compress_comics/internal/cbz"
+
+	"github.com/disintegration/imaging"
+)
+
+// CalibrationOptions holds the settings grid tried by Calibrate
+type CalibrationOptions struct {
+	MaxDims   []int        // Candidate max dimensions
+	Qualities []int        // Candidate JPEG qualities
+	Display   int          // Long edge pages are compared at, as on the reading device
+	Limits    DecodeLimits // Sample pages over these limits are left out
+}
+
+// Trial is the outcome of one max dimension/quality pair over all sample pages
+type Trial struct {
+	MaxDim        int
+	Quality       int
+	OriginalBytes int64
+	Bytes         int64
+	MeanPSNR      float64 // Mean over pages, dB (higher = closer to the original)
+	LowPSNR       float64 // 10th percentile over pages, dB
+}
+
+// Savings is the share (0-1) of sample bytes saved
+func (t Trial) Savings() float64 {
+	if t.OriginalBytes == 0 {
+		return 0
+	}
+	return 1 - float64(t.Bytes)/float64(t.OriginalBytes)
+}
+
+// maxPSNR stands in for identical images, whose PSNR is infinite
+const maxPSNR = 99
+
+// Calibrate compresses every sample page at each max dimension/quality pair
+// and measures size and fidelity. Fidelity is PSNR against the original
+// scaled to the display size, so both resolution loss (max dimension below
+// the display) and JPEG artifacts count. Pages that cannot be decoded are
+// skipped; the number used is returned with the trials, ordered by max
+// dimension then quality.
+func Calibrate(pages []cbz.ImageEntry, opts CalibrationOptions) ([]Trial, int) {
+	type pageResult struct {
+		size  int64
+		bytes [][]int64   // [dim][quality]
+		psnr  [][]float64 // [dim][quality]
+	}
+
+	results := make([]*pageResult, len(pages))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.NumCPU(), len(pages)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				bytes, psnr, ok := calibratePage(pages[i], opts)
+				if ok {
+					results[i] = &pageResult{size: pages[i].OriginalSize, bytes: bytes, psnr: psnr}
+				}
+			}
+		}()
+	}
+	for i := range pages {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	used := 0
+	for _, r := range results {
+		if r != nil {
+			used++
+		}
+	}
+
+	trials := make([]Trial, 0, len(opts.MaxDims)*len(opts.Qualities))
+	for d, dim := range opts.MaxDims {
+		for q, quality := range opts.Qualities {
+			t := Trial{MaxDim: dim, Quality: quality}
+			var psnrs []float64
+			for _, r := range results {
+				if r == nil {
+					continue
+				}
+				t.OriginalBytes += r.size
+				t.Bytes += r.bytes[d][q]
+				psnrs = append(psnrs, r.psnr[d][q])
+			}
+			if len(psnrs) > 0 {
+				sum := 0.0
+				for _, v := range psnrs {
+					sum += v
+				}
+				t.MeanPSNR = sum / float64(len(psnrs))
+				sort.Float64s(psnrs)
+				t.LowPSNR = psnrs[len(psnrs)/10]
+			}
+			trials = append(trials, t)
+		}
+	}
+	return trials, used
+}
+
+// calibratePage encodes one page at every grid point
+func calibratePage(entry cbz.ImageEntry, opts CalibrationOptions) (sizes [][]int64, psnrs [][]float64, ok bool) {
+	var err error
+	defer recoverPanic(entry.Path, &err)
+
+	if checkDecodeLimits(entry.Data, opts.Limits) != nil {
+		return nil, nil, false
+	}
+	img, _, err := decodePage(entry)
+	if err != nil {
+		return nil, nil, false
+	}
+	if isHighBitDepth(img) {
+		img = reduceBitDepth(img, false)
+	}
+	reference := fitLongEdge(img, opts.Display)
+	refSize := reference.Bounds().Size()
+
+	sizes = make([][]int64, len(opts.MaxDims))
+	psnrs = make([][]float64, len(opts.MaxDims))
+	for d, dim := range opts.MaxDims {
+		resized := fitLongEdge(img, dim)
+		sizes[d] = make([]int64, len(opts.Qualities))
+		psnrs[d] = make([]float64, len(opts.Qualities))
+		for q, quality := range opts.Qualities {
+			var buf bytes.Buffer
+			if err := imaging.Encode(&buf, resized, imaging.JPEG, imaging.JPEGQuality(quality)); err != nil {
+				return nil, nil, false
+			}
+			sizes[d][q] = int64(buf.Len())
+
+			decoded, err := imaging.Decode(&buf)
+			if err != nil {
+				return nil, nil, false
+			}
+			shown := imaging.Resize(decoded, refSize.X, refSize.Y, imaging.Lanczos)
+			psnrs[d][q] = psnr(reference, shown)
+		}
+	}
+	return sizes, psnrs, true
+}
+
+// fitLongEdge scales img down so its long edge is at most edge
+func fitLongEdge(img image.Image, edge int) *image.NRGBA {
+	b := img.Bounds()
+	if b.Dx() <= edge && b.Dy() <= edge {
+		return imaging.Clone(img)
+	}
+	return imaging.Fit(img, edge, edge, imaging.Lanczos)
+}
+
+// psnr returns the peak signal-to-noise ratio of b against a over RGB, in dB
+func psnr(a, b *image.NRGBA) float64 {
+	var sum float64
+	n := 0
+	for y := 0; y < a.Rect.Dy(); y++ {
+		ra := a.Pix[y*a.Stride:]
+		rb := b.Pix[y*b.Stride:]
+		for x := 0; x < a.Rect.Dx()*4; x += 4 {
+			for c := 0; c < 3; c++ {
+				d := float64(ra[x+c]) - float64(rb[x+c])
+				sum += d * d
+			}
+			n += 3
+		}
+	}
+	if n == 0 || sum == 0 {
+		return maxPSNR
+	}
+	mse := sum / float64(n)
+	return math.Min(maxPSNR, 10*math.Log10(255*255/mse))
+}
+
+// Recommend picks the trial with the largest savings whose 10th percentile
+// PSNR reaches target; ok is false if none does
+func Recommend(trials []Trial, target float64) (best Trial, ok bool) {
+	for _, t := range trials {
+		if t.LowPSNR < target {
+			continue
+		}
+		if !ok || t.Bytes < best.Bytes || (t.Bytes == best.Bytes && t.MaxDim > best.MaxDim) {
+			best, ok = t, true
+		}
+	}
+	return best, ok
+}
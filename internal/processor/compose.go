@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/cbz"
+)
+
+// composeStrips stacks runs of consecutive strip slices of equal width into
+// pages of at most width*ComposeAspect height. Composed pages are handed to
+// the normal encode path as lossless PNG named after their first slice, so
+// they sort where the slices were. Returns the new page list plus the number
+// of slices consumed and pages produced.
+func (p *ImageProcessor) composeStrips(images []cbz.ImageEntry) ([]cbz.ImageEntry, int, int) {
+	aspect := p.opts.ComposeAspect
+	if aspect <= 0 || len(images) < 2 {
+		return images, 0, 0
+	}
+
+	out := make([]cbz.ImageEntry, 0, len(images))
+	var (
+		group    []cbz.ImageEntry
+		width    int
+		height   int
+		strips   int
+		composed int
+	)
+
+	flush := func() {
+		if len(group) > 1 {
+			if page, ok := p.stackStrips(group, width, height); ok {
+				out = append(out, page)
+				strips += len(group)
+				composed++
+				group = nil
+				return
+			}
+		}
+		out = append(out, group...)
+		group = nil
+	}
+
+	for _, entry := range images {
+		w, h, ok := p.stripSize(entry)
+		if !ok {
+			flush()
+			out = append(out, entry)
+			continue
+		}
+		if len(group) > 0 && (w != width || float64(height+h) > float64(width)*aspect) {
+			flush()
+		}
+		if len(group) == 0 {
+			width, height = w, 0
+		}
+		group = append(group, entry)
+		height += h
+	}
+	flush()
+
+	return out, strips, composed
+}
+
+// stripSize returns the dimensions of entry if it is a strip slice that may
+// be composed (not kept by the format policy or extension list, header readable)
+func (p *ImageProcessor) stripSize(entry cbz.ImageEntry) (int, int, bool) {
+	if p.keeps(entry.Path) {
+		return 0, 0, false
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(entry.Data))
+	if err != nil || !analyzer.IsStrip(cfg.Width, cfg.Height, p.opts.ComposeAspect) {
+		return 0, 0, false
+	}
+	return cfg.Width, cfg.Height, true
+}
+
+// stackStrips decodes group and draws it top to bottom on one canvas. Any
+// slice that fails the decode guards or decoding leaves the group as it was.
+func (p *ImageProcessor) stackStrips(group []cbz.ImageEntry, width, height int) (_ cbz.ImageEntry, ok bool) {
+	// A decoder panic returns the zero values: not composed
+	var err error
+	defer recoverPanic(group[0].Path, &err)
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	var size int64
+	y := 0
+	for _, entry := range group {
+		if err = checkDecodeLimits(entry.Data, p.opts.Limits); err != nil {
+			return cbz.ImageEntry{}, false
+		}
+		var img image.Image
+		img, _, err = decodePage(entry)
+		if err != nil {
+			return cbz.ImageEntry{}, false
+		}
+		// EXIF orientation can disagree with the header size
+		b := img.Bounds()
+		if b.Dx() != width || y+b.Dy() > height {
+			return cbz.ImageEntry{}, false
+		}
+		draw.Draw(canvas, image.Rect(0, y, width, y+b.Dy()), img, b.Min, draw.Src)
+		y += b.Dy()
+		size += entry.OriginalSize
+	}
+
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err = enc.Encode(&buf, canvas); err != nil {
+		return cbz.ImageEntry{}, false
+	}
+
+	first := group[0]
+	return cbz.ImageEntry{
+		Path:         strings.TrimSuffix(first.Path, filepath.Ext(first.Path)) + ".png",
+		OriginalSize: size,
+		Data:         buf.Bytes(),
+		ModTime:      first.ModTime,
+	}, true
+}
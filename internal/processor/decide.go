@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"fmt"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+)
+
+// Rewrites lists the settings of cfg that rewrite the archive at cbzPath
+// whatever the heuristics say. parts is the number of further archives in
+// its split set (0 = not one).
+func Rewrites(cfg config.Config, cbzPath string, analysis *analyzer.AnalysisResult, parts int) []string {
+	var rewrites []string
+	if cfg.Pages.IsSet() {
+		rewrites = append(rewrites, "pages "+cfg.Pages.String())
+	}
+	if LevelsApply(cfg, cbzPath) {
+		rewrites = append(rewrites, "levels")
+	}
+	if cfg.Grayscale {
+		rewrites = append(rewrites, "grayscale")
+	}
+	if cfg.Flatten && NestedAnalysis(analysis) {
+		rewrites = append(rewrites, "flatten")
+	}
+	if cfg.Split && analysis.Chapters != nil {
+		rewrites = append(rewrites, fmt.Sprintf("split into %d", len(analysis.Chapters)))
+	}
+	if cfg.JoinSplit && parts > 0 {
+		rewrites = append(rewrites, fmt.Sprintf("join %d parts", parts+1))
+	}
+	return rewrites
+}
+
+// Decide settles a run's verdict on an analyzed archive and returns the
+// rewrites that apply (see Rewrites). They turn a skip into processing
+// unless the archive is excluded (excluded is the override's reason, "" if
+// none), encrypted or skipped by a caller's heuristic; of them only a page
+// selection also rewrites archives we already wrote (gamma would compound).
+func Decide(cfg config.Config, cbzPath, excluded string, analysis *analyzer.AnalysisResult, parts int) []string {
+	rewrites := Rewrites(cfg, cbzPath, analysis, parts)
+	heuristicSkip := !analysis.NeedsProcessing && analysis.DecidedBy != ""
+	if len(rewrites) > 0 && !analysis.NeedsProcessing && excluded == "" && analysis.EncryptedEntries == 0 && !heuristicSkip &&
+		(cfg.Pages.IsSet() || !analysis.Marker.Valid) {
+		analysis.NeedsProcessing = true
+		analysis.SkipReason = ""
+		analysis.SkipKind = ""
+	}
+	if parts > 0 && !analysis.NeedsProcessing && !cfg.JoinSplit {
+		analysis.SkipReason += fmt.Sprintf(" (split set of %d parts; -join-split rewrites it as one archive)", parts+1)
+	}
+	return rewrites
+}
+
+// ForcedSkip returns why a forced run (-force), which bypasses the
+// heuristics, still skips an archive, checking in this order: it is
+// excluded, it has encrypted entries, or it carries a valid marker and
+// cfg.IgnoreMarker is off. reason is "" when the archive is processed.
+func ForcedSkip(cfg config.Config, excluded string, encrypted int, marker cbz.Marker) (reason, kind string) {
+	switch {
+	case excluded != "":
+		return excluded, analyzer.SkipExcluded
+	case encrypted > 0:
+		return analyzer.EncryptedReason(encrypted), analyzer.SkipEncrypted
+	case marker.Valid && !cfg.IgnoreMarker:
+		return fmt.Sprintf("already processed (content hash %s)", marker.Hash), analyzer.SkipMarker
+	}
+	return "", ""
+}
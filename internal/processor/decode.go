@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+
+	"compress_comics/internal/config"
+)
+
+// DecodeLimits bounds what a single page may cost to decode. Zero fields are unlimited.
+type DecodeLimits struct {
+	MaxPixels int64 // Width × height
+	MaxBytes  int64 // Estimated memory for the decoded image plus the working copy used for resizing
+}
+
+// DecodeLimitsFromConfig converts the configured megapixel and MB limits
+func DecodeLimitsFromConfig(cfg config.Config) DecodeLimits {
+	return DecodeLimits{
+		MaxPixels: int64(cfg.MaxMegapixels * 1e6),
+		MaxBytes:  int64(cfg.MaxDecodeMB) << 20,
+	}
+}
+
+// headerError is returned by checkDecodeLimits for pages whose header is
+// unreadable, as opposed to pages over the limits
+type headerError struct {
+	err error
+}
+
+func (e headerError) Error() string { return e.err.Error() }
+func (e headerError) Unwrap() error { return e.err }
+
+// checkDecodeLimits reads only the image header and rejects pages whose
+// decoded size would exceed the limits, before any pixel data is allocated
+func checkDecodeLimits(data []byte, limits DecodeLimits) error {
+	if limits.MaxPixels <= 0 && limits.MaxBytes <= 0 {
+		return nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return headerError{err}
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return headerError{fmt.Errorf("invalid %s dimensions %dx%d", format, cfg.Width, cfg.Height)}
+	}
+
+	pixels := int64(cfg.Width) * int64(cfg.Height)
+	if limits.MaxPixels > 0 && pixels > limits.MaxPixels {
+		return fmt.Errorf("%dx%d %s exceeds the %.1f megapixel limit",
+			cfg.Width, cfg.Height, format, float64(limits.MaxPixels)/1e6)
+	}
+
+	// imaging converts everything to NRGBA (4 bytes/pixel) alongside the decoded image
+	estimate := pixels * (bytesPerPixel(cfg.ColorModel) + 4)
+	if limits.MaxBytes > 0 && estimate > limits.MaxBytes {
+		return fmt.Errorf("%dx%d %s needs ~%s to decode, over the %s limit",
+			cfg.Width, cfg.Height, format, FormatBytes(estimate), FormatBytes(limits.MaxBytes))
+	}
+	return nil
+}
+
+// bytesPerPixel estimates the decoded footprint of one pixel in the given color model
+func bytesPerPixel(model color.Model) int64 {
+	switch model {
+	case color.GrayModel, color.AlphaModel:
+		return 1
+	case color.Gray16Model, color.Alpha16Model:
+		return 2
+	case color.YCbCrModel:
+		return 3 // Upper bound (4:4:4); 4:2:0 JPEGs need half that
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 8
+	default:
+		return 4
+	}
+}
+
+// recoverPanic converts a panic (e.g. in a third-party decoder) into an error in *err
+func recoverPanic(what string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("panic while processing %s: %v", what, r)
+	}
+}
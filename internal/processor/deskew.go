@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Deskew search range and resolution. Scans are rarely off by more than a
+// few degrees; larger angles are more likely deliberate (splash pages).
+const (
+	deskewMaxAngle  = 5.0 // Degrees searched either way
+	deskewStep      = 0.1 // Degrees between candidates
+	deskewMinAngle  = 0.3 // Smaller skews are left alone
+	deskewSampleDim = 800 // Detection runs on a copy this size
+	deskewDarkLevel = 128 // Luminance below this counts as ink
+)
+
+// detectSkew estimates how far the page content is rotated, in degrees
+// counter-clockwise, using projection profiles: when rows of ink line up
+// with the pixel grid, the row histogram of dark pixels is most peaked.
+func detectSkew(img image.Image) float64 {
+	small := imaging.Fit(img, deskewSampleDim, deskewSampleDim, imaging.Box)
+	gray := imaging.Grayscale(small)
+	b := gray.Bounds()
+
+	// Collect ink pixel coordinates relative to the center
+	cx, cy := float64(b.Dx())/2, float64(b.Dy())/2
+	var xs, ys []float64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if gray.Pix[gray.PixOffset(x, y)] < deskewDarkLevel {
+				xs = append(xs, float64(x-b.Min.X)-cx)
+				ys = append(ys, float64(y-b.Min.Y)-cy)
+			}
+		}
+	}
+	if len(xs) < 100 {
+		return 0
+	}
+
+	diag := int(math.Hypot(float64(b.Dx()), float64(b.Dy()))) + 2
+	rows := make([]float64, diag)
+
+	steps := int(math.Round(deskewMaxAngle / deskewStep))
+	best, bestScore := 0.0, -1.0
+	for i := -steps; i <= steps; i++ {
+		angle := float64(i) * deskewStep
+		// Row of each ink pixel once the page is rotated back by angle
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		clear(rows)
+		for j := range xs {
+			row := int(xs[j]*sin+ys[j]*cos) + diag/2
+			if row >= 0 && row < diag {
+				rows[row]++
+			}
+		}
+
+		var score float64
+		for _, n := range rows {
+			score += n * n
+		}
+		if score > bestScore {
+			best, bestScore = angle, score
+		}
+	}
+	return best
+}
+
+// deskew rotates img to undo a detected skew and crops back to the
+// original size. It returns the correction applied in degrees (0 if none).
+func deskew(img image.Image) (image.Image, float64) {
+	angle := detectSkew(img)
+	if math.Abs(angle) < deskewMinAngle {
+		return img, 0
+	}
+
+	b := img.Bounds()
+	rotated := imaging.Rotate(img, -angle, color.White)
+	return imaging.CropCenter(rotated, b.Dx(), b.Dy()), -angle
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"bytes"
+	"image"
+
+	"compress_comics/internal/analyzer"
+)
+
+// pageBounds tracks the largest short and long side over an archive's
+// pages: every page fits a screen exactly when both maxima do
+type pageBounds struct {
+	short, long int
+}
+
+// add includes a width x height page
+func (b *pageBounds) add(width, height int) {
+	b.short = max(b.short, min(width, height))
+	b.long = max(b.long, max(width, height))
+}
+
+// addPage includes a written page, reading its header when processing
+// passed it through undecoded (width 0)
+func (b *pageBounds) addPage(width, height int, data []byte) {
+	if width == 0 {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			width, height = cfg.Width, cfg.Height
+		}
+	}
+	b.add(width, height)
+}
+
+// analysisBounds are the bounds of the pages an analysis found, which an
+// archive that is skipped keeps
+func analysisBounds(analysis *analyzer.AnalysisResult) pageBounds {
+	var b pageBounds
+	for _, page := range analysis.Pages {
+		b.add(page.Width, page.Height)
+	}
+	return b
+}
+
+// deviceFit checks the pages against every configured device profile:
+// false means some page is larger than that screen and will still be
+// downscaled when read. nil without profiles or page dimensions.
+func (p *Pipeline) deviceFit(b pageBounds) map[string]bool {
+	if len(p.config.DeviceProfiles) == 0 || b.long == 0 {
+		return nil
+	}
+	fit := make(map[string]bool, len(p.config.DeviceProfiles))
+	for _, profile := range p.config.DeviceProfiles {
+		fit[profile.Name] = profile.Fits(b.short, b.long)
+	}
+	return fit
+}
+
+// NeedsDownscale counts, per device profile, the archives with pages larger
+// than its screen
+func (b BatchResult) NeedsDownscale() map[string]int {
+	var counts map[string]int
+	for _, result := range b.Results {
+		for name, fits := range result.DeviceFit {
+			if fits {
+				continue
+			}
+			if counts == nil {
+				counts = make(map[string]int)
+			}
+			counts[name]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"sync"
+
+	"compress_comics/internal/analyzer"
+)
+
+// Event is one step of processing an archive, published on the pipeline's
+// EventBus. Handlers switch on the concrete type.
+type Event interface {
+	Archive() string // Source archive the event belongs to
+}
+
+// AnalysisEvent follows the heuristics deciding whether an archive needs work
+type AnalysisEvent struct {
+	Path     string
+	Analysis *analyzer.AnalysisResult
+}
+
+// ExtractionEvent follows reading an archive's entries into memory
+type ExtractionEvent struct {
+	Path       string
+	Pages      int // Pages to encode (after -pages and strip composition)
+	OtherFiles int // Non-image entries carried over unchanged
+}
+
+// PageEvent follows encoding one page
+type PageEvent struct {
+	Path          string
+	Page          string // Entry name in the source archive
+	NewPath       string // Entry name written (extension may change)
+	Width, Height int    // Output dimensions (0 when the page was passed through undecoded)
+	Quality       int    // Quality chosen for the page
+	Codec         string // Winning codec with -codecs
+	OriginalSize  int64
+	NewSize       int64
+	Resized       bool
+	Converted     bool
+	Err           error // Set when the page failed and was kept unchanged
+}
+
+// VerificationEvent follows the check of a finished compressed archive
+type VerificationEvent struct {
+	Path string
+	Temp string // Verified archive, not yet in place
+	Size int64
+}
+
+// BackupEvent follows moving an original out of the way before replacing it
+type BackupEvent struct {
+	Path   string
+	Backup string // Backup location (trash path or store object in those modes)
+	Mode   string // backup_mode
+}
+
+func (e AnalysisEvent) Archive() string     { return e.Path }
+func (e ExtractionEvent) Archive() string   { return e.Path }
+func (e PageEvent) Archive() string         { return e.Path }
+func (e VerificationEvent) Archive() string { return e.Path }
+func (e BackupEvent) Archive() string       { return e.Path }
+
+// EventBus delivers pipeline events to subscribers. Handlers run
+// synchronously, one at a time even with several workers, so they should
+// return quickly.
+type EventBus struct {
+	mu       sync.Mutex
+	handlers []func(Event)
+}
+
+// Subscribe registers handler for every event published after the call
+func (b *EventBus) Subscribe(handler func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// publish hands e to every subscriber
+func (b *EventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, handler := range b.handlers {
+		handler(e)
+	}
+}
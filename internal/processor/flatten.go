@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/cbz"
+)
+
+// nested reports whether any page sits in a folder inside the archive
+func nested(pages []cbz.ImageEntry) bool {
+	for _, page := range pages {
+		if strings.Contains(page.Path, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// NestedAnalysis is nested for the pages an analysis found, so -flatten rewrites the archive
+func NestedAnalysis(analysis *analyzer.AnalysisResult) bool {
+	for _, page := range analysis.Pages {
+		if strings.Contains(page.Path, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// flatName is the root-level name of page i of total when flattening:
+// its number, zero-padded so names sort in page order, with the
+// extension of the written page
+func flatName(i, total int, written string) string {
+	width := max(3, len(fmt.Sprint(total)))
+	return fmt.Sprintf("%0*d%s", width, i+1, strings.ToLower(path.Ext(written)))
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// PageSize is one page's size before and after compression
+type PageSize struct {
+	Page         string
+	OriginalSize int64
+	NewSize      int64 // Equal to OriginalSize for pages kept as they were
+}
+
+// sparkBlocks are the sparkline levels, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Limits of the verbose per-page summary
+const (
+	sparkWidth    = 60 // Longer archives are folded into this many cells, each showing its largest page
+	topPagesShown = 5  // Largest pages listed with their sizes before and after
+)
+
+// sparkline draws values as block characters, full at scale. When there
+// are more values than width, each cell shows the largest of the values it
+// covers, so a single huge page still stands out.
+func sparkline(values []int64, width int, scale int64) string {
+	cells := values
+	if len(values) > width {
+		cells = make([]int64, width)
+		for i, v := range values {
+			cell := i * width / len(values)
+			cells[cell] = max(cells[cell], v)
+		}
+	}
+
+	var b strings.Builder
+	for _, v := range cells {
+		level := 0
+		if scale > 0 {
+			level = int((min(v, scale)*int64(len(sparkBlocks)-1) + scale/2) / scale)
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// printPageSizes prints a sparkline of page sizes before and after
+// compression, and the largest pages with their share of the archive, so
+// archives dominated by one huge page (a foldout) are easy to spot
+func printPageSizes(w io.Writer, pages []PageSize) {
+	if len(pages) < 2 {
+		return
+	}
+
+	before := make([]int64, len(pages))
+	after := make([]int64, len(pages))
+	var total, largest int64
+	for i, page := range pages {
+		before[i], after[i] = page.OriginalSize, page.NewSize
+		total += page.OriginalSize
+		largest = max(largest, page.OriginalSize, page.NewSize)
+	}
+	// Both lines share a scale, so they can be compared
+	fmt.Fprintf(w, "    before %s\n", sparkline(before, sparkWidth, largest))
+	fmt.Fprintf(w, "    after  %s  (█ = %s)\n", sparkline(after, sparkWidth, largest), FormatBytes(largest))
+
+	// Short archives already list every page above
+	if len(pages) <= topPagesShown {
+		return
+	}
+	top := make([]PageSize, len(pages))
+	copy(top, pages)
+	sort.SliceStable(top, func(i, j int) bool { return top[i].OriginalSize > top[j].OriginalSize })
+	for _, page := range top[:min(topPagesShown, len(top))] {
+		saved := 0.0
+		if page.OriginalSize > 0 {
+			saved = float64(page.OriginalSize-page.NewSize) / float64(page.OriginalSize) * 100
+		}
+		fmt.Fprintf(w, "    %-32s %10s -> %10s  (%.1f%% saved, %.0f%% of archive)\n",
+			truncateString(page.Page, 32), FormatBytes(page.OriginalSize), FormatBytes(page.NewSize),
+			saved, float64(page.OriginalSize)/float64(total)*100)
+	}
+}
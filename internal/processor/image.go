@@ -2,14 +2,23 @@ package processor
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"image"
+	"image/draw"
 	"path/filepath"
 	"strings"
 
+	"compress_comics/internal/analyzer"
 	"compress_comics/internal/cbz"
+	"compress_comics/internal/codec"
+	"compress_comics/internal/config"
+	"compress_comics/internal/fileclass"
 
 	"github.com/disintegration/imaging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ProcessedImage holds the result of processing one image
@@ -20,70 +29,248 @@ type ProcessedImage struct {
 	WasConverted bool
 	OriginalSize int64
 	NewSize      int64
+	Codec        string  // Winning codec when racing codecs (empty otherwise)
+	HighBitDepth bool    // Source had 16 bits per channel
+	CMYK         bool    // Source was a CMYK JPEG (always re-encoded as RGB)
+	Adjusted     bool    // Levels/gamma or deskew were applied (always re-encoded)
+	SkewAngle    float64 // Rotation applied by deskew, degrees counter-clockwise (0 = none)
+	Quality      int     // Encoding quality chosen for the page (before any size fallback)
+	Width        int     // Output dimensions (0 when passed through undecoded)
+	Height       int
+	LowQuality   bool   // JPEG saved below the target quality, passed through unchanged
+	Modern       bool   // Well-compressed WebP/AVIF page, passed through unchanged
+	Kept         string // Why the original bytes were kept (one of the Kept constants; empty = re-encoded)
 }
 
+// Why a page was kept byte for byte instead of re-encoded
+const (
+	KeptPolicy     = "format policy"        // format_policy keeps its format, or process_extensions leaves it out
+	KeptLowQuality = "below target quality" // JPEG saved at a lower quality than the target
+	KeptModern     = "compact WebP/AVIF"    // Under keep_modern_kb_per_mp
+	KeptLarger     = "re-encode larger"     // No re-encoding came out smaller, even at the quality floor
+)
+
 // ImageProcessor handles image resizing and conversion
 type ImageProcessor struct {
 	maxDimension int
 	jpegQuality  int
+	opts         ImageOptions
+}
+
+// ImageOptions holds optional processing behaviour beyond size and quality
+type ImageOptions struct {
+	Limits         DecodeLimits        // Pages over these limits are left unchanged
+	FormatPolicy   cbz.FormatPolicy    // Source formats to pass through instead of converting
+	Extensions     cbz.ExtensionList   // Page extensions processed; others pass through (empty = all)
+	Codecs         []string            // Race these codecs per page and keep the smallest (empty = JPEG only)
+	Dither         bool                // Dither when reducing 16-bit pages to 8 bits
+	Levels         LevelsOptions       // Contrast stage for faded scans
+	Deskew         bool                // Detect and correct small rotations of scanned pages
+	ComposeAspect  float64             // Stack strip slices into pages of this height/width (0 = off)
+	Webtoon        bool                // Clamp only the width of pages, so vertical strips keep their height
+	QualityCurve   config.QualityCurve // JPEG quality adjustment by scale factor
+	Grayscale      bool                // Encode pages as grayscale
+	KeepLowQuality bool                // Pass through JPEGs saved below the target quality
+	KeepModern     float64             // Pass through WebP/AVIF pages under this many KB per megapixel (0 = off)
 }
 
 // NewImageProcessor creates a processor with given settings
-func NewImageProcessor(maxDim, quality int) *ImageProcessor {
+func NewImageProcessor(maxDim, quality int, opts ImageOptions) *ImageProcessor {
 	return &ImageProcessor{
 		maxDimension: maxDim,
 		jpegQuality:  quality,
+		opts:         opts,
 	}
 }
 
 // Process takes a raw image entry and returns processed data
 func (p *ImageProcessor) Process(entry cbz.ImageEntry) (*ProcessedImage, error) {
+	return p.ProcessContext(context.Background(), entry)
+}
+
+// ProcessContext is Process with a per-image span under ctx
+func (p *ImageProcessor) ProcessContext(ctx context.Context, entry cbz.ImageEntry) (*ProcessedImage, error) {
+	_, span := tracer.Start(ctx, "process image", trace.WithAttributes(
+		attribute.String("image.path", entry.Path),
+		attribute.Int64("image.original_size", entry.OriginalSize),
+	))
+	result, err := p.process(entry)
+	if result != nil {
+		span.SetAttributes(
+			attribute.Int64("image.new_size", result.NewSize),
+			attribute.Bool("image.resized", result.WasResized),
+			attribute.Bool("image.converted", result.WasConverted),
+		)
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+// Stages of page processing a PageError can come from
+const (
+	StageLimits = "limits" // Refused by the decode guards
+	StageDecode = "decode"
+	StageEncode = "encode"
+	StagePanic  = "panic" // A decoder or resampler crashed
+)
+
+// PageError locates a failure inside an archive; the page is kept unchanged
+type PageError struct {
+	Page  string // Path of the page inside the archive
+	Stage string // One of the Stage constants
+	Err   error
+}
+
+func (e *PageError) Error() string {
+	switch e.Stage {
+	case StageLimits:
+		return fmt.Sprintf("refusing to decode %s: %v", e.Page, e.Err)
+	case StagePanic:
+		return fmt.Sprintf("panic while processing %s: %v", e.Page, e.Err)
+	default:
+		return fmt.Sprintf("failed to %s %s: %v", e.Stage, e.Page, e.Err)
+	}
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// KeptReason says why the page was kept, for reports next to the Kept reasons
+func (e *PageError) KeptReason() string {
+	switch e.Stage {
+	case StageLimits:
+		return "over decode limits"
+	case StagePanic:
+		return "processing crashed"
+	default:
+		return e.Stage + " failed"
+	}
+}
+
+// process decodes, resizes and re-encodes one image. Oversized pages and
+// decoder panics become errors for this page only; every error is a
+// *PageError.
+func (p *ImageProcessor) process(entry cbz.ImageEntry) (_ *ProcessedImage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PageError{Page: entry.Path, Stage: StagePanic, Err: fmt.Errorf("%v", r)}
+		}
+	}()
+
+	// Formats the policy keeps, and extensions not allowed, are passed through byte for byte
+	if p.keeps(entry.Path) {
+		return &ProcessedImage{
+			NewPath:      entry.Path,
+			Data:         entry.Data,
+			OriginalSize: entry.OriginalSize,
+			NewSize:      entry.OriginalSize,
+			Kept:         KeptPolicy,
+		}, nil
+	}
+
+	if kept := p.keepLowQuality(entry); kept != nil {
+		return kept, nil
+	}
+	if kept := p.keepModern(entry); kept != nil {
+		return kept, nil
+	}
+
+	if err := checkDecodeLimits(entry.Data, p.opts.Limits); err != nil {
+		if errors.As(err, new(headerError)) {
+			return nil, &PageError{Page: entry.Path, Stage: StageDecode, Err: err}
+		}
+		return nil, &PageError{Page: entry.Path, Stage: StageLimits, Err: err}
+	}
+
 	// Decode image with auto-orientation (handles EXIF rotation)
-	img, err := imaging.Decode(bytes.NewReader(entry.Data), imaging.AutoOrientation(true))
+	img, cmyk, err := decodePage(entry)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode %s: %w", entry.Path, err)
+		return nil, &PageError{Page: entry.Path, Stage: StageDecode, Err: err}
 	}
 
 	result := &ProcessedImage{
 		OriginalSize: entry.OriginalSize,
+		CMYK:         cmyk,
+	}
+
+	// Reduce 16-bit pages explicitly, before resizing would truncate them
+	if isHighBitDepth(img) {
+		img = reduceBitDepth(img, p.opts.Dither)
+		result.HighBitDepth = true
 	}
 
 	// Determine new filename (convert non-JPEG to .jpg)
-	ext := strings.ToLower(filepath.Ext(entry.Path))
-	if ext != ".jpg" && ext != ".jpeg" {
+	if fileclass.FormatOf(entry.Path) != "jpeg" {
 		// Change extension to .jpg
-		result.NewPath = strings.TrimSuffix(entry.Path, ext) + ".jpg"
+		result.NewPath = strings.TrimSuffix(entry.Path, filepath.Ext(entry.Path)) + ".jpg"
 		result.WasConverted = true
 	} else {
 		result.NewPath = entry.Path
 	}
 
+	// Straighten before Fit, so the page uses the full resolution budget
+	if p.opts.Deskew {
+		img, result.SkewAngle = deskew(img)
+		if result.SkewAngle != 0 {
+			result.Adjusted = true
+		}
+	}
+
 	// Check if resize needed
 	bounds := img.Bounds()
 	width := bounds.Dx()
 	height := bounds.Dy()
 
-	if width > p.maxDimension || height > p.maxDimension {
+	if p.oversized(width, height) {
 		// Use Fit to resize while preserving aspect ratio
 		// Lanczos filter provides best quality for photographic content
-		img = imaging.Fit(img, p.maxDimension, p.maxDimension, imaging.Lanczos)
+		img = imaging.Fit(img, p.maxDimension, p.maxHeight(), imaging.Lanczos)
 		result.WasResized = true
 	}
 
+	// Quality follows the scale factor when a curve is configured
+	scaled := img.Bounds().Size()
+	result.Width, result.Height = scaled.X, scaled.Y
+	scale := float64(max(scaled.X, scaled.Y)) / float64(max(width, height))
+	result.Quality = p.opts.QualityCurve.Quality(scale, p.jpegQuality)
+
+	// Levels run after resizing: fewer pixels, same histogram
+	if p.opts.Levels.Active() {
+		img = adjustLevels(img, p.opts.Levels)
+		result.Adjusted = true
+	}
+
+	// A gray image encodes as a single-channel JPEG
+	if p.opts.Grayscale {
+		img = toGray(img)
+		result.Adjusted = true
+	}
+
+	if len(p.opts.Codecs) > 0 {
+		return p.race(img, entry, result)
+	}
+
 	// Encode as JPEG at target quality
-	newData, err := p.encodeJPEG(img, p.jpegQuality)
+	newData, err := p.encodeJPEG(img, result.Quality)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode %s: %w", entry.Path, err)
+		return nil, &PageError{Page: entry.Path, Stage: StageEncode, Err: err}
 	}
 	newSize := int64(len(newData))
 
-	isAlreadyJPEG := ext == ".jpg" || ext == ".jpeg"
+	isAlreadyJPEG := fileclass.FormatOf(entry.Path) == "jpeg"
+
+	// A page that needed no changes can stay as it is if readers display
+	// its format
+	keepable := displayFormats[fileclass.FormatOf(entry.Path)] && !result.WasResized && !result.CMYK && !result.Adjusted
 
 	// If the new file is LARGER than original, we have a problem.
-	// Try adaptive quality reduction to get it smaller.
-	if newSize > entry.OriginalSize {
+	// Try adaptive quality reduction to get it smaller. Keepable non-JPEG
+	// pages (flat-color PNG line art) skip this: a lossless original beats
+	// a JPEG pushed below the target quality.
+	if newSize > entry.OriginalSize && (isAlreadyJPEG || !keepable) {
 		// Try progressively lower quality until smaller or hit minimum (60)
-		for quality := p.jpegQuality - 5; quality >= 60; quality -= 5 {
+		for quality := result.Quality - 5; quality >= 60; quality -= 5 {
 			attemptData, err := p.encodeJPEG(img, quality)
 			if err != nil {
 				break
@@ -100,12 +287,14 @@ func (p *ImageProcessor) Process(entry cbz.ImageEntry) (*ProcessedImage, error)
 		}
 	}
 
-	// Final check: if still larger and it was already a JPEG, keep original
-	if newSize >= entry.OriginalSize && isAlreadyJPEG && !result.WasResized {
+	// Final check: if still larger, keep the original in its own format
+	if newSize >= entry.OriginalSize && keepable {
 		result.Data = entry.Data
 		result.NewSize = entry.OriginalSize
 		result.NewPath = entry.Path
 		result.WasConverted = false
+		result.HighBitDepth = false
+		result.Kept = KeptLarger
 		return result, nil
 	}
 
@@ -115,6 +304,83 @@ func (p *ImageProcessor) Process(entry cbz.ImageEntry) (*ProcessedImage, error)
 	return result, nil
 }
 
+// keeps reports whether a page passes through by configuration: its format
+// is kept by the format policy or its extension is not allowed
+func (p *ImageProcessor) keeps(name string) bool {
+	return p.opts.FormatPolicy.Keeps(name) || !p.opts.Extensions.Allows(name)
+}
+
+// keepLowQuality passes a JPEG through when it was saved at a lower quality
+// than the target and needs no other change: re-encoding it would only add
+// generation loss. Returns nil when the page must be processed.
+func (p *ImageProcessor) keepLowQuality(entry cbz.ImageEntry) *ProcessedImage {
+	if !p.opts.KeepLowQuality || p.opts.Deskew || p.opts.Grayscale || p.opts.Levels.Active() {
+		return nil
+	}
+	info, err := codec.ParseJPEG(entry.Data)
+	if err != nil || info.CMYK() || info.Quality == 0 || p.oversized(info.Width, info.Height) {
+		return nil
+	}
+	if info.Quality >= p.opts.QualityCurve.Quality(1, p.jpegQuality) {
+		return nil
+	}
+	return &ProcessedImage{
+		NewPath:      entry.Path,
+		Data:         entry.Data,
+		OriginalSize: entry.OriginalSize,
+		NewSize:      entry.OriginalSize,
+		Quality:      info.Quality,
+		Width:        info.Width,
+		Height:       info.Height,
+		LowQuality:   true,
+		Kept:         KeptLowQuality,
+	}
+}
+
+// keepModern passes a WebP or AVIF page through when it needs no resize
+// and is already compressed below the KeepModern bar: as JPEG it would come
+// out bigger and worse. Returns nil when the page must be processed.
+func (p *ImageProcessor) keepModern(entry cbz.ImageEntry) *ProcessedImage {
+	if p.opts.KeepModern <= 0 || !fileclass.IsModern(entry.Path) || p.opts.Deskew || p.opts.Grayscale || p.opts.Levels.Active() {
+		return nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(entry.Data))
+	if err != nil || p.oversized(cfg.Width, cfg.Height) || !analyzer.UnderModernBar(entry.OriginalSize, cfg.Width, cfg.Height, p.opts.KeepModern) {
+		return nil
+	}
+	return &ProcessedImage{
+		NewPath:      entry.Path,
+		Data:         entry.Data,
+		OriginalSize: entry.OriginalSize,
+		NewSize:      entry.OriginalSize,
+		Width:        cfg.Width,
+		Height:       cfg.Height,
+		Modern:       true,
+		Kept:         KeptModern,
+	}
+}
+
+// displayFormats are the page formats every comic reader displays; pages in
+// other formats are converted even when that makes them larger
+var displayFormats = map[string]bool{
+	"jpeg": true,
+	"png":  true,
+	"gif":  true,
+	"webp": true,
+}
+
+// decodePage decodes a page with EXIF auto-orientation. CMYK JPEGs take a
+// dedicated path that converts them to RGB (see codec.DecodeCMYK).
+func decodePage(entry cbz.ImageEntry) (image.Image, bool, error) {
+	if info, err := codec.ParseJPEG(entry.Data); err == nil && info.CMYK() {
+		img, err := codec.DecodeCMYK(entry.Data, info)
+		return img, true, err
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(entry.Data), imaging.AutoOrientation(true))
+	return img, false, err
+}
+
 // encodeJPEG encodes image as JPEG at given quality
 func (p *ImageProcessor) encodeJPEG(img image.Image, quality int) ([]byte, error) {
 	var buf bytes.Buffer
@@ -125,18 +391,41 @@ func (p *ImageProcessor) encodeJPEG(img image.Image, quality int) ([]byte, error
 	return buf.Bytes(), nil
 }
 
+// maxHeight is the height pages are fitted into: the max dimension, or for
+// webtoon strips only what JPEG can hold
+func (p *ImageProcessor) maxHeight() int {
+	if p.opts.Webtoon {
+		return codec.MaxJPEGDimension
+	}
+	return p.maxDimension
+}
+
+// oversized reports whether a width x height page must be scaled down
+func (p *ImageProcessor) oversized(width, height int) bool {
+	return width > p.maxDimension || height > p.maxHeight()
+}
+
 // ShouldProcess returns true if this image needs processing
 func (p *ImageProcessor) ShouldProcess(entry cbz.ImageEntry, width, height int) bool {
 	// Check if resize needed
-	if width > p.maxDimension || height > p.maxDimension {
+	if p.oversized(width, height) {
 		return true
 	}
 
 	// Check if format conversion needed
-	ext := strings.ToLower(filepath.Ext(entry.Path))
-	if ext != ".jpg" && ext != ".jpeg" {
+	if fileclass.FormatOf(entry.Path) != "jpeg" {
 		return true
 	}
 
 	return false
 }
+
+// toGray converts img to 8-bit grayscale
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray
+}
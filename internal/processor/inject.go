@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Stages -inject-failure can fail
+const (
+	StageWrite   = "write"   // Building the compressed archive
+	StageVerify  = "verify"  // Verifying the compressed archive
+	StageBackup  = "backup"  // Moving the original to backup
+	StageRename  = "rename"  // Moving the compressed archive into place
+	StageRestore = "restore" // The rename, and then putting the original back
+)
+
+// InjectStages lists every stage, in the order a swap reaches them
+var InjectStages = []string{StageWrite, StageVerify, StageBackup, StageRename, StageRestore}
+
+// ErrInjected marks a failure raised on purpose by -inject-failure
+var ErrInjected = errors.New("injected failure")
+
+// FailureInjection makes one stage of compressing an archive fail on
+// purpose, so the cleanup, restore and rollback paths can be exercised on a
+// real library before trusting them with it
+type FailureInjection struct {
+	Stage string
+	File  string // Glob on the archive's base name (empty = every archive)
+}
+
+// ParseFailureInjection parses "stage=NAME[,file=GLOB]"
+func ParseFailureInjection(spec string) (*FailureInjection, error) {
+	f := &FailureInjection{}
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid -inject-failure %q (want stage=NAME[,file=GLOB])", spec)
+		}
+		switch key {
+		case "stage":
+			if !slices.Contains(InjectStages, value) {
+				return nil, fmt.Errorf("unknown stage %q (must be one of %s)", value, strings.Join(InjectStages, ", "))
+			}
+			f.Stage = value
+		case "file":
+			if _, err := filepath.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid file pattern %q: %w", value, err)
+			}
+			f.File = value
+		default:
+			return nil, fmt.Errorf("unknown -inject-failure key %q (must be stage or file)", key)
+		}
+	}
+	if f.Stage == "" {
+		return nil, fmt.Errorf("invalid -inject-failure %q: stage is required", spec)
+	}
+	return f, nil
+}
+
+// String formats f the way ParseFailureInjection reads it
+func (f *FailureInjection) String() string {
+	if f.File == "" {
+		return "stage=" + f.Stage
+	}
+	return "stage=" + f.Stage + ",file=" + f.File
+}
+
+// fails reports whether stage should fail for the archive at cbzPath. A
+// failed restore needs a failed rename first, so restore fails both.
+func (f *FailureInjection) fails(stage, cbzPath string) bool {
+	if f == nil {
+		return false
+	}
+	if f.Stage != stage && !(f.Stage == StageRestore && stage == StageRename) {
+		return false
+	}
+	if f.File == "" {
+		return true
+	}
+	ok, _ := filepath.Match(f.File, filepath.Base(cbzPath))
+	return ok
+}
+
+// InjectFailure makes the pipeline fail f's stage on purpose. Call it
+// before processing starts.
+func (p *Pipeline) InjectFailure(f *FailureInjection) {
+	p.inject = f
+}
+
+// injected returns an ErrInjected error when stage is to fail for cbzPath
+func (p *Pipeline) injected(stage, cbzPath string) error {
+	if !p.inject.fails(stage, cbzPath) {
+		return nil
+	}
+	return fmt.Errorf("%w at stage %s", ErrInjected, stage)
+}
+
+// restore puts the original of cbzPath back from backup
+func (p *Pipeline) restore(cbzPath string) error {
+	if err := p.injected(StageRestore, cbzPath); err != nil {
+		return err
+	}
+	return p.backup.RestoreFromBackup(cbzPath)
+}
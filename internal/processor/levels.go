@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"image"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/config"
+
+	"github.com/disintegration/imaging"
+)
+
+// LevelsOptions configures the contrast stage for faded scans
+type LevelsOptions struct {
+	AutoLevels  bool    // Stretch the histogram so the darkest/brightest pixels reach black/white
+	ClipPercent float64 // Share of pixels ignored at each end when finding black/white points
+	Gamma       float64 // Midtone gamma after stretching (1 = unchanged, >1 brightens)
+}
+
+// Active reports whether the stage changes pixels
+func (o LevelsOptions) Active() bool {
+	return o.AutoLevels || (o.Gamma > 0 && o.Gamma != 1)
+}
+
+// adjustLevels applies one lookup table to R, G and B, so hues are kept.
+// Black and white points come from the luminance histogram.
+func adjustLevels(img image.Image, opts LevelsOptions) *image.NRGBA {
+	dst := imaging.Clone(img)
+
+	low, high := 0, 255
+	if opts.AutoLevels {
+		low, high = levelBounds(dst, opts.ClipPercent)
+	}
+
+	gamma := opts.Gamma
+	if gamma <= 0 {
+		gamma = 1
+	}
+
+	var lut [256]uint8
+	for v := range lut {
+		x := (float64(v) - float64(low)) / float64(high-low)
+		x = math.Min(math.Max(x, 0), 1)
+		lut[v] = uint8(math.Round(math.Pow(x, 1/gamma) * 255))
+	}
+
+	for i := 0; i < len(dst.Pix); i += 4 {
+		dst.Pix[i] = lut[dst.Pix[i]]
+		dst.Pix[i+1] = lut[dst.Pix[i+1]]
+		dst.Pix[i+2] = lut[dst.Pix[i+2]]
+	}
+	return dst
+}
+
+// levelBounds returns the luminance values below and above which clipPercent
+// of pixels fall; a flat image returns the full range
+func levelBounds(img *image.NRGBA, clipPercent float64) (int, int) {
+	var hist [256]int
+	for i := 0; i < len(img.Pix); i += 4 {
+		// Rec. 601 luma in integer arithmetic
+		y := (299*int(img.Pix[i]) + 587*int(img.Pix[i+1]) + 114*int(img.Pix[i+2])) / 1000
+		hist[y]++
+	}
+
+	total := len(img.Pix) / 4
+	clip := int(float64(total) * clipPercent / 100)
+
+	low, count := 0, 0
+	for ; low < 255; low++ {
+		count += hist[low]
+		if count > clip {
+			break
+		}
+	}
+	high, count := 255, 0
+	for ; high > 0; high-- {
+		count += hist[high]
+		if count > clip {
+			break
+		}
+	}
+
+	if high <= low {
+		return 0, 255
+	}
+	return low, high
+}
+
+// levelsApply reports whether pages of this archive get levels or gamma adjusted
+func (p *Pipeline) levelsApply(cbzPath string) bool {
+	return p.processor.opts.Levels.Active() || matchesDir(cbzPath, p.config.AutoLevelsDirs)
+}
+
+// LevelsApply is levelsApply for a run with cfg, without a pipeline
+func LevelsApply(cfg config.Config, cbzPath string) bool {
+	return ImageOptionsFromConfig(cfg).Levels.Active() || matchesDir(cbzPath, cfg.AutoLevelsDirs)
+}
+
+// matchesDir reports whether any pattern matches the archive's directory.
+// Patterns with a separator are matched against the whole directory path;
+// plain names (e.g. "Golden Age*") against each of its directory names.
+func matchesDir(cbzPath string, patterns []string) bool {
+	dir := filepath.Dir(absPath(cbzPath))
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, `/\`) {
+			if ok, _ := filepath.Match(filepath.FromSlash(pattern), dir); ok {
+				return true
+			}
+			continue
+		}
+		for d := dir; ; d = filepath.Dir(d) {
+			if ok, _ := filepath.Match(pattern, filepath.Base(d)); ok {
+				return true
+			}
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"compress_comics/internal/config"
+	"compress_comics/internal/fsutil"
+)
+
+// mountQueue hands out archives in scan order, except that an archive on a
+// filesystem already at its mount_limits limit waits while archives on
+// other filesystems go ahead, so workers don't all block on the slowest disk
+type mountQueue struct {
+	mu      sync.Mutex
+	limits  map[string]int    // Device -> most archives in flight
+	active  map[string]int    // Device -> archives in flight
+	pending map[string][]int  // Device -> indexes of waiting archives, in order ("" = unlimited)
+	devices map[string]string // Archive in flight -> device
+	freed   chan struct{}     // Signaled when a slot frees up
+}
+
+// newMountQueue groups files by the limited filesystem they are on.
+// Archives whose device can't be determined are not limited; processing
+// reports why they can't be read.
+func newMountQueue(files []string, limits config.MountLimits) (*mountQueue, error) {
+	q := &mountQueue{
+		limits:  make(map[string]int),
+		active:  make(map[string]int),
+		pending: make(map[string][]int),
+		devices: make(map[string]string),
+		freed:   make(chan struct{}, 1),
+	}
+	for path, limit := range limits {
+		device, err := fsutil.DeviceOf(path)
+		if err != nil {
+			return nil, fmt.Errorf("mount_limits: %w", err)
+		}
+		// Two paths on one filesystem: the stricter limit wins
+		if current, ok := q.limits[device]; !ok || limit < current {
+			q.limits[device] = limit
+		}
+	}
+
+	dirDevices := make(map[string]string) // One stat per directory
+	for i, path := range files {
+		dir := filepath.Dir(path)
+		device, ok := dirDevices[dir]
+		if !ok {
+			device, _ = fsutil.DeviceOf(dir)
+			if _, limited := q.limits[device]; !limited {
+				device = ""
+			}
+			dirDevices[dir] = device
+		}
+		q.pending[device] = append(q.pending[device], i)
+	}
+	return q, nil
+}
+
+// next returns the index of the earliest waiting archive whose filesystem
+// has a free slot, blocking until there is one. It returns false once every
+// archive has been handed out, or when stop is closed.
+func (q *mountQueue) next(files []string, stop <-chan struct{}) (int, bool) {
+	for {
+		q.mu.Lock()
+		best, bestDevice, waiting := -1, "", false
+		for device, indexes := range q.pending {
+			if len(indexes) == 0 {
+				continue
+			}
+			waiting = true
+			if limit, ok := q.limits[device]; ok && q.active[device] >= limit {
+				continue
+			}
+			if best < 0 || indexes[0] < best {
+				best, bestDevice = indexes[0], device
+			}
+		}
+		if best >= 0 {
+			q.pending[bestDevice] = q.pending[bestDevice][1:]
+			if bestDevice != "" {
+				q.active[bestDevice]++
+				q.devices[files[best]] = bestDevice
+			}
+		}
+		q.mu.Unlock()
+
+		if best >= 0 {
+			return best, true
+		}
+		if !waiting {
+			return 0, false
+		}
+		select {
+		case <-q.freed:
+		case <-stop:
+			return 0, false
+		}
+	}
+}
+
+// release frees the slot of a finished archive
+func (q *mountQueue) release(path string) {
+	q.mu.Lock()
+	device, ok := q.devices[path]
+	if ok {
+		delete(q.devices, path)
+		q.active[device]--
+	}
+	q.mu.Unlock()
+
+	if ok {
+		select {
+		case q.freed <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"time"
+
+	"compress_comics/internal/analyzer"
+)
+
+// MultiReporter forwards every event to each of its reporters in order, so
+// console output, a JSON log and metrics can watch the same run
+type MultiReporter struct {
+	reporters []ProgressReporter
+}
+
+// NewMultiReporter combines reporters; nil reporters are dropped
+func NewMultiReporter(reporters ...ProgressReporter) *MultiReporter {
+	m := &MultiReporter{}
+	for _, r := range reporters {
+		m.Add(r)
+	}
+	return m
+}
+
+// Add attaches another reporter; nil is ignored
+func (m *MultiReporter) Add(reporter ProgressReporter) {
+	if reporter != nil {
+		m.reporters = append(m.reporters, reporter)
+	}
+}
+
+func (m *MultiReporter) OnFileStart(path string, index, total int) {
+	for _, r := range m.reporters {
+		r.OnFileStart(path, index, total)
+	}
+}
+
+func (m *MultiReporter) OnFileSkipped(path string, reason string) {
+	for _, r := range m.reporters {
+		r.OnFileSkipped(path, reason)
+	}
+}
+
+func (m *MultiReporter) OnImageProcessed(imagePath string, originalSize, newSize int64) {
+	for _, r := range m.reporters {
+		r.OnImageProcessed(imagePath, originalSize, newSize)
+	}
+}
+
+func (m *MultiReporter) OnPageProgress(path string, progress PageProgress) {
+	for _, r := range m.reporters {
+		r.OnPageProgress(path, progress)
+	}
+}
+
+func (m *MultiReporter) OnFileComplete(result Result) {
+	for _, r := range m.reporters {
+		r.OnFileComplete(result)
+	}
+}
+
+func (m *MultiReporter) OnBatchComplete(result BatchResult) {
+	for _, r := range m.reporters {
+		r.OnBatchComplete(result)
+	}
+}
+
+func (m *MultiReporter) OnDryRunFile(result *analyzer.AnalysisResult) {
+	for _, r := range m.reporters {
+		r.OnDryRunFile(result)
+	}
+}
+
+func (m *MultiReporter) OnBatchProgress(processedBytes, totalBytes int64, elapsed time.Duration) {
+	for _, r := range m.reporters {
+		r.OnBatchProgress(processedBytes, totalBytes, elapsed)
+	}
+}
+
+func (m *MultiReporter) OnDryRunComplete(summary *analyzer.DryRunSummary) {
+	for _, r := range m.reporters {
+		r.OnDryRunComplete(summary)
+	}
+}
@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Policies for an output archive that already exists (-on-collision)
+const (
+	CollisionSkip      = "skip"      // Leave the existing archive and skip the source
+	CollisionOverwrite = "overwrite" // Replace the existing archive
+	CollisionVersion   = "version"   // Write "<name> (2).cbz", "<name> (3).cbz", ...
+)
+
+// ParseCollisionPolicy validates an -on-collision value
+func ParseCollisionPolicy(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case CollisionSkip, CollisionOverwrite, CollisionVersion:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown collision policy %q (must be skip, overwrite or version)", s)
+}
+
+// DefaultOutputName mirrors the input tree under the output directory
+const DefaultOutputName = "{dir}/{name}{ext}"
+
+// outputToken matches the placeholders of an output name template
+var outputToken = regexp.MustCompile(`\{[^}]*\}`)
+
+// ValidateOutputName checks that a template only uses known placeholders:
+// {dir} (directory relative to the input root), {series} (name of the
+// archive's directory), {name} (file name without extension) and {ext}
+func ValidateOutputName(tmpl string) error {
+	if !strings.Contains(tmpl, "{name}") {
+		return fmt.Errorf("output name %q must contain {name}", tmpl)
+	}
+	for _, token := range outputToken.FindAllString(tmpl, -1) {
+		switch token {
+		case "{dir}", "{series}", "{name}", "{ext}":
+		default:
+			return fmt.Errorf("output name %q: unknown placeholder %s", tmpl, token)
+		}
+	}
+	return nil
+}
+
+// mirrorPath places cbzPath in the output directory by the name template.
+// The directory is relative to the input root the archive was found under,
+// or to its own directory for single files.
+func (p *Pipeline) mirrorPath(cbzPath, ext string) (string, error) {
+	abs := absPath(cbzPath)
+	root, ok := p.roots[abs]
+	if !ok {
+		root = filepath.Dir(abs)
+	}
+	rel, err := filepath.Rel(absPath(root), filepath.Dir(abs))
+	if err != nil {
+		rel = "."
+	}
+
+	tmpl := p.config.OutputName
+	if tmpl == "" {
+		tmpl = DefaultOutputName
+	}
+	name := strings.NewReplacer(
+		"{dir}", filepath.ToSlash(rel),
+		"{series}", filepath.Base(filepath.Dir(abs)),
+		"{name}", strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs)),
+		"{ext}", ext,
+	).Replace(tmpl)
+
+	outDir := absPath(p.config.OutputDir)
+	dest := filepath.Join(outDir, filepath.FromSlash(name))
+	if r, err := filepath.Rel(outDir, dest); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("output name %q puts %s outside %s", tmpl, filepath.Base(cbzPath), outDir)
+	}
+	return dest, nil
+}
+
+// resolveCollision applies the collision policy to an existing dest,
+// returning the path to write or a skip reason. Renamed copies next to the
+// original (-rename-to-cbz) are always skipped.
+func (p *Pipeline) resolveCollision(dest string) (string, string) {
+	if _, err := os.Stat(dest); err != nil {
+		return dest, ""
+	}
+
+	policy := p.config.OnCollision
+	if p.config.OutputDir == "" {
+		policy = CollisionSkip
+	}
+	switch policy {
+	case CollisionOverwrite:
+		return dest, ""
+	case CollisionVersion:
+		ext := filepath.Ext(dest)
+		stem := strings.TrimSuffix(dest, ext)
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s (%d)%s", stem, n, ext)
+			if _, err := os.Stat(candidate); err != nil {
+				return candidate, ""
+			}
+		}
+	}
+	return dest, fmt.Sprintf("%s already exists", filepath.Base(dest))
+}
@@ -1,10 +1,13 @@
 package processor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,20 +16,46 @@ import (
 	"compress_comics/internal/backup"
 	"compress_comics/internal/cbz"
 	"compress_comics/internal/config"
+	"compress_comics/internal/fsutil"
+	"compress_comics/internal/ignore"
+	"compress_comics/internal/journal"
+	"compress_comics/internal/override"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Result tracks the outcome of processing a single CBZ
 type Result struct {
 	SourcePath      string
 	OutputPath      string
+	Copy            bool            // OutputPath is a copy; the original stays where it was (see writesCopy)
+	Parts           []string        // Per-chapter archives a merged archive was split into (OutputPath is the first)
+	DeviceFit       map[string]bool // Device profile -> every written (or kept) page fits its screen
 	OriginalSize    int64
 	CompressedSize  int64
 	ImagesProcessed int
 	ImagesSkipped   int
 	PNGsConverted   int
+	CodecWins       map[string]int     // Pages won per codec when racing codecs
+	HighBitDepth    int                // Pages decoded from 16 bits per channel
+	CMYKPages       int                // CMYK JPEG pages converted to RGB
+	LowQualityPages int                // JPEG pages below the target quality, kept to avoid generation loss
+	ModernPages     int                // WebP/AVIF pages below keep_modern_kb_per_mp, kept as they are
+	Deskewed        map[string]float64 // Page path -> rotation applied by deskew (degrees)
+	KeptPages       map[string]string  // Page path -> why its original bytes were kept (a Kept constant or PageError.KeptReason)
+	PageSizes       []PageSize         // Every page's size before and after, in archive order
+	StripsComposed  int                // Strip slices stacked into composed pages
+	ComposedPages   int                // Pages built from strip slices
 	Skipped         bool
 	SkipReason      string
+	SkipKind        string // SkipReason's category (analyzer.SkipOptimized etc. or SkipExists)
 	Errors          []error
+	PageErrors      []PageError // Pages kept unchanged because they failed (the archive is still written)
+	UnsafePaths     []string    // Absolute or traversing entry paths written normalized, as "original -> normalized"
+	SalvagedPages   []string    // Corrupt input pages kept because they still decode (-salvage), with the damage
+	DroppedEntries  []string    // Corrupt input entries left out (-salvage), with the reason
+	Warnings        []string    // Problems that left the written archive intact, e.g. a failed sample export
 	Duration        time.Duration
 	Analysis        *analyzer.AnalysisResult // For dry-run reporting
 	Index           int                      // Progress: current file index (1-based)
@@ -42,6 +71,10 @@ type BatchResult struct {
 	ProcessedFiles  int
 	SkippedFiles    int
 	FailedFiles     int
+	IgnoredFiles    int    // Archives left out of the scan by skip_patterns and exclude files
+	NotAttempted    int    // Files never started because the batch was aborted
+	Aborted         bool   // Batch stopped early (failure budget exceeded)
+	AbortReason     string // Why the batch stopped early
 	TotalDuration   time.Duration
 }
 
@@ -64,38 +97,241 @@ type ProgressReporter interface {
 	OnFileStart(path string, index, total int)
 	OnFileSkipped(path string, reason string)
 	OnImageProcessed(imagePath string, originalSize, newSize int64)
+	OnPageProgress(path string, progress PageProgress)
 	OnFileComplete(result Result)
 	OnBatchComplete(result BatchResult)
+	// OnBatchProgress reports the bytes of the batch's archives processed
+	// so far, out of totalBytes, after each archive and periodically while
+	// pages encode (see EstimateRemaining)
+	OnBatchProgress(processedBytes, totalBytes int64, elapsed time.Duration)
 	OnDryRunFile(result *analyzer.AnalysisResult)
 	OnDryRunComplete(summary *analyzer.DryRunSummary)
 }
 
+// PageProgress reports how far encoding of one archive has got. It is sent
+// every pageProgressInterval, so only archives slow enough to look stalled
+// report it.
+type PageProgress struct {
+	Done       int   // Pages encoded
+	Total      int   // Pages in the archive
+	BytesDone  int64 // Original bytes of the pages encoded
+	BytesTotal int64 // Original bytes of all pages
+}
+
+// pageProgressInterval is the minimum time between PageProgress reports
+const pageProgressInterval = 10 * time.Second
+
 // Pipeline orchestrates the full compression process
 type Pipeline struct {
 	config    config.Config
 	reader    *cbz.Reader
 	writer    *cbz.Writer
 	processor *ImageProcessor
+	levels    *ImageProcessor // processor with auto-levels, for archives under AutoLevelsDirs
 	analyzer  *analyzer.Analyzer
+	checks    []analyzer.Heuristic
+	excluded  string // Skip reason when an override excludes the archive
 	backup    *backup.Manager
+	journal   *journal.Journal
 	reporter  ProgressReporter
+	copySlots chan struct{} // Limits concurrent network copies with LocalCopy (nil = off)
+	events    *EventBus
+	roots     map[string]string // Archive (absolute) -> input directory FindFiles found it under
+	ignored   int               // Archives FindFiles left out by skip_patterns and exclude files
+	tracker   *batchProgress    // Bytes processed in the running batch (nil outside one)
+	swaps     *swapGate         // Originals being replaced, for Abort
+	inject    *FailureInjection // Stage to fail on purpose (-inject-failure; nil = none)
 }
 
 // NewPipeline creates a configured pipeline
 func NewPipeline(cfg config.Config, reporter ProgressReporter) *Pipeline {
-	return &Pipeline{
-		config:    cfg,
-		reader:    cbz.NewReader(),
-		writer:    cbz.NewWriter(),
-		processor: NewImageProcessor(cfg.MaxDimension, cfg.JPEGQuality),
-		analyzer:  analyzer.NewAnalyzer(cfg.MaxDimension, cfg.ThresholdMBPage),
-		backup:    backup.NewManager(cfg.BackupDir),
-		reporter:  reporter,
+	// Local copies also mean building archives locally
+	tempDir := cfg.TempDir
+	if cfg.LocalCopy && tempDir == "" {
+		tempDir = os.TempDir()
 	}
+	p := &Pipeline{
+		reader:   cbz.NewReader(),
+		writer:   cbz.NewWriter(cbz.WriterOptions{TempDir: tempDir, Durable: cfg.Durable, Level: cfg.ZipLevel, Store: cfg.ZipMethod == cbz.ZipMethodStore}),
+		backup:   backup.NewManager(cfg.BackupDir, backup.Mode(cfg.BackupMode)),
+		journal:  journal.New(journal.DefaultPath(cfg.BackupDir)),
+		reporter: reporter,
+		events:   &EventBus{},
+		roots:    make(map[string]string),
+		swaps:    &swapGate{},
+	}
+	if cfg.LocalCopy {
+		p.copySlots = make(chan struct{}, max(1, cfg.CopyWorkers))
+	}
+	p.configure(cfg, "")
+	return p
+}
+
+// configure builds the analyzer and image processors for cfg. A non-empty
+// excluded reason skips every archive (see forArchive).
+func (p *Pipeline) configure(cfg config.Config, excluded string) {
+	imageOpts := ImageOptionsFromConfig(cfg)
+	levelsOpts := imageOpts
+	levelsOpts.Levels.AutoLevels = true
+
+	p.config = cfg
+	p.excluded = excluded
+	p.processor = NewImageProcessor(cfg.MaxDimension, cfg.JPEGQuality, imageOpts)
+	p.levels = NewImageProcessor(cfg.MaxDimension, cfg.JPEGQuality, levelsOpts)
+	p.analyzer = analyzer.NewAnalyzer(cfg.MaxDimension, cfg.ThresholdMBPage, analyzer.Options{
+		IgnoreMarker:  cfg.IgnoreMarker,
+		FormatPolicy:  cfg.FormatPolicy,
+		Extensions:    cfg.ProcessExtensions,
+		ComposeAspect: cfg.ComposeAspect,
+		Webtoon:       cfg.Webtoon,
+		Excluded:      excluded,
+		KeepModern:    cfg.KeepModernKBPerMP,
+		Heuristics:    p.checks,
+	})
+}
+
+// ImageOptionsFromConfig gathers the page processing settings of cfg
+func ImageOptionsFromConfig(cfg config.Config) ImageOptions {
+	return ImageOptions{
+		Limits:         DecodeLimitsFromConfig(cfg),
+		FormatPolicy:   cfg.FormatPolicy,
+		Extensions:     cfg.ProcessExtensions,
+		Codecs:         cfg.Codecs,
+		Dither:         cfg.Dither,
+		Deskew:         cfg.Deskew,
+		ComposeAspect:  cfg.ComposeAspect,
+		QualityCurve:   cfg.QualityCurve,
+		Webtoon:        cfg.Webtoon,
+		Grayscale:      cfg.Grayscale,
+		KeepLowQuality: cfg.KeepLowQuality,
+		KeepModern:     cfg.KeepModernKBPerMP,
+		Levels: LevelsOptions{
+			AutoLevels:  cfg.AutoLevels,
+			ClipPercent: cfg.LevelsClipPercent,
+			Gamma:       cfg.Gamma,
+		},
+	}
+}
+
+// AddReporter attaches another reporter alongside the one the pipeline was
+// created with. Call it before processing starts.
+func (p *Pipeline) AddReporter(reporter ProgressReporter) {
+	switch current := p.reporter.(type) {
+	case nil:
+		p.reporter = reporter
+	case *MultiReporter:
+		current.Add(reporter)
+	default:
+		p.reporter = NewMultiReporter(current, reporter)
+	}
+}
+
+// AddHeuristic adds a skip/process rule the analyzer consults for every
+// archive after those added before it (see analyzer.Heuristic). Call it
+// before processing starts.
+func (p *Pipeline) AddHeuristic(h analyzer.Heuristic) {
+	p.checks = append(p.checks, h)
+	p.configure(p.config, p.excluded)
+}
+
+// Backups returns where each original this pipeline replaced was backed
+// up to (see backup.Manager.Locations)
+func (p *Pipeline) Backups() map[string]string {
+	return p.backup.Locations()
+}
+
+// Events returns the bus the pipeline publishes per-stage events on
+func (p *Pipeline) Events() *EventBus {
+	return p.events
+}
+
+// forArchive returns the pipeline for cbzPath: p itself, or a copy with the
+// archive's override file applied
+func (p *Pipeline) forArchive(cbzPath string) (*Pipeline, error) {
+	ov, err := override.Resolve(cbzPath, p.roots[absPath(cbzPath)], p.config.SeriesRules, p.config.ComicInfoRules)
+	if err != nil || ov == nil {
+		return p, err
+	}
+	archive := *p
+	archive.configure(ov.Apply(p.config), ov.SkipReason())
+	return &archive, nil
+}
+
+// DryRun returns a copy of p that only analyzes, with -force off so the
+// heuristics' verdicts show. It shares p's roots, heuristics, reporter and
+// journal, so it sees the same per-root settings and needs no Close of its own.
+func (p *Pipeline) DryRun() *Pipeline {
+	cfg := p.config
+	cfg.DryRun = true
+	cfg.Force = false
+	preview := *p
+	preview.configure(cfg, p.excluded)
+	return &preview
+}
+
+// Close flushes the replace journal, dropping operations that completed
+func (p *Pipeline) Close() error {
+	return p.journal.Close()
+}
+
+// absPath returns an absolute path for journaling, falling back to path as given
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// keptPage records why a page's original bytes were kept
+func (r *Result) keptPage(page, reason string) {
+	if r.KeptPages == nil {
+		r.KeptPages = make(map[string]string)
+	}
+	r.KeptPages[page] = reason
+}
+
+// fileSize is the size of the file at path, or 0 if it can't be read
+func fileSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
 }
 
 // ProcessFile handles a single CBZ file
 func (p *Pipeline) ProcessFile(cbzPath string) (*Result, error) {
+	return p.processFile(context.Background(), cbzPath)
+}
+
+// processFile wraps compressFile in a per-file span. A panic anywhere in the
+// file's processing fails only this file, never the batch.
+func (p *Pipeline) processFile(ctx context.Context, cbzPath string) (result *Result, err error) {
+	ctx, span := tracer.Start(ctx, "process file", trace.WithAttributes(attribute.String("cbz.path", cbzPath)))
+	func() {
+		defer recoverPanic(cbzPath, &err)
+		var archive *Pipeline
+		if archive, err = p.forArchive(cbzPath); err != nil {
+			return
+		}
+		result, err = archive.compressFile(ctx, cbzPath)
+	}()
+	if result != nil {
+		span.SetAttributes(
+			attribute.Bool("cbz.skipped", result.Skipped),
+			attribute.Int64("cbz.original_size", result.OriginalSize),
+			attribute.Int64("cbz.compressed_size", result.CompressedSize),
+			attribute.Int("cbz.images_processed", result.ImagesProcessed),
+		)
+		if result.Skipped {
+			span.SetAttributes(attribute.String("cbz.skip_reason", result.SkipReason))
+		}
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+// compressFile runs every stage for one archive, each under its own span
+func (p *Pipeline) compressFile(ctx context.Context, cbzPath string) (*Result, error) {
 	startTime := time.Now()
 	result := &Result{
 		SourcePath: cbzPath,
@@ -109,24 +345,92 @@ func (p *Pipeline) ProcessFile(cbzPath string) (*Result, error) {
 	}
 	result.OriginalSize = info.Size()
 
+	// An existing converted copy or output archive is settled by the collision policy
+	dest, err := p.outputPath(cbzPath)
+	if err != nil {
+		return nil, err
+	}
+	// A page selection is a preview: the subset must never replace the original
+	if p.config.Pages.IsSet() && !p.writesCopy(cbzPath, dest) {
+		return nil, fmt.Errorf("page selection %s writes a subset of the archive; it needs an output directory", p.config.Pages)
+	}
+	if dest != cbzPath {
+		var reason string
+		if dest, reason = p.resolveCollision(dest); reason != "" {
+			result.Skipped = true
+			result.SkipReason = reason
+			result.SkipKind = SkipExists
+			result.Duration = time.Since(startTime)
+			if p.reporter != nil {
+				p.reporter.OnFileSkipped(cbzPath, result.SkipReason)
+			}
+			return result, nil
+		}
+	}
+
+	// On a network share, read a local copy: the random reads below are
+	// much slower over SMB/NFS than one sequential copy
+	sourcePath := cbzPath
+	if p.copySlots != nil {
+		local, err := p.copyLocal(cbzPath)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(filepath.Dir(local))
+		sourcePath = local
+	}
+
+	// A split set (name.z01, name.z02, ... ended by this archive) is read
+	// joined into one archive, and replaced by one
+	parts, err := cbz.SplitParts(cbzPath)
+	if err != nil {
+		return nil, err
+	}
+	if parts != nil {
+		joined, err := p.joinSplit(sourcePath, parts)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(joined)
+		sourcePath = joined
+		for _, part := range parts {
+			result.OriginalSize += fileSize(part)
+		}
+	}
+
 	// Analyze file first (unless force mode)
 	var analysis *analyzer.AnalysisResult
 	if !p.config.Force {
 		var err error
-		analysis, err = p.analyzer.Analyze(cbzPath)
+		_, span := tracer.Start(ctx, "analyze")
+		analysis, err = p.analyzer.AnalyzeAs(sourcePath, cbzPath)
+		endSpan(span, err)
 		if err != nil {
 			return nil, fmt.Errorf("analysis failed: %w", err)
 		}
+		p.events.publish(AnalysisEvent{Path: cbzPath, Analysis: analysis})
+
+		// Page selections, levels, grayscale, flattening and splitting rewrite
+		// archives the heuristics would skip
+		rewrites := Decide(p.config, cbzPath, p.excluded, analysis, len(parts))
 
 		// Dry run - report all files (skipped and to-process) via OnDryRunFile
 		if p.config.DryRun {
 			result.Duration = time.Since(startTime)
 			// Calculate estimated savings for files that need processing
 			p.analyzer.EstimateSavings(analysis)
+			if analysis.NeedsProcessing {
+				analysis.ProcessingReasons = append(analysis.ProcessingReasons, rewrites...)
+				if parts != nil && !p.config.JoinSplit {
+					analysis.ProcessingReasons = append(analysis.ProcessingReasons, fmt.Sprintf("join %d parts", len(parts)+1))
+				}
+			}
 			result.Analysis = analysis
 			if !analysis.NeedsProcessing {
 				result.Skipped = true
 				result.SkipReason = analysis.SkipReason
+				result.SkipKind = analysis.SkipKind
+				result.DeviceFit = p.deviceFit(analysisBounds(analysis))
 			}
 			if p.reporter != nil {
 				p.reporter.OnDryRunFile(analysis)
@@ -137,6 +441,8 @@ func (p *Pipeline) ProcessFile(cbzPath string) (*Result, error) {
 		if !analysis.NeedsProcessing {
 			result.Skipped = true
 			result.SkipReason = analysis.SkipReason
+			result.SkipKind = analysis.SkipKind
+			result.DeviceFit = p.deviceFit(analysisBounds(analysis))
 			result.Duration = time.Since(startTime)
 			if p.reporter != nil {
 				p.reporter.OnFileSkipped(cbzPath, analysis.SkipReason)
@@ -145,34 +451,190 @@ func (p *Pipeline) ProcessFile(cbzPath string) (*Result, error) {
 		}
 	}
 
+	// Forced runs bypass the heuristics but still honor override exclusions,
+	// can't read encrypted archives, and respect the processing marker
+	if p.config.Force {
+		var (
+			encrypted int
+			marker    cbz.Marker
+		)
+		if p.excluded == "" {
+			if encrypted, err = cbz.CountEncrypted(sourcePath); err != nil {
+				return nil, fmt.Errorf("analysis failed: %w", err)
+			}
+		}
+		if p.excluded == "" && encrypted == 0 && !p.config.IgnoreMarker {
+			if marker, err = cbz.ReadMarker(sourcePath); err != nil {
+				return nil, fmt.Errorf("analysis failed: %w", err)
+			}
+		}
+		if reason, kind := ForcedSkip(p.config, p.excluded, encrypted, marker); reason != "" {
+			result.Skipped = true
+			result.SkipReason = reason
+			result.SkipKind = kind
+			result.Duration = time.Since(startTime)
+			if p.reporter != nil {
+				p.reporter.OnFileSkipped(cbzPath, result.SkipReason)
+			}
+			return result, nil
+		}
+	}
+
 	// Extract CBZ
-	contents, err := p.reader.Extract(cbzPath)
+	_, span := tracer.Start(ctx, "extract")
+	contents, err := p.reader.Extract(sourcePath)
+	endSpan(span, err)
+	var corrupt *cbz.CorruptError
+	switch {
+	case errors.As(err, &corrupt) && p.config.Salvage:
+		contents = p.salvageCorrupt(corrupt, result)
+	case corrupt != nil:
+		return nil, fmt.Errorf("%w (-salvage keeps the pages that still decode)", err)
+	case err != nil:
+		return nil, err
+	}
+	result.UnsafePaths = contents.UnsafePaths
+
+	// Forced runs skip the analysis that would have caught an archive without pages
+	if len(contents.Images) == 0 {
+		result.Skipped = true
+		result.SkipReason = analyzer.SkipNoPages
+		result.SkipKind = analyzer.SkipNoPages
+		result.Duration = time.Since(startTime)
+		if p.reporter != nil {
+			p.reporter.OnFileSkipped(cbzPath, result.SkipReason)
+		}
+		return result, nil
+	}
+
+	// Restrict to the requested page range (images are already in natural page order)
+	if err := contents.SelectPages(p.config.Pages); err != nil {
+		return nil, err
+	}
+
+	imageProcessor := p.processor
+	if matchesDir(cbzPath, p.config.AutoLevelsDirs) {
+		imageProcessor = p.levels
+	}
+
+	// Stack strip slices (webtoon rips) into full pages before encoding
+	contents.Images, result.StripsComposed, result.ComposedPages = imageProcessor.composeStrips(contents.Images)
+	p.events.publish(ExtractionEvent{Path: cbzPath, Pages: len(contents.Images), OtherFiles: len(contents.OtherFiles)})
+
+	// Stream pages into a temporary archive as they are encoded, so encoded
+	// pages never pile up in memory
+	archive, err := p.writer.BeginTemp(cbzPath)
 	if err != nil {
+		return nil, fmt.Errorf("failed to create compressed CBZ: %w", err)
+	}
+	// A subset is not the archive a later run would find, so it gets no marker
+	if p.config.Pages.IsSet() {
+		archive.Unmarked()
+	}
+
+	// Entries that pass through unchanged are copied compressed, as they are
+	source, err := cbz.OpenSource(sourcePath)
+	if err != nil {
+		archive.Abort()
 		return nil, err
 	}
+	defer source.Close()
 
-	// Process images
-	entries := make([]cbz.WriteEntry, 0, len(contents.Images)+len(contents.OtherFiles))
+	// Encoded pages are only kept for the before/after samples
+	samples := make(map[int]cbz.ImageEntry)
+	if p.config.SampleDir != "" {
+		for _, i := range sampleIndices(len(contents.Images), p.config.SampleCount) {
+			samples[i] = cbz.ImageEntry{}
+		}
+	}
+
+	// Where each page was written, in page order, for the order check in verification
+	order := make([]pageMapping, 0, len(contents.Images))
+	var bounds pageBounds
+
+	// Pages in chapter folders are renumbered into the archive root
+	flatten := p.config.Flatten && nested(contents.Images)
+	written := func(i int, name string) string {
+		if flatten {
+			return flatName(i, len(contents.Images), name)
+		}
+		return name
+	}
 
+	progress := PageProgress{Total: len(contents.Images)}
 	for _, img := range contents.Images {
-		processed, err := p.processor.Process(img)
+		progress.BytesTotal += img.OriginalSize
+	}
+	lastProgress := time.Now()
+
+	encodeCtx, span := tracer.Start(ctx, "encode", trace.WithAttributes(attribute.Int("cbz.images", len(contents.Images))))
+	for i, img := range contents.Images {
+		if p.reporter != nil && time.Since(lastProgress) >= pageProgressInterval {
+			p.reporter.OnPageProgress(cbzPath, progress)
+			lastProgress = time.Now()
+		}
+		p.tracker.page(cbzPath, progress)
+		progress.Done++
+		progress.BytesDone += img.OriginalSize
+
+		processed, err := imageProcessor.ProcessContext(encodeCtx, img)
 		if err != nil {
-			// Log error but continue with other images
-			result.Errors = append(result.Errors, err)
-			// Keep original on error
-			entries = append(entries, cbz.WriteEntry{
-				Path: img.Path,
-				Data: img.Data,
-			})
+			// Record the page and continue with other images
+			var pageErr *PageError
+			if !errors.As(err, &pageErr) {
+				pageErr = &PageError{Page: img.Path, Stage: StageEncode, Err: err}
+			}
+			result.PageErrors = append(result.PageErrors, *pageErr)
+			result.keptPage(img.Path, pageErr.KeptReason())
+			// Strict mode never writes an archive with unprocessed pages
+			if p.config.Strict {
+				archive.Abort()
+				endSpan(span, err)
+				return nil, fmt.Errorf("strict: %w", err)
+			}
+			// Keep original on error, and leave the archive unmarked so later runs retry the page
+			archive.Unmarked()
+			name := written(i, img.Path)
+			if err := archive.Add(source.EntryAs(img.Path, name, img.Data)); err != nil {
+				endSpan(span, err)
+				return nil, fmt.Errorf("failed to create compressed CBZ: %w", err)
+			}
+			order = append(order, pageMapping{Source: img.Path, Output: name})
+			bounds.addPage(0, 0, img.Data)
+			result.PageSizes = append(result.PageSizes, PageSize{Page: img.Path, OriginalSize: img.OriginalSize, NewSize: img.OriginalSize})
+			if _, ok := samples[i]; ok {
+				samples[i] = img
+			}
+			p.events.publish(PageEvent{Path: cbzPath, Page: img.Path, NewPath: name, OriginalSize: img.OriginalSize, NewSize: img.OriginalSize, Err: err})
 			continue
 		}
 
-		entries = append(entries, cbz.WriteEntry{
-			Path: processed.NewPath,
-			Data: processed.Data,
+		name := written(i, processed.NewPath)
+		if err := archive.Add(source.EntryAs(processed.NewPath, name, processed.Data)); err != nil {
+			endSpan(span, err)
+			return nil, fmt.Errorf("failed to create compressed CBZ: %w", err)
+		}
+		order = append(order, pageMapping{Source: img.Path, Output: name})
+		bounds.addPage(processed.Width, processed.Height, processed.Data)
+		result.PageSizes = append(result.PageSizes, PageSize{Page: img.Path, OriginalSize: processed.OriginalSize, NewSize: processed.NewSize})
+		if _, ok := samples[i]; ok {
+			samples[i] = cbz.ImageEntry{Path: name, Data: processed.Data}
+		}
+		p.events.publish(PageEvent{
+			Path:         cbzPath,
+			Page:         img.Path,
+			NewPath:      name,
+			Width:        processed.Width,
+			Height:       processed.Height,
+			Quality:      processed.Quality,
+			Codec:        processed.Codec,
+			OriginalSize: processed.OriginalSize,
+			NewSize:      processed.NewSize,
+			Resized:      processed.WasResized,
+			Converted:    processed.WasConverted,
 		})
 
-		if processed.WasResized || processed.WasConverted {
+		if processed.WasResized || processed.WasConverted || processed.CMYK || processed.Adjusted {
 			result.ImagesProcessed++
 		} else {
 			result.ImagesSkipped++
@@ -180,25 +642,60 @@ func (p *Pipeline) ProcessFile(cbzPath string) (*Result, error) {
 		if processed.WasConverted {
 			result.PNGsConverted++
 		}
+		if processed.HighBitDepth {
+			result.HighBitDepth++
+		}
+		if processed.LowQuality {
+			result.LowQualityPages++
+		}
+		if processed.Modern {
+			result.ModernPages++
+		}
+		if processed.Kept != "" {
+			result.keptPage(img.Path, processed.Kept)
+		}
+		if processed.CMYK {
+			result.CMYKPages++
+		}
+		if processed.SkewAngle != 0 {
+			if result.Deskewed == nil {
+				result.Deskewed = make(map[string]float64)
+			}
+			result.Deskewed[img.Path] = processed.SkewAngle
+		}
+		if processed.Codec != "" {
+			if result.CodecWins == nil {
+				result.CodecWins = make(map[string]int)
+			}
+			result.CodecWins[processed.Codec]++
+		}
 
 		if p.reporter != nil && p.config.Verbose {
 			p.reporter.OnImageProcessed(img.Path, processed.OriginalSize, processed.NewSize)
 		}
 	}
+	span.End()
 
-	// Include non-image files (like ComicInfo.xml)
+	// Include non-image files (like ComicInfo.xml), then finish the archive
+	_, span = tracer.Start(ctx, "write")
 	for _, other := range contents.OtherFiles {
-		entries = append(entries, cbz.WriteEntry{
-			Path: other.Path,
-			Data: other.Data,
-		})
+		if err = archive.Add(source.Entry(other.Path, other.Data)); err != nil {
+			break
+		}
 	}
-
-	// Create temporary output
-	tempOutput, err := p.writer.CreateTemp(cbzPath, entries)
+	if err == nil {
+		if err = p.injected(StageWrite, cbzPath); err != nil {
+			archive.Abort()
+		} else {
+			err = archive.Commit()
+		}
+	}
+	source.Close() // before the original is moved to backup (Windows can't rename open files)
+	endSpan(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create compressed CBZ: %w", err)
 	}
+	tempOutput := archive.Path()
 
 	// Get compressed size
 	compressedInfo, err := os.Stat(tempOutput)
@@ -209,35 +706,366 @@ func (p *Pipeline) ProcessFile(cbzPath string) (*Result, error) {
 	result.CompressedSize = compressedInfo.Size()
 
 	// Verify the new CBZ is valid before proceeding
-	if err := p.verifyCompressedCBZ(tempOutput); err != nil {
+	_, span = tracer.Start(ctx, "verify")
+	err = p.injected(StageVerify, cbzPath)
+	if err == nil {
+		err = p.verifyCompressedCBZ(tempOutput, order)
+	}
+	endSpan(span, err)
+	if err != nil {
 		os.Remove(tempOutput)
 		return nil, fmt.Errorf("verification failed: %w", err)
 	}
+	p.events.publish(VerificationEvent{Path: cbzPath, Temp: tempOutput, Size: result.CompressedSize})
+
+	// Swap the compressed archive into place, keeping the original in backup,
+	// or write a merged archive's chapters as archives of their own
+	var chapters []string
+	if p.config.Split {
+		sources := make([]string, len(order))
+		for i, page := range order {
+			sources[i] = page.Source
+		}
+		chapters = cbz.Chapters(sources)
+	}
+	_, span = tracer.Start(ctx, "replace")
+	if chapters != nil {
+		err = p.splitArchive(cbzPath, dest, tempOutput, order, chapters, info, result)
+	} else {
+		err = p.replaceOriginal(cbzPath, dest, tempOutput, info, result)
+	}
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rest of a split set goes to backup with the archive that ended it.
+	// The joined archive is in place by now, so a part that can't be moved
+	// is only left behind next to it.
+	if parts != nil && !p.writesCopy(cbzPath, dest) {
+		for _, part := range parts {
+			backupPath, err := p.backup.MoveToBackup(part)
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("split part %s left in place: failed to back up: %v", filepath.Base(part), err))
+				continue
+			}
+			p.events.publish(BackupEvent{Path: part, Backup: absPath(backupPath), Mode: string(p.backup.Mode())})
+		}
+	}
+
+	// Export before/after pairs for quality auditing (failure here never affects the archive)
+	if p.config.SampleDir != "" {
+		_, span = tracer.Start(ctx, "export samples")
+		err := p.exportSamples(cbzPath, contents.Images, samples)
+		endSpan(span, err)
+		if err != nil {
+			result.Warnings = append(result.Warnings, err.Error())
+		}
+	}
+
+	result.OutputPath = dest
+	result.Copy = p.writesCopy(cbzPath, dest)
+	result.DeviceFit = p.deviceFit(bounds)
+	if len(result.Parts) > 0 {
+		result.OutputPath = result.Parts[0]
+	}
+	result.Duration = time.Since(startTime)
+
+	return result, nil
+}
+
+// Policies for archives without a .cbz extension (-rename-to-cbz)
+const (
+	RenameKeep      = "keep"      // Compress in place under the old name
+	RenameReplace   = "replace"   // Replace the original with a .cbz of the same stem
+	RenameAlongside = "alongside" // Write a .cbz next to the original and leave it untouched
+)
+
+// ParseRenamePolicy validates a -rename-to-cbz value
+func ParseRenamePolicy(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case RenameKeep, RenameReplace, RenameAlongside:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown rename policy %q (must be keep, replace or alongside)", s)
+}
+
+// copyLocal copies cbzPath into a fresh directory under the local temp
+// dir, holding one of the copy slots while it transfers
+func (p *Pipeline) copyLocal(cbzPath string) (string, error) {
+	p.copySlots <- struct{}{}
+	defer func() { <-p.copySlots }()
+
+	dir, err := os.MkdirTemp(p.writer.TempDir(), "cbz-local-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create local copy dir: %w", err)
+	}
+	local := filepath.Join(dir, filepath.Base(cbzPath))
+	if err := fsutil.Copy(cbzPath, local); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to copy %s locally: %w", cbzPath, err)
+	}
+	return local, nil
+}
+
+// outputPath is where the compressed archive of cbzPath goes: cbzPath
+// itself, under -rename-to-cbz the same name with a .cbz extension, or its
+// place in the output directory
+func (p *Pipeline) outputPath(cbzPath string) (string, error) {
+	ext := filepath.Ext(cbzPath)
+	if p.renamesToCBZ() {
+		ext = ".cbz"
+	}
+	if p.config.OutputDir != "" {
+		return p.mirrorPath(cbzPath, ext)
+	}
+	return strings.TrimSuffix(cbzPath, filepath.Ext(cbzPath)) + ext, nil
+}
+
+// joinSplit joins the split set ended by head into a temporary archive
+// where compressed archives are built, returning its path
+func (p *Pipeline) joinSplit(head string, parts []string) (string, error) {
+	dir := p.writer.TempDir()
+	if dir == "" {
+		dir = filepath.Dir(head)
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(head)+".joined.*"+cbz.TempSuffix)
+	if err != nil {
+		return "", fmt.Errorf("failed to join split set: %w", err)
+	}
+	f.Close()
+	if err := cbz.JoinSplit(parts, head, f.Name()); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to join split set: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// renamesToCBZ reports whether -rename-to-cbz gives archives a .cbz extension
+func (p *Pipeline) renamesToCBZ() bool {
+	return p.config.RenameToCBZ == RenameReplace || p.config.RenameToCBZ == RenameAlongside
+}
+
+// writesCopy reports whether the compressed archive goes to dest next to
+// the original (or in the output directory) rather than replacing it
+func (p *Pipeline) writesCopy(cbzPath, dest string) bool {
+	return dest != cbzPath && (p.config.OutputDir != "" || p.config.RenameToCBZ == RenameAlongside)
+}
+
+// replaceOriginal stages tempOutput next to cbzPath, copies the original's
+// metadata onto it and swaps it into place at dest under the journal,
+// moving the original to backup. With -rename-to-cbz alongside the original
+// stays and no backup is made. Non-fatal problems are added to
+// result.Errors.
+func (p *Pipeline) replaceOriginal(cbzPath, dest, tempOutput string, info os.FileInfo, result *Result) error {
+	if p.writesCopy(cbzPath, dest) {
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			os.Remove(tempOutput)
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	// Bring an archive built on another volume next to its destination
+	// first, so the swap below stays a same-volume rename
+	if p.writer.TempDir() != "" {
+		staged := dest + cbz.TempSuffix
+		if p.copySlots != nil {
+			p.copySlots <- struct{}{}
+		}
+		err := fsutil.Move(tempOutput, staged)
+		if p.copySlots != nil {
+			<-p.copySlots
+		}
+		if err != nil {
+			os.Remove(tempOutput)
+			return fmt.Errorf("failed to stage compressed CBZ: %w", err)
+		}
+		tempOutput = staged
+	}
+
+	// Give the replacement the original's extended attributes (Finder tags etc.),
+	// mode, owner and mtime before it goes live. Xattrs come first: on macOS
+	// setting them would otherwise bump the preserved mtime.
+	xattrs, err := fsutil.ReadXattrs(cbzPath)
+	if err != nil {
+		os.Remove(tempOutput)
+		return fmt.Errorf("failed to read extended attributes: %w", err)
+	}
+	if err := fsutil.WriteXattrs(tempOutput, xattrs); err != nil {
+		os.Remove(tempOutput)
+		return fmt.Errorf("failed to preserve extended attributes: %w", err)
+	}
+	if err := fsutil.CopyMetadata(info, tempOutput, p.config.PreserveMTime); err != nil {
+		os.Remove(tempOutput)
+		return fmt.Errorf("failed to preserve file attributes: %w", err)
+	}
+
+	// A copy next to the original or in the output directory replaces nothing
+	if p.writesCopy(cbzPath, dest) {
+		err := p.injected(StageRename, cbzPath)
+		if err == nil {
+			err = fsutil.Move(tempOutput, dest)
+		}
+		if err != nil {
+			os.Remove(tempOutput)
+			return fmt.Errorf("failed to write %s: %w", dest, err)
+		}
+		if p.writer.Durable() {
+			if err := fsutil.SyncDir(filepath.Dir(dest)); err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("failed to sync directory: %w", err))
+			}
+		}
+		return nil
+	}
+
+	// Once the run is aborting no more originals are touched
+	if !p.swaps.enter() {
+		os.Remove(tempOutput)
+		return ErrAborted
+	}
+	defer p.swaps.leave()
+
+	// Journal every step of the swap so `recover` can finish or undo it after a crash
+	op := journal.Record{
+		ID:         journal.NewID(),
+		State:      journal.StateBegin,
+		Target:     absPath(cbzPath),
+		Temp:       absPath(tempOutput),
+		BackupMode: string(p.backup.Mode()),
+	}
+	if dest != cbzPath {
+		op.Output = absPath(dest)
+	}
+	if fsutil.ReplacePreservesAttributes && p.backup.Mode() == backup.ModeDir && dest == cbzPath {
+		return p.replaceInOneStep(op, cbzPath, tempOutput, result)
+	}
+	if err := p.journal.Append(op); err != nil {
+		os.Remove(tempOutput)
+		return err
+	}
 
 	// Move original to backup
-	if err := p.backup.MoveToBackup(cbzPath); err != nil {
+	var backupPath string
+	err = p.injected(StageBackup, cbzPath)
+	if err == nil {
+		backupPath, err = p.backup.MoveToBackup(cbzPath)
+	}
+	if err != nil {
 		os.Remove(tempOutput)
-		return nil, fmt.Errorf("backup failed: %w", err)
+		op.State = journal.StateRolledBack
+		p.journal.Append(op)
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	op.State = journal.StateBackedUp
+	op.Backup = absPath(backupPath)
+	if err := p.journal.Append(op); err != nil {
+		result.Errors = append(result.Errors, err)
 	}
 
-	// Rename compressed to original location
-	if err := os.Rename(tempOutput, cbzPath); err != nil {
+	// Interrupted between the two renames: put the original back
+	if p.swaps.aborted() {
+		if err := p.restore(cbzPath); err != nil {
+			return fmt.Errorf("CRITICAL: interrupted and restore failed (original is at %s): %w", backupPath, err)
+		}
+		os.Remove(tempOutput)
+		op.State = journal.StateRolledBack
+		p.journal.Append(op)
+		return fmt.Errorf("%w (original restored)", ErrAborted)
+	}
+
+	p.events.publish(BackupEvent{Path: cbzPath, Backup: op.Backup, Mode: op.BackupMode})
+
+	// Rename compressed to original location (or its .cbz name)
+	err = p.injected(StageRename, cbzPath)
+	if err == nil {
+		err = fsutil.Rename(tempOutput, dest)
+	}
+	if err != nil {
 		// Try to restore from backup
-		if restoreErr := p.backup.RestoreFromBackup(cbzPath); restoreErr != nil {
-			return nil, fmt.Errorf("CRITICAL: rename failed and restore failed: %w (restore: %v)", err, restoreErr)
+		if restoreErr := p.restore(cbzPath); restoreErr != nil {
+			return fmt.Errorf("CRITICAL: rename failed and restore failed: %w (restore: %v)", err, restoreErr)
 		}
 		os.Remove(tempOutput)
-		return nil, fmt.Errorf("rename failed (original restored): %w", err)
+		op.State = journal.StateRolledBack
+		p.journal.Append(op)
+		return fmt.Errorf("rename failed (original restored): %w", err)
+	}
+	op.State = journal.StateCommitted
+	if err := p.journal.Append(op); err != nil {
+		result.Errors = append(result.Errors, err)
 	}
 
-	result.OutputPath = cbzPath
-	result.Duration = time.Since(startTime)
+	// Persist both renames (original -> backup, compressed -> original) before reporting success
+	if p.writer.Durable() {
+		if err := fsutil.SyncDir(filepath.Dir(cbzPath)); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to sync directory: %w", err))
+		}
+		if backupDir := p.backup.BackupDir(); backupDir != "" {
+			if err := fsutil.SyncDir(backupDir); err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("failed to sync backup directory: %w", err))
+			}
+		}
+	}
 
-	return result, nil
+	return nil
 }
 
-// verifyCompressedCBZ checks that the new CBZ is valid
-func (p *Pipeline) verifyCompressedCBZ(path string) error {
+// replaceInOneStep swaps the compressed archive in with a single ReplaceFile
+// call where the platform has one, so the live file keeps its attributes and
+// ACLs and is never missing
+func (p *Pipeline) replaceInOneStep(op journal.Record, cbzPath, tempOutput string, result *Result) error {
+	backupPath := p.backup.Reserve(cbzPath)
+	op.Backup = absPath(backupPath)
+	if err := p.journal.Append(op); err != nil {
+		p.backup.Release(backupPath)
+		os.Remove(tempOutput)
+		return err
+	}
+
+	err := p.injected(StageBackup, cbzPath)
+	if err == nil {
+		err = p.injected(StageRename, cbzPath)
+	}
+	if err == nil {
+		err = p.backup.Replace(cbzPath, tempOutput, backupPath)
+	}
+	if err != nil {
+		os.Remove(tempOutput)
+		op.State = journal.StateRolledBack
+		p.journal.Append(op)
+		return fmt.Errorf("replace failed: %w", err)
+	}
+	op.State = journal.StateCommitted
+	if err := p.journal.Append(op); err != nil {
+		result.Errors = append(result.Errors, err)
+	}
+	p.events.publish(BackupEvent{Path: cbzPath, Backup: op.Backup, Mode: op.BackupMode})
+
+	if p.writer.Durable() {
+		if err := fsutil.SyncDir(filepath.Dir(cbzPath)); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to sync directory: %w", err))
+		}
+		if err := fsutil.SyncDir(filepath.Dir(backupPath)); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to sync backup directory: %w", err))
+		}
+	}
+	return nil
+}
+
+// pageMapping pairs a source page with the entry it was written as
+type pageMapping struct {
+	Source string
+	Output string
+}
+
+// verifyCompressedCBZ checks that the new CBZ is valid, also to a strict
+// zip parser (see cbz.CheckStrict), and that readers, which sort pages by
+// name, see them in the source's order: a renamed or renumbered page that
+// sorts elsewhere would silently reorder chapters
+func (p *Pipeline) verifyCompressedCBZ(path string, order []pageMapping) error {
+	if err := cbz.CheckStrict(path); err != nil {
+		return fmt.Errorf("compressed CBZ fails the strict zip check: %w", err)
+	}
 	contents, err := p.reader.Extract(path)
 	if err != nil {
 		return fmt.Errorf("cannot read compressed CBZ: %w", err)
@@ -245,26 +1073,69 @@ func (p *Pipeline) verifyCompressedCBZ(path string) error {
 	if len(contents.Images) == 0 {
 		return fmt.Errorf("compressed CBZ has no images")
 	}
-	return nil
-}
-
-// shouldSkipFile checks if a filename matches any of the skip patterns
-func (p *Pipeline) shouldSkipFile(filename string) bool {
-	for _, pattern := range p.config.SkipPatterns {
-		if matched, _ := filepath.Match(pattern, filename); matched {
-			return true
+	if len(contents.Images) != len(order) {
+		return fmt.Errorf("compressed CBZ has %d pages, expected %d", len(contents.Images), len(order))
+	}
+	for i, img := range contents.Images {
+		if img.Path != order[i].Output {
+			return fmt.Errorf("page order changed: page %d is %s, expected %s (from %s)", i+1, img.Path, order[i].Output, order[i].Source)
 		}
 	}
-	return false
+	return nil
 }
 
 // ProcessDirectory processes all CBZ files in a directory
 func (p *Pipeline) ProcessDirectory(dirPath string) (*BatchResult, error) {
-	// Find all CBZ files
-	var cbzFiles []string
+	cbzFiles, err := p.FindFiles(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	return p.ProcessFiles(cbzFiles)
+}
+
+// FindFiles walks a directory and returns the CBZ files the pipeline would process
+func (p *Pipeline) FindFiles(dirPath string) ([]string, error) {
+	cbzFiles, ignored, err := findArchives(p.config, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range cbzFiles {
+		p.roots[absPath(path)] = dirPath
+	}
+	p.ignored += ignored
+	return cbzFiles, nil
+}
+
+// FindArchives lists the archives under dirPath that a pipeline with cfg
+// would process, without building one: it only reads the directory tree
+func FindArchives(cfg config.Config, dirPath string) ([]string, error) {
+	cbzFiles, _, err := findArchives(cfg, dirPath)
+	return cbzFiles, err
+}
+
+// findArchives walks dirPath for archives, also counting the archives
+// skip_patterns and exclude files leave out
+func findArchives(cfg config.Config, dirPath string) ([]string, int, error) {
+	var (
+		cbzFiles []string
+		ignored  int
+	)
+	// Other files matching skip patterns (resource forks, thumbnails)
+	// aren't worth reporting
+	countIgnored := func(path string) {
+		if cfg.IsArchive(path) {
+			ignored++
+		}
+	}
 
 	// Get absolute path of backup directory to skip it during walk
-	backupDirAbs, _ := filepath.Abs(p.config.BackupDir)
+	backupDirAbs, _ := filepath.Abs(cfg.BackupDir)
+
+	// Permanent exclusions: the configured exclude file plus .cbzignore files in the tree
+	excludes, err := ignore.New(dirPath, cfg.ExcludeFile)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	walkFn := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -277,30 +1148,93 @@ func (p *Pipeline) ProcessDirectory(dirPath string) (*BatchResult, error) {
 			if absPath == backupDirAbs {
 				return filepath.SkipDir
 			}
+			if path != dirPath && excludes.Excluded(path, true) {
+				return filepath.SkipDir
+			}
+			if err := excludes.LoadDir(path); err != nil {
+				return err
+			}
+		} else if excludes.Excluded(path, false) {
+			countIgnored(path)
+			return nil
 		}
 
 		// Skip files matching skip patterns (e.g., macOS resource forks)
-		if !info.IsDir() && p.shouldSkipFile(info.Name()) {
+		if !info.IsDir() && skipsFile(cfg.SkipPatterns, info.Name()) {
+			countIgnored(path)
+			return nil
+		}
+
+		// Never pick up temp archives left behind by an interrupted run (see `recover`)
+		if strings.HasSuffix(info.Name(), cbz.TempSuffix) {
 			return nil
 		}
 
-		if !info.IsDir() && strings.ToLower(filepath.Ext(path)) == ".cbz" {
+		if !info.IsDir() && cfg.IsArchive(path) {
 			cbzFiles = append(cbzFiles, path)
 		}
-		if !p.config.Recursive && info.IsDir() && path != dirPath {
+		if !cfg.Recursive && info.IsDir() && path != dirPath {
 			return filepath.SkipDir
 		}
 		return nil
 	}
 
 	if err := filepath.Walk(dirPath, walkFn); err != nil {
-		return nil, fmt.Errorf("failed to scan directory: %w", err)
+		return nil, 0, fmt.Errorf("failed to scan directory: %w", err)
 	}
 
+	return cbzFiles, ignored, nil
+}
+
+// skipsFile checks if a filename matches any of the skip patterns
+func skipsFile(patterns []string, filename string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, filename); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// FindRoots returns the archives under every root, in root order, as one
+// list. Roots may be directories or single archives; an archive reachable
+// from two overlapping roots is listed once.
+func (p *Pipeline) FindRoots(roots []string) ([]string, error) {
+	var cbzFiles []string
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access %s: %w", root, err)
+		}
+		found := []string{root}
+		if info.IsDir() {
+			if found, err = p.FindFiles(root); err != nil {
+				return nil, fmt.Errorf("%s: %w", root, err)
+			}
+		}
+		for _, path := range found {
+			if key := absPath(path); !seen[key] {
+				seen[key] = true
+				cbzFiles = append(cbzFiles, path)
+			}
+		}
+	}
+	return cbzFiles, nil
+}
+
+// ProcessFiles processes the given CBZ files as one batch
+func (p *Pipeline) ProcessFiles(cbzFiles []string) (*BatchResult, error) {
 	totalFiles := len(cbzFiles)
 	if totalFiles == 0 {
 		return &BatchResult{TotalFiles: 0}, nil
 	}
+	if p.config.Preflight && !p.config.DryRun {
+		if err := p.Preflight(cbzFiles); err != nil {
+			return nil, err
+		}
+	}
+	cbzFiles = p.prioritizeRecent(cbzFiles)
 
 	// Determine worker count
 	workers := p.config.Workers
@@ -311,37 +1245,67 @@ func (p *Pipeline) ProcessDirectory(dirPath string) (*BatchResult, error) {
 		workers = 1
 	}
 
+	ctx, span := tracer.Start(context.Background(), "batch", trace.WithAttributes(
+		attribute.Int("batch.files", totalFiles),
+		attribute.Int("batch.workers", workers),
+		attribute.Bool("batch.dry_run", p.config.DryRun),
+	))
+	defer span.End()
+
 	// Single worker path (avoid goroutine overhead)
+	var batch *BatchResult
+	var err error
 	if workers == 1 {
-		return p.processDirectorySequential(cbzFiles)
+		batch, err = p.processDirectorySequential(ctx, cbzFiles)
+	} else {
+		batch, err = p.processDirectoryParallel(ctx, cbzFiles, workers)
 	}
-
-	return p.processDirectoryParallel(cbzFiles, workers)
+	if batch != nil {
+		span.SetAttributes(
+			attribute.Int("batch.processed", batch.ProcessedFiles),
+			attribute.Int("batch.skipped", batch.SkippedFiles),
+			attribute.Int("batch.failed", batch.FailedFiles),
+			attribute.Int64("batch.bytes_saved", batch.TotalOriginal-batch.TotalCompressed),
+		)
+	}
+	return batch, err
 }
 
 // processDirectorySequential processes files one at a time (original behavior)
-func (p *Pipeline) processDirectorySequential(cbzFiles []string) (*BatchResult, error) {
+func (p *Pipeline) processDirectorySequential(ctx context.Context, cbzFiles []string) (*BatchResult, error) {
 	batch := &BatchResult{
-		Results:    make([]Result, 0, len(cbzFiles)),
-		TotalFiles: len(cbzFiles),
+		Results:      make([]Result, 0, len(cbzFiles)),
+		TotalFiles:   len(cbzFiles),
+		IgnoredFiles: p.ignored,
 	}
 	startTime := time.Now()
 	totalFiles := len(cbzFiles)
+	p.tracker = newBatchProgress(p.reporter, cbzFiles)
+	defer func() { p.tracker = nil }()
 
 	for i, cbzPath := range cbzFiles {
-		result, err := p.ProcessFile(cbzPath)
+		if p.reporter != nil {
+			p.reporter.OnFileStart(cbzPath, i+1, totalFiles)
+		}
+		result, err := p.processFile(ctx, cbzPath)
 		if err != nil {
 			batch.FailedFiles++
 			failedResult := Result{
-				SourcePath: cbzPath,
-				Errors:     []error{err},
-				Index:      i + 1,
-				Total:      totalFiles,
+				SourcePath:   cbzPath,
+				OriginalSize: fileSize(cbzPath),
+				Errors:       []error{err},
+				Index:        i + 1,
+				Total:        totalFiles,
 			}
 			batch.Results = append(batch.Results, failedResult)
 			if p.reporter != nil {
 				p.reporter.OnFileComplete(failedResult)
 			}
+			p.tracker.finish(cbzPath)
+			if p.failureBudgetExceeded(batch) {
+				batch.NotAttempted = totalFiles - (i + 1)
+				break
+			}
 			continue
 		}
 
@@ -362,6 +1326,7 @@ func (p *Pipeline) processDirectorySequential(cbzFiles []string) (*BatchResult,
 		if p.reporter != nil {
 			p.reporter.OnFileComplete(*result)
 		}
+		p.tracker.finish(cbzPath)
 	}
 
 	batch.TotalDuration = time.Since(startTime)
@@ -386,15 +1351,34 @@ func (p *Pipeline) processDirectorySequential(cbzFiles []string) (*BatchResult,
 }
 
 // processDirectoryParallel processes files concurrently using a worker pool
-func (p *Pipeline) processDirectoryParallel(cbzFiles []string, numWorkers int) (*BatchResult, error) {
+func (p *Pipeline) processDirectoryParallel(ctx context.Context, cbzFiles []string, numWorkers int) (*BatchResult, error) {
 	startTime := time.Now()
 	totalFiles := len(cbzFiles)
 
+	var mounts *mountQueue
+	if len(p.config.MountLimits) > 0 {
+		var err error
+		if mounts, err = newMountQueue(cbzFiles, p.config.MountLimits); err != nil {
+			return nil, err
+		}
+	}
+
+	// Archives weigh on the temp space and memory budgets by size. Dry runs
+	// only read headers, so neither applies.
+	var tempSpace, memory *byteBudget
+	if !p.config.DryRun {
+		tempSpace = newByteBudget(p.config.TempBudgetMB)
+		memory = newByteBudget(p.config.MaxInFlightMB)
+	}
+
 	// Create a safe reporter for concurrent use
 	var safeReporter ProgressReporter
 	if p.reporter != nil {
 		safeReporter = NewSafeReporter(p.reporter)
 	}
+	// Set before the workers copy the pipeline, so they share the tracker
+	p.tracker = newBatchProgress(safeReporter, cbzFiles)
+	defer func() { p.tracker = nil }()
 
 	// Create channels
 	jobs := make(chan FileJob, numWorkers)
@@ -406,16 +1390,38 @@ func (p *Pipeline) processDirectoryParallel(cbzFiles []string, numWorkers int) (
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			p.worker(jobs, results, safeReporter)
+			p.worker(ctx, jobs, results, safeReporter)
 		}()
 	}
 
-	// Send jobs (in separate goroutine to avoid deadlock)
+	// Send jobs (in separate goroutine to avoid deadlock); stop dispatching once aborted
+	stop := make(chan struct{})
+	var dispatched int
 	go func() {
-		for i, path := range cbzFiles {
-			jobs <- FileJob{Path: path, Index: i + 1, Total: totalFiles}
+		defer close(jobs)
+		for i := 0; i < totalFiles; i++ {
+			next := i
+			if mounts != nil {
+				var ok bool
+				if next, ok = mounts.next(cbzFiles, stop); !ok {
+					return
+				}
+			}
+			path := cbzFiles[next]
+			if tempSpace != nil && !tempSpace.acquire(path, p.tempEstimate(path), stop) {
+				return
+			}
+			if memory != nil && !memory.acquire(path, memoryEstimate(path), stop) {
+				tempSpace.release(path)
+				return
+			}
+			select {
+			case jobs <- FileJob{Path: cbzFiles[next], Index: next + 1, Total: totalFiles}:
+				dispatched++
+			case <-stop:
+				return
+			}
 		}
-		close(jobs)
 	}()
 
 	// Close results when all workers done
@@ -426,23 +1432,35 @@ func (p *Pipeline) processDirectoryParallel(cbzFiles []string, numWorkers int) (
 
 	// Collect results
 	batch := &BatchResult{
-		Results:    make([]Result, 0, totalFiles),
-		TotalFiles: totalFiles,
+		Results:      make([]Result, 0, totalFiles),
+		TotalFiles:   totalFiles,
+		IgnoredFiles: p.ignored,
 	}
 
 	for res := range results {
+		if mounts != nil {
+			mounts.release(res.Job.Path)
+		}
+		tempSpace.release(res.Job.Path)
+		memory.release(res.Job.Path)
 		if res.Error != nil {
 			batch.FailedFiles++
 			failedResult := Result{
-				SourcePath: res.Job.Path,
-				Errors:     []error{res.Error},
-				Index:      res.Job.Index,
-				Total:      res.Job.Total,
+				SourcePath:   res.Job.Path,
+				OriginalSize: fileSize(res.Job.Path),
+				Errors:       []error{res.Error},
+				Index:        res.Job.Index,
+				Total:        res.Job.Total,
 			}
 			batch.Results = append(batch.Results, failedResult)
 			if safeReporter != nil {
 				safeReporter.OnFileComplete(failedResult)
 			}
+			p.tracker.finish(res.Job.Path)
+			// In-flight jobs still finish and are collected; nothing new is dispatched
+			if !batch.Aborted && p.failureBudgetExceeded(batch) {
+				close(stop)
+			}
 			continue
 		}
 
@@ -459,6 +1477,12 @@ func (p *Pipeline) processDirectoryParallel(cbzFiles []string, numWorkers int) (
 		if safeReporter != nil {
 			safeReporter.OnFileComplete(*res.Result)
 		}
+		p.tracker.finish(res.Job.Path)
+	}
+
+	// results is closed only after the dispatcher has closed jobs, so dispatched is final here
+	if batch.Aborted {
+		batch.NotAttempted = totalFiles - dispatched
 	}
 
 	batch.TotalDuration = time.Since(startTime)
@@ -482,10 +1506,26 @@ func (p *Pipeline) processDirectoryParallel(cbzFiles []string, numWorkers int) (
 	return batch, nil
 }
 
+// failureBudgetExceeded marks the batch aborted once failures exceed MaxFailures (0 = unlimited)
+func (p *Pipeline) failureBudgetExceeded(batch *BatchResult) bool {
+	if p.config.MaxFailures < 1 || batch.FailedFiles < p.config.MaxFailures {
+		return false
+	}
+	batch.Aborted = true
+	batch.AbortReason = fmt.Sprintf("stopped after %d failed file(s) (max-failures %d)", batch.FailedFiles, p.config.MaxFailures)
+	return true
+}
+
 // worker processes files from the jobs channel and sends results
-func (p *Pipeline) worker(jobs <-chan FileJob, results chan<- FileResult, reporter ProgressReporter) {
+func (p *Pipeline) worker(ctx context.Context, jobs <-chan FileJob, results chan<- FileResult, reporter ProgressReporter) {
+	// Events raised while processing go through the shared, locked reporter
+	wp := *p
+	wp.reporter = reporter
 	for job := range jobs {
-		result, err := p.ProcessFile(job.Path)
+		if reporter != nil {
+			reporter.OnFileStart(job.Path, job.Index, job.Total)
+		}
+		result, err := wp.processFile(ctx, job.Path)
 		if result != nil {
 			result.Index = job.Index
 			result.Total = job.Total
@@ -501,6 +1541,7 @@ func (p *Pipeline) worker(jobs <-chan FileJob, results chan<- FileResult, report
 // ConsoleReporter implements ProgressReporter for terminal output
 type ConsoleReporter struct {
 	verbose bool
+	quiet   bool // No per-archive lines, only summaries (-progress none)
 	writer  io.Writer
 }
 
@@ -525,24 +1566,39 @@ func (r *ConsoleReporter) OnImageProcessed(imagePath string, originalSize, newSi
 		savings := float64(originalSize-newSize) / float64(originalSize) * 100
 		fmt.Fprintf(r.writer, "    %s: %s -> %s (%.1f%% saved)\n",
 			filepath.Base(imagePath),
-			formatBytes(originalSize),
-			formatBytes(newSize),
+			FormatBytes(originalSize),
+			FormatBytes(newSize),
 			savings)
 	}
 }
 
+func (r *ConsoleReporter) OnPageProgress(path string, progress PageProgress) {
+	if r.quiet {
+		return
+	}
+	fmt.Fprintf(r.writer, "    %s: %d/%d pages, %s of %s\n",
+		truncateString(filepath.Base(path), 42),
+		progress.Done, progress.Total,
+		FormatBytes(progress.BytesDone),
+		FormatBytes(progress.BytesTotal))
+}
+
 func (r *ConsoleReporter) OnFileComplete(result Result) {
+	// Quiet runs still name failed archives
+	if r.quiet && (result.Analysis != nil || result.Skipped || result.OutputPath != "" || len(result.Errors) == 0) {
+		return
+	}
 	fileName := filepath.Base(result.SourcePath)
 	progress := fmt.Sprintf("[%d/%d]", result.Index, result.Total)
 
 	// Handle dry-run mode (Analysis is populated)
 	if result.Analysis != nil {
 		analysis := result.Analysis
-		sizeStr := formatBytes(analysis.FileSize)
+		sizeStr := FormatBytes(analysis.FileSize)
 
 		if analysis.NeedsProcessing {
 			savingsStr := fmt.Sprintf("~%s (%.0f%%)",
-				formatBytes(analysis.EstimatedSavingsBytes),
+				FormatBytes(analysis.EstimatedSavingsBytes),
 				analysis.EstimatedSavingsPct)
 			reasonStr := strings.Join(analysis.ProcessingReasons, ", ")
 			fmt.Fprintf(r.writer, "%s %-42s %10s  %15s  %s\n",
@@ -551,6 +1607,10 @@ func (r *ConsoleReporter) OnFileComplete(result Result) {
 			fmt.Fprintf(r.writer, "%s %-42s %10s  %15s  [SKIP] %s\n",
 				progress, truncateString(fileName, 42), sizeStr, "-", analysis.SkipReason)
 		}
+		// Merged archives can be split with -split
+		if analysis.Chapters != nil {
+			fmt.Fprintf(r.writer, "    %d chapters: %s\n", len(analysis.Chapters), strings.Join(analysis.Chapters, ", "))
+		}
 		return
 	}
 
@@ -561,8 +1621,8 @@ func (r *ConsoleReporter) OnFileComplete(result Result) {
 		return
 	}
 
-	// Handle failed files (non-dry-run)
-	if len(result.Errors) > 0 {
+	// Handle failed files (non-dry-run); written archives only carry warnings
+	if len(result.Errors) > 0 && result.OutputPath == "" {
 		fmt.Fprintf(r.writer, "%s %-42s  [FAIL] %v\n",
 			progress, truncateString(fileName, 42), result.Errors[0])
 		return
@@ -574,11 +1634,60 @@ func (r *ConsoleReporter) OnFileComplete(result Result) {
 		fmt.Fprintf(r.writer, "%s %-42s %10s -> %10s  (%.1f%% saved, %d images, %v)\n",
 			progress,
 			truncateString(fileName, 42),
-			formatBytes(result.OriginalSize),
-			formatBytes(result.CompressedSize),
+			FormatBytes(result.OriginalSize),
+			FormatBytes(result.CompressedSize),
 			savings,
 			result.ImagesProcessed,
 			result.Duration.Round(time.Millisecond))
+
+		if result.ComposedPages > 0 {
+			fmt.Fprintf(r.writer, "    composed %d strips into %d pages\n", result.StripsComposed, result.ComposedPages)
+		}
+
+		for _, unsafe := range result.UnsafePaths {
+			fmt.Fprintf(r.writer, "    normalized unsafe path %s\n", unsafe)
+		}
+		for _, page := range result.SalvagedPages {
+			fmt.Fprintf(r.writer, "    salvaged corrupt page %s\n", page)
+		}
+		for _, entry := range result.DroppedEntries {
+			fmt.Fprintf(r.writer, "    dropped corrupt entry %s\n", entry)
+		}
+		for _, pageErr := range result.PageErrors {
+			fmt.Fprintf(r.writer, "    kept failed page %s [%s]: %v\n", pageErr.Page, pageErr.Stage, pageErr.Err)
+		}
+		for _, err := range result.Errors {
+			fmt.Fprintf(r.writer, "    warning: %v\n", err)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(r.writer, "    warning: %s\n", warning)
+		}
+
+		if r.verbose {
+			printPageSizes(r.writer, result.PageSizes)
+		}
+
+		if r.verbose && len(result.KeptPages) > 0 {
+			pages := make([]string, 0, len(result.KeptPages))
+			for page := range result.KeptPages {
+				pages = append(pages, page)
+			}
+			sort.Slice(pages, func(i, j int) bool { return cbz.NaturalLess(pages[i], pages[j]) })
+			for _, page := range pages {
+				fmt.Fprintf(r.writer, "    kept %s (%s)\n", page, result.KeptPages[page])
+			}
+		}
+
+		if r.verbose && len(result.Deskewed) > 0 {
+			pages := make([]string, 0, len(result.Deskewed))
+			for page := range result.Deskewed {
+				pages = append(pages, page)
+			}
+			sort.Slice(pages, func(i, j int) bool { return cbz.NaturalLess(pages[i], pages[j]) })
+			for _, page := range pages {
+				fmt.Fprintf(r.writer, "    deskewed %s by %+.1f°\n", page, result.Deskewed[page])
+			}
+		}
 	}
 }
 
@@ -588,19 +1697,70 @@ func (r *ConsoleReporter) OnBatchComplete(result BatchResult) {
 	fmt.Fprintf(r.writer, "Total files:    %d\n", result.TotalFiles)
 	fmt.Fprintf(r.writer, "Processed:      %d\n", result.ProcessedFiles)
 	fmt.Fprintf(r.writer, "Skipped:        %d\n", result.SkippedFiles)
+	for _, kind := range sortedSkipKinds(result.SkipReasons()) {
+		fmt.Fprintf(r.writer, "  %-20s %d\n", kind.name+":", kind.count)
+	}
+	if result.IgnoredFiles > 0 {
+		fmt.Fprintf(r.writer, "Ignored:        %d (skip patterns and exclude files)\n", result.IgnoredFiles)
+	}
 	fmt.Fprintf(r.writer, "Failed:         %d\n", result.FailedFiles)
+	if result.Aborted {
+		fmt.Fprintf(r.writer, "Not attempted:  %d\n", result.NotAttempted)
+		fmt.Fprintf(r.writer, "ABORTED:        %s\n", result.AbortReason)
+	}
 
 	if result.TotalOriginal > 0 {
 		savings := float64(result.TotalOriginal-result.TotalCompressed) / float64(result.TotalOriginal) * 100
-		fmt.Fprintf(r.writer, "Original size:  %s\n", formatBytes(result.TotalOriginal))
-		fmt.Fprintf(r.writer, "Compressed:     %s\n", formatBytes(result.TotalCompressed))
+		fmt.Fprintf(r.writer, "Original size:  %s\n", FormatBytes(result.TotalOriginal))
+		fmt.Fprintf(r.writer, "Compressed:     %s\n", FormatBytes(result.TotalCompressed))
 		fmt.Fprintf(r.writer, "Savings:        %s (%.1f%%)\n",
-			formatBytes(result.TotalOriginal-result.TotalCompressed), savings)
+			FormatBytes(result.TotalOriginal-result.TotalCompressed), savings)
+		writeSavingsHistogram(r.writer, result.SavingsHistogram())
 	}
 	fmt.Fprintf(r.writer, "Duration:       %v\n", result.TotalDuration.Round(time.Second))
+	if pages := result.HighBitDepthPages(); pages > 0 {
+		fmt.Fprintf(r.writer, "16-bit pages:   %d (reduced to 8-bit)\n", pages)
+	}
+	if pages := result.CMYKPages(); pages > 0 {
+		fmt.Fprintf(r.writer, "CMYK pages:     %d (converted to RGB)\n", pages)
+	}
+	if pages := result.LowQualityPages(); pages > 0 {
+		fmt.Fprintf(r.writer, "Low quality:    %d pages kept (below target quality)\n", pages)
+	}
+	if pages := result.ModernPages(); pages > 0 {
+		fmt.Fprintf(r.writer, "WebP/AVIF:      %d pages kept (already well compressed)\n", pages)
+	}
+	if wins := result.CodecWins(); len(wins) > 0 {
+		fmt.Fprintf(r.writer, "Codec wins:     %s\n", formatCodecWins(wins))
+	}
+
+	// Skipped and failed archives dilute the savings above; show both views
+	if library := result.Library(); library.Before > 0 && library.Unchanged > 0 {
+		fmt.Fprintln(r.writer)
+		fmt.Fprintln(r.writer, "=== Library ===")
+		fmt.Fprintf(r.writer, "Archives:       %d (%s left unchanged)\n", library.Archives, FormatBytes(library.Unchanged))
+		fmt.Fprintf(r.writer, "Before:         %s\n", FormatBytes(library.Before))
+		fmt.Fprintf(r.writer, "After:          %s\n", FormatBytes(library.After))
+		fmt.Fprintf(r.writer, "Savings:        %s (%.1f%%)\n", FormatBytes(library.Saved()), library.Percent())
+	}
+
+	// Per-series breakdown only adds information when the batch spans several directories
+	if series := result.SeriesStats(); len(series) > 1 {
+		fmt.Fprintln(r.writer)
+		fmt.Fprintln(r.writer, "=== By Series ===")
+		fmt.Fprintf(r.writer, "%-40s %5s %5s %5s %5s %10s %10s %7s\n",
+			"Series", "Files", "Proc", "Skip", "Fail", "Before", "After", "Saved")
+		for _, s := range series {
+			fmt.Fprintf(r.writer, "%-40s %5d %5d %5d %5d %10s %10s %6.1f%%\n",
+				truncateString(filepath.Base(s.Series), 40),
+				s.Files, s.ProcessedFiles, s.SkippedFiles, s.FailedFiles,
+				FormatBytes(s.TotalOriginal), FormatBytes(s.TotalCompressed), s.SavingsPercent())
+		}
+	}
 }
 
-func formatBytes(bytes int64) string {
+// FormatBytes renders a byte count in human-readable units (e.g., "1.5 MB")
+func FormatBytes(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
 		return fmt.Sprintf("%d B", bytes)
@@ -617,6 +1777,10 @@ func (r *ConsoleReporter) OnDryRunFile(result *analyzer.AnalysisResult) {
 	// No-op: output is now combined into OnFileComplete for cleaner display
 }
 
+func (r *ConsoleReporter) OnBatchProgress(processedBytes, totalBytes int64, elapsed time.Duration) {
+	// No-op: plain output has no line to update; the bar shows the ETA
+}
+
 func (r *ConsoleReporter) OnDryRunComplete(summary *analyzer.DryRunSummary) {
 	fmt.Fprintln(r.writer)
 	fmt.Fprintln(r.writer, "=== DRY RUN SUMMARY ===")
@@ -626,10 +1790,10 @@ func (r *ConsoleReporter) OnDryRunComplete(summary *analyzer.DryRunSummary) {
 	if len(summary.FilesToProcess) > 0 {
 		fmt.Fprintln(r.writer)
 		fmt.Fprintln(r.writer, "ESTIMATED TOTALS:")
-		fmt.Fprintf(r.writer, "  Current size:      %s\n", formatBytes(summary.TotalCurrentSize))
-		fmt.Fprintf(r.writer, "  Estimated after:   ~%s\n", formatBytes(summary.TotalEstimatedNew))
+		fmt.Fprintf(r.writer, "  Current size:      %s\n", FormatBytes(summary.TotalCurrentSize))
+		fmt.Fprintf(r.writer, "  Estimated after:   ~%s\n", FormatBytes(summary.TotalEstimatedNew))
 		fmt.Fprintf(r.writer, "  Estimated savings: ~%s (%.1f%%)\n",
-			formatBytes(summary.TotalSavings), summary.SavingsPercent)
+			FormatBytes(summary.TotalSavings), summary.SavingsPercent)
 		fmt.Fprintln(r.writer)
 		fmt.Fprintln(r.writer, "Note: Estimates are approximate. Actual savings may vary.")
 	}
@@ -677,6 +1841,14 @@ func (s *SafeReporter) OnImageProcessed(imagePath string, originalSize, newSize
 	}
 }
 
+func (s *SafeReporter) OnPageProgress(path string, progress PageProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reporter != nil {
+		s.reporter.OnPageProgress(path, progress)
+	}
+}
+
 func (s *SafeReporter) OnFileComplete(result Result) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -693,6 +1865,14 @@ func (s *SafeReporter) OnBatchComplete(result BatchResult) {
 	}
 }
 
+func (s *SafeReporter) OnBatchProgress(processedBytes, totalBytes int64, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reporter != nil {
+		s.reporter.OnBatchProgress(processedBytes, totalBytes, elapsed)
+	}
+}
+
 func (s *SafeReporter) OnDryRunFile(result *analyzer.AnalysisResult) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"compress_comics/internal/backup"
+)
+
+// PreflightError lists every directory a batch would fail to write to
+type PreflightError struct {
+	Problems []string // "<dir>: <reason> (<what needs it>)", sorted
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("preflight found %d unwritable location(s), nothing was changed:\n  %s",
+		len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// Preflight checks, before anything is changed, that the batch can write
+// everywhere it will need to: the directory each compressed archive goes
+// to, the backup directory (also home of the journal and history) and the
+// temp directory. Each directory is probed once by creating and removing a
+// file, which also catches read-only mounts and ACLs that mode bits don't
+// show. All problems are returned together as a *PreflightError.
+func (p *Pipeline) Preflight(cbzFiles []string) error {
+	needs := make(map[string]string) // Directory -> what needs to write there
+	need := func(dir, what string) {
+		if _, ok := needs[dir]; !ok {
+			needs[dir] = what
+		}
+	}
+
+	var problems []string
+	backsUp := false
+	for _, cbzPath := range cbzFiles {
+		dest, err := p.outputPath(cbzPath)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", cbzPath, err))
+			continue
+		}
+		if p.writesCopy(cbzPath, dest) {
+			need(filepath.Dir(absPath(dest)), "output archives")
+		} else {
+			need(filepath.Dir(absPath(cbzPath)), "archives replaced in place")
+			backsUp = true
+		}
+	}
+	if backsUp && backup.Mode(p.config.BackupMode) != backup.ModeTrash {
+		need(absPath(p.config.BackupDir), "backups")
+	}
+	if tempDir := p.config.TempDir; tempDir != "" || p.config.LocalCopy {
+		if tempDir == "" {
+			tempDir = os.TempDir()
+		}
+		need(absPath(tempDir), "temporary archives")
+	}
+
+	for dir, what := range needs {
+		if err := probeWritable(dir); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v (%s)", dir, err, what))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return &PreflightError{Problems: problems}
+}
+
+// probeWritable creates and removes a file in dir, or in its nearest
+// existing parent when dir doesn't exist yet (it is created on first use)
+func probeWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, os.ErrNotExist) || parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".cbz-preflight-*")
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return fmt.Errorf("not writable: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
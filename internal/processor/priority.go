@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// prioritizeRecent moves archives modified within the last p.config.RecentDays
+// days to the front, newest first, so fresh downloads are done early in a
+// long backfill. The backlog keeps its scan order behind them.
+func (p *Pipeline) prioritizeRecent(cbzFiles []string) []string {
+	if p.config.RecentDays <= 0 {
+		return cbzFiles
+	}
+	cutoff := time.Now().Add(-time.Duration(p.config.RecentDays) * 24 * time.Hour)
+
+	type recentFile struct {
+		path    string
+		modTime time.Time
+	}
+	var recent []recentFile
+	backlog := make([]string, 0, len(cbzFiles))
+	for _, path := range cbzFiles {
+		info, err := os.Stat(path)
+		if err == nil && info.ModTime().After(cutoff) {
+			recent = append(recent, recentFile{path, info.ModTime()})
+		} else {
+			backlog = append(backlog, path)
+		}
+	}
+	if len(recent) == 0 {
+		return cbzFiles
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].modTime.After(recent[j].modTime) })
+
+	ordered := make([]string, 0, len(cbzFiles))
+	for _, file := range recent {
+		ordered = append(ordered, file.path)
+	}
+	return append(ordered, backlog...)
+}
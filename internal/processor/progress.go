@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"compress_comics/internal/analyzer"
+)
+
+// Progress output styles (-progress)
+const (
+	ProgressAuto  = "auto"  // bar on a terminal, plain otherwise (cron, CI, pipes)
+	ProgressBar   = "bar"   // One line per archive plus a live bar redrawn below them
+	ProgressPlain = "plain" // One line per archive, no terminal control codes
+	ProgressNone  = "none"  // Only the summary
+)
+
+// ParseProgressMode validates a -progress value
+func ParseProgressMode(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case ProgressAuto, ProgressBar, ProgressPlain, ProgressNone:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown progress mode %q (must be auto, bar, plain or none)", s)
+}
+
+// IsTerminal reports whether f is an interactive terminal rather than a
+// file or pipe. TERM=dumb terminals can't redraw lines, so they don't count.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// NewProgressReporter returns the console reporter for a progress mode,
+// resolving auto by whether out is a terminal
+func NewProgressReporter(mode string, verbose bool, out *os.File) ProgressReporter {
+	if mode == ProgressAuto {
+		mode = ProgressPlain
+		if IsTerminal(out) {
+			mode = ProgressBar
+		}
+	}
+	console := NewConsoleReporter(verbose, out)
+	switch mode {
+	case ProgressBar:
+		return &BarReporter{ConsoleReporter: console, writer: out}
+	case ProgressNone:
+		console.quiet = true
+	}
+	return console
+}
+
+// barWidth is the number of cells in the progress bar
+const barWidth = 30
+
+// BarReporter is the console reporter with a live progress bar on the last
+// line: archive lines are printed above it, and the bar is redrawn after
+// each one. Calls must be serialized (see SafeReporter).
+type BarReporter struct {
+	*ConsoleReporter
+	writer  io.Writer
+	done    int
+	total   int
+	saved   int64
+	current string // Archive started last
+	pages   string // Page progress of a slow archive
+	eta     time.Duration
+	drawn   bool
+}
+
+func (r *BarReporter) OnFileStart(path string, index, total int) {
+	r.total = total
+	r.current = filepath.Base(path)
+	r.pages = ""
+	r.draw()
+}
+
+func (r *BarReporter) OnImageProcessed(imagePath string, originalSize, newSize int64) {
+	r.clear()
+	r.ConsoleReporter.OnImageProcessed(imagePath, originalSize, newSize)
+	r.draw()
+}
+
+func (r *BarReporter) OnPageProgress(path string, progress PageProgress) {
+	r.current = filepath.Base(path)
+	r.pages = fmt.Sprintf("%d/%d pages", progress.Done, progress.Total)
+	r.draw()
+}
+
+func (r *BarReporter) OnBatchProgress(processedBytes, totalBytes int64, elapsed time.Duration) {
+	r.eta = EstimateRemaining(processedBytes, totalBytes, elapsed)
+	r.draw()
+}
+
+func (r *BarReporter) OnFileComplete(result Result) {
+	r.done++
+	if result.CompressedSize > 0 {
+		r.saved += result.OriginalSize - result.CompressedSize
+	}
+	r.pages = ""
+	r.clear()
+	r.ConsoleReporter.OnFileComplete(result)
+	r.draw()
+}
+
+func (r *BarReporter) OnBatchComplete(result BatchResult) {
+	r.clear()
+	r.ConsoleReporter.OnBatchComplete(result)
+}
+
+func (r *BarReporter) OnDryRunComplete(summary *analyzer.DryRunSummary) {
+	r.clear()
+	r.ConsoleReporter.OnDryRunComplete(summary)
+}
+
+// draw redraws the bar in place
+func (r *BarReporter) draw() {
+	if r.total == 0 {
+		return
+	}
+	filled := barWidth * r.done / r.total
+	status := r.current
+	if r.pages != "" {
+		status += " (" + r.pages + ")"
+	}
+	eta := ""
+	if r.eta > 0 {
+		eta = "  ETA " + r.eta.String()
+	}
+	fmt.Fprintf(r.writer, "\r\033[K[%s%s] %d/%d  %s saved%s  %s",
+		strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled),
+		r.done, r.total, FormatBytes(r.saved), eta, truncateString(status, 50))
+	r.drawn = true
+}
+
+// clear erases the bar so regular output can take its line
+func (r *BarReporter) clear() {
+	if r.drawn {
+		fmt.Fprint(r.writer, "\r\033[K")
+		r.drawn = false
+	}
+}
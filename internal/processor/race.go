@@ -0,0 +1,249 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/codec"
+	"compress_comics/internal/fileclass"
+)
+
+// Candidate codecs for the encoding race
+const (
+	CodecJPEG     = "jpeg"
+	CodecWebP     = "webp"
+	CodecOriginal = "original" // Keep the source bytes (only when no resize was needed)
+)
+
+// Encoder encodes a page at a quality of 1-100 (the jpeg_quality scale)
+type Encoder func(img image.Image, quality int) ([]byte, error)
+
+// registeredEncoder is a codec added with RegisterEncoder
+type registeredEncoder struct {
+	ext    string
+	encode Encoder
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = make(map[string]registeredEncoder)
+)
+
+// RegisterEncoder adds a codec to the encoding race, usable in -codecs and
+// the codecs setting like jpeg and webp. Pages it wins are stored with ext,
+// which must belong to a known format (see fileclass.RegisterFormat) so later
+// runs read them back as pages.
+func RegisterEncoder(name, ext string, encode Encoder) error {
+	name = strings.ToLower(name)
+	switch {
+	case name == CodecJPEG || name == CodecWebP || name == CodecOriginal:
+		return fmt.Errorf("codec %s is built in", name)
+	case name == "" || strings.Contains(name, ","):
+		return fmt.Errorf("invalid codec name %q", name)
+	case !fileclass.IsImage("page" + ext):
+		return fmt.Errorf("codec %s: %s is not a registered page format", name, ext)
+	}
+
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[name] = registeredEncoder{ext: strings.ToLower(ext), encode: encode}
+	return nil
+}
+
+// registeredCodec returns a codec added with RegisterEncoder
+func registeredCodec(name string) (registeredEncoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	enc, ok := encoders[name]
+	return enc, ok
+}
+
+// codecNames lists every codec for error messages
+func codecNames() string {
+	names := []string{CodecJPEG, CodecWebP, CodecOriginal}
+	encodersMu.RLock()
+	for name := range encoders {
+		names = append(names, name)
+	}
+	encodersMu.RUnlock()
+	sort.Strings(names[3:])
+	return strings.Join(names, ", ")
+}
+
+// ParseCodecs parses a comma-separated codec list such as "jpeg,webp,original"
+func ParseCodecs(spec string) ([]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var codecs []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case CodecJPEG, CodecOriginal:
+		case CodecWebP:
+			if !codec.WebPEncoderAvailable() {
+				return nil, fmt.Errorf("codec webp needs cwebp (libwebp) on PATH")
+			}
+		default:
+			if _, ok := registeredCodec(name); !ok {
+				return nil, fmt.Errorf("unknown codec %q (must be one of %s)", name, codecNames())
+			}
+		}
+		if !seen[name] {
+			seen[name] = true
+			codecs = append(codecs, name)
+		}
+	}
+	return codecs, nil
+}
+
+// candidate is one codec's encoding of a page
+type candidate struct {
+	codec string
+	data  []byte
+	err   error
+}
+
+// race encodes img with every configured codec in parallel and keeps the
+// smallest. JPEG is encoded at the page's quality, which is the quality bar,
+// and WebP at the cwebp quality that matches it (see webpQuality); the
+// original qualifies only if the page needed no resize. Ties go to the codec
+// listed first.
+func (p *ImageProcessor) race(img image.Image, entry cbz.ImageEntry, result *ProcessedImage) (*ProcessedImage, error) {
+	candidates := make([]candidate, len(p.opts.Codecs))
+
+	var wg sync.WaitGroup
+	for i, name := range p.opts.Codecs {
+		if name == CodecOriginal {
+			candidates[i] = candidate{codec: name, data: entry.Data}
+			if result.WasResized {
+				candidates[i].err = fmt.Errorf("original exceeds %dx%dpx", p.maxDimension, p.maxHeight())
+			} else if result.CMYK {
+				candidates[i].err = fmt.Errorf("original is CMYK")
+			} else if result.Adjusted {
+				candidates[i].err = fmt.Errorf("original lacks levels adjustment")
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := p.encode(name, img, result.Quality)
+			candidates[i] = candidate{codec: name, data: data, err: err}
+		}()
+	}
+	wg.Wait()
+
+	best := -1
+	var errs []error
+	for i, c := range candidates {
+		if c.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.codec, c.err))
+			continue
+		}
+		if best < 0 || len(c.data) < len(candidates[best].data) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, &PageError{Page: entry.Path, Stage: StageEncode, Err: fmt.Errorf("no codec succeeded: %w", errors.Join(errs...))}
+	}
+
+	win := candidates[best]
+	result.Codec = win.codec
+	result.Data = win.data
+	result.NewSize = int64(len(win.data))
+
+	stem := strings.TrimSuffix(entry.Path, filepath.Ext(entry.Path))
+	switch win.codec {
+	case CodecJPEG:
+		result.NewPath = stem + ".jpg"
+	case CodecWebP:
+		result.NewPath = stem + ".webp"
+	case CodecOriginal:
+		result.NewPath = entry.Path
+		result.Kept = KeptLarger
+	default:
+		enc, _ := registeredCodec(win.codec)
+		result.NewPath = stem + enc.ext
+	}
+	result.WasConverted = fileclass.FormatOf(result.NewPath) != fileclass.FormatOf(entry.Path)
+
+	return result, nil
+}
+
+// encode encodes img with one codec at the given quality
+func (p *ImageProcessor) encode(name string, img image.Image, quality int) ([]byte, error) {
+	switch name {
+	case CodecJPEG:
+		return p.encodeJPEG(img, quality)
+	case CodecWebP:
+		return codec.EncodeWebP(img, webpQuality(quality))
+	}
+	if enc, ok := registeredCodec(name); ok {
+		return enc.encode(img, quality)
+	}
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+// webpQualities pairs JPEG qualities with the cwebp -q that reaches about
+// the same SSIM on typical pages, in ascending order. cwebp's scale runs
+// lower than libjpeg's: given the JPEG quality unchanged, WebP spends bytes
+// on detail the JPEG it competes with doesn't keep, and loses the race.
+var webpQualities = [][2]int{{1, 1}, {50, 40}, {75, 65}, {85, 76}, {90, 82}, {95, 90}, {100, 100}}
+
+// webpQuality maps a JPEG quality to cwebp's scale, interpolating between
+// the pairs of webpQualities
+func webpQuality(jpegQuality int) int {
+	jpegQuality = max(1, min(100, jpegQuality))
+	for i := 1; i < len(webpQualities); i++ {
+		lo, hi := webpQualities[i-1], webpQualities[i]
+		if jpegQuality <= hi[0] {
+			return lo[1] + (jpegQuality-lo[0])*(hi[1]-lo[1])/(hi[0]-lo[0])
+		}
+	}
+	return 100
+}
+
+// CodecWins sums the pages each codec won across the batch
+func (b BatchResult) CodecWins() map[string]int {
+	var wins map[string]int
+	for _, result := range b.Results {
+		for name, n := range result.CodecWins {
+			if wins == nil {
+				wins = make(map[string]int)
+			}
+			wins[name] += n
+		}
+	}
+	return wins
+}
+
+// formatCodecWins renders wins most-winning first, e.g. "webp 40, jpeg 12"
+func formatCodecWins(wins map[string]int) string {
+	names := make([]string, 0, len(wins))
+	for name := range wins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if wins[names[i]] != wins[names[j]] {
+			return wins[names[i]] > wins[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, wins[name])
+	}
+	return strings.Join(parts, ", ")
+}
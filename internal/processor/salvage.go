@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/fileclass"
+)
+
+// salvageCorrupt returns the contents of an archive with corrupt entries
+// (-salvage): corrupt pages that still decode completely are kept, and every
+// other corrupt entry is dropped, so damage never passes silently into the
+// rewritten archive. Both are recorded in result.
+func (p *Pipeline) salvageCorrupt(corrupt *cbz.CorruptError, result *Result) *cbz.Contents {
+	contents := corrupt.Contents
+	limits := DecodeLimitsFromConfig(p.config)
+	for _, entry := range corrupt.Entries {
+		page := fileclass.PageName(entry.Path, entry.Data)
+		if page == "" {
+			result.DroppedEntries = append(result.DroppedEntries, fmt.Sprintf("%s (%v)", entry.Path, entry.Err))
+			continue
+		}
+		img := cbz.ImageEntry{Path: page, OriginalSize: int64(len(entry.Data)), Data: entry.Data}
+		if err := decodesFully(img, limits); err != nil {
+			result.DroppedEntries = append(result.DroppedEntries, fmt.Sprintf("%s (%v; %v)", entry.Path, entry.Err, err))
+			continue
+		}
+		contents.Images = append(contents.Images, img)
+		result.SalvagedPages = append(result.SalvagedPages, fmt.Sprintf("%s (%v)", entry.Path, entry.Err))
+	}
+	sort.Slice(contents.Images, func(i, j int) bool {
+		return cbz.NaturalLess(contents.Images[i].Path, contents.Images[j].Path)
+	})
+	return contents
+}
+
+// decodesFully reports why a page can't be decoded to the end, or nil
+func decodesFully(img cbz.ImageEntry, limits DecodeLimits) (err error) {
+	defer recoverPanic(img.Path, &err)
+	if err := checkDecodeLimits(img.Data, limits); err != nil {
+		return err
+	}
+	_, _, err = decodePage(img)
+	return err
+}
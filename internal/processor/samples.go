@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/cbz"
+)
+
+// exportSamples writes before/after pairs for evenly spaced pages of an archive.
+// Samples land in <SampleDir>/<parent dir>/<archive name>/ so same-named archives
+// from different series don't overwrite each other. encoded holds the written
+// page for each sampled index (see sampleIndices).
+func (p *Pipeline) exportSamples(cbzPath string, images []cbz.ImageEntry, encoded map[int]cbz.ImageEntry) error {
+	if len(images) == 0 || p.config.SampleCount < 1 {
+		return nil
+	}
+
+	archiveName := strings.TrimSuffix(filepath.Base(cbzPath), filepath.Ext(cbzPath))
+	seriesName := filepath.Base(filepath.Dir(cbzPath))
+	outDir := filepath.Join(p.config.SampleDir, seriesName, archiveName)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create sample dir %s: %w", outDir, err)
+	}
+
+	for _, i := range sampleIndices(len(images), p.config.SampleCount) {
+		before := images[i]
+		after := encoded[i]
+
+		prefix := fmt.Sprintf("%03d_", i+1)
+		beforePath := filepath.Join(outDir, prefix+"before"+strings.ToLower(filepath.Ext(before.Path)))
+		afterPath := filepath.Join(outDir, prefix+"after"+strings.ToLower(filepath.Ext(after.Path)))
+
+		if err := os.WriteFile(beforePath, before.Data, 0644); err != nil {
+			return fmt.Errorf("failed to write sample %s: %w", beforePath, err)
+		}
+		if err := os.WriteFile(afterPath, after.Data, 0644); err != nil {
+			return fmt.Errorf("failed to write sample %s: %w", afterPath, err)
+		}
+	}
+
+	return nil
+}
+
+// sampleIndices picks n page indices spread evenly across total pages
+func sampleIndices(total, n int) []int {
+	if n > total {
+		n = total
+	}
+	indices := make([]int, 0, n)
+	for k := 0; k < n; k++ {
+		indices = append(indices, (2*k+1)*total/(2*n))
+	}
+	return indices
+}
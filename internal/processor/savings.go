@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// savingsBounds split the summary's savings histogram into buckets:
+// < 10%, 10-30%, 30-50% and >= 50%
+var savingsBounds = []float64{10, 30, 50}
+
+// savingsBarWidth is the length of the longest histogram bar
+const savingsBarWidth = 30
+
+// SavingsHistogram counts compressed archives by the share of their size
+// saved, one count per bucket of savingsBounds plus one above the last
+func (b BatchResult) SavingsHistogram() []int {
+	counts := make([]int, len(savingsBounds)+1)
+	for _, result := range b.Results {
+		if result.Skipped || result.OutputPath == "" || result.OriginalSize <= 0 {
+			continue
+		}
+		saved := float64(result.OriginalSize-result.CompressedSize) / float64(result.OriginalSize) * 100
+		bucket := len(savingsBounds)
+		for i, bound := range savingsBounds {
+			if saved < bound {
+				bucket = i
+				break
+			}
+		}
+		counts[bucket]++
+	}
+	return counts
+}
+
+// LibrarySavings measures a batch over every archive it covered: compressed
+// archives at their new size, skipped and failed ones at their unchanged
+// size. TotalOriginal and TotalCompressed count compressed archives only,
+// so their percentage says how well compression worked; this one says how
+// much smaller the library got.
+type LibrarySavings struct {
+	Archives  int   // Archives in the batch with a known size
+	Before    int64 // Their total size before the run
+	After     int64 // Their total size after it
+	Unchanged int64 // Bytes in archives left as they were
+}
+
+// Saved is the number of bytes the library shrank by
+func (l LibrarySavings) Saved() int64 {
+	return l.Before - l.After
+}
+
+// Percent is Saved as a share of Before
+func (l LibrarySavings) Percent() float64 {
+	if l.Before == 0 {
+		return 0
+	}
+	return float64(l.Saved()) / float64(l.Before) * 100
+}
+
+// Library totals the batch as LibrarySavings. Archives that would be
+// compressed in a dry run count as unchanged.
+func (b BatchResult) Library() LibrarySavings {
+	var l LibrarySavings
+	for _, result := range b.Results {
+		if result.OriginalSize <= 0 {
+			continue
+		}
+		l.Archives++
+		l.Before += result.OriginalSize
+		if !result.Skipped && result.OutputPath != "" {
+			l.After += result.CompressedSize
+		} else {
+			l.After += result.OriginalSize
+			l.Unchanged += result.OriginalSize
+		}
+	}
+	return l
+}
+
+// writeSavingsHistogram prints one bar per bucket, scaled to the fullest
+func writeSavingsHistogram(w io.Writer, counts []int) {
+	largest := 0
+	for _, n := range counts {
+		largest = max(largest, n)
+	}
+	if largest == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "Savings per archive:")
+	for i, n := range counts {
+		var label string
+		switch {
+		case i == 0:
+			label = fmt.Sprintf("< %g%%", savingsBounds[0])
+		case i == len(savingsBounds):
+			label = fmt.Sprintf(">= %g%%", savingsBounds[i-1])
+		default:
+			label = fmt.Sprintf("%g-%g%%", savingsBounds[i-1], savingsBounds[i])
+		}
+		bar := int(math.Round(float64(n) / float64(largest) * savingsBarWidth))
+		line := fmt.Sprintf("  %-8s %5d  %s", label, n, strings.Repeat("#", bar))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
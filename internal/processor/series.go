@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// SeriesStat aggregates batch results for one series (the archive's parent directory)
+type SeriesStat struct {
+	Series          string // Parent directory path
+	Files           int
+	ProcessedFiles  int
+	SkippedFiles    int
+	FailedFiles     int
+	TotalOriginal   int64 // Size before compression (processed files only)
+	TotalCompressed int64 // Size after compression (processed files only)
+}
+
+// SavingsPercent returns the percentage saved on processed files
+func (s SeriesStat) SavingsPercent() float64 {
+	if s.TotalOriginal == 0 {
+		return 0
+	}
+	return float64(s.TotalOriginal-s.TotalCompressed) / float64(s.TotalOriginal) * 100
+}
+
+// SeriesStats groups the batch by parent directory, largest savings first
+func (b BatchResult) SeriesStats() []SeriesStat {
+	bySeries := make(map[string]*SeriesStat)
+
+	for _, result := range b.Results {
+		dir := filepath.Dir(result.SourcePath)
+		stat, ok := bySeries[dir]
+		if !ok {
+			stat = &SeriesStat{Series: dir}
+			bySeries[dir] = stat
+		}
+
+		stat.Files++
+		switch {
+		case result.Skipped:
+			stat.SkippedFiles++
+		case result.OutputPath == "" && len(result.Errors) > 0:
+			stat.FailedFiles++
+		case result.OutputPath != "":
+			// Dry runs write nothing, so their archives count in Files only
+			stat.ProcessedFiles++
+			stat.TotalOriginal += result.OriginalSize
+			stat.TotalCompressed += result.CompressedSize
+		}
+	}
+
+	stats := make([]SeriesStat, 0, len(bySeries))
+	for _, stat := range bySeries {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		si := stats[i].TotalOriginal - stats[i].TotalCompressed
+		sj := stats[j].TotalOriginal - stats[j].TotalCompressed
+		if si != sj {
+			return si > sj
+		}
+		return stats[i].Series < stats[j].Series
+	})
+
+	return stats
+}
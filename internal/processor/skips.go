@@ -0,0 +1,48 @@
+package processor
+
+import "sort"
+
+// SkipExists is the skip kind of archives whose output already exists
+// (on_collision: skip)
+const SkipExists = "output exists"
+
+// SkipReasons counts skipped archives by skip kind. Skips without a kind
+// are counted as "other".
+func (b BatchResult) SkipReasons() map[string]int {
+	var kinds map[string]int
+	for _, result := range b.Results {
+		if !result.Skipped {
+			continue
+		}
+		if kinds == nil {
+			kinds = make(map[string]int)
+		}
+		kind := result.SkipKind
+		if kind == "" {
+			kind = "other"
+		}
+		kinds[kind]++
+	}
+	return kinds
+}
+
+// skipKindCount is one line of the skip breakdown
+type skipKindCount struct {
+	name  string
+	count int
+}
+
+// sortedSkipKinds orders skip kinds most common first
+func sortedSkipKinds(kinds map[string]int) []skipKindCount {
+	sorted := make([]skipKindCount, 0, len(kinds))
+	for name, count := range kinds {
+		sorted = append(sorted, skipKindCount{name, count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	return sorted
+}
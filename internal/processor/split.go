@@ -0,0 +1,170 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/fsutil"
+)
+
+// splitArchive writes each chapter of the verified archive at tempOutput
+// as its own CBZ next to dest ("<name> - <chapter>.cbz") and, unless the
+// run writes copies, moves the original to backup. Pages outside the
+// chapter folders (a cover at the root) join the chapter after them; other
+// files at the root (ComicInfo.xml) go into every part that has none of
+// its own.
+func (p *Pipeline) splitArchive(cbzPath, dest, tempOutput string, order []pageMapping, chapters []string, info os.FileInfo, result *Result) error {
+	defer os.Remove(tempOutput)
+
+	if p.writesCopy(cbzPath, dest) {
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	contents, err := p.reader.Extract(tempOutput)
+	if err != nil {
+		return fmt.Errorf("cannot read compressed CBZ: %w", err)
+	}
+	source, err := cbz.OpenSource(tempOutput)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	data := make(map[string][]byte, len(contents.Images))
+	for _, img := range contents.Images {
+		data[img.Path] = img.Data
+	}
+
+	isChapter := make(map[string]bool, len(chapters))
+	for _, chapter := range chapters {
+		isChapter[chapter] = true
+	}
+	inPart := func(chapter, name string) string {
+		return strings.TrimPrefix(name, chapter+"/")
+	}
+
+	parts := make(map[string][]cbz.WriteEntry, len(chapters))
+	var pending []pageMapping
+	for _, page := range order {
+		pending = append(pending, page)
+		chapter := cbz.ChapterOf(page.Source)
+		if !isChapter[chapter] {
+			continue
+		}
+		for _, q := range pending {
+			parts[chapter] = append(parts[chapter], source.EntryAs(q.Output, inPart(chapter, q.Output), data[q.Output]))
+		}
+		pending = nil
+	}
+	last := chapters[len(chapters)-1]
+	for _, q := range pending {
+		parts[last] = append(parts[last], source.EntryAs(q.Output, inPart(last, q.Output), data[q.Output]))
+	}
+
+	var shared []cbz.OtherEntry
+	for _, other := range contents.OtherFiles {
+		if chapter := cbz.ChapterOf(other.Path); isChapter[chapter] {
+			parts[chapter] = append(parts[chapter], source.EntryAs(other.Path, inPart(chapter, other.Path), other.Data))
+		} else {
+			shared = append(shared, other)
+		}
+	}
+	for _, chapter := range chapters {
+		for _, other := range shared {
+			if !hasEntry(parts[chapter], other.Path) {
+				parts[chapter] = append(parts[chapter], source.EntryAs(other.Path, other.Path, other.Data))
+			}
+		}
+	}
+
+	// Settle every name before writing anything
+	ext := filepath.Ext(dest)
+	stem := strings.TrimSuffix(dest, ext)
+	paths := make([]string, len(chapters))
+	for i, chapter := range chapters {
+		path, reason := p.resolveCollision(fmt.Sprintf("%s - %s%s", stem, chapter, ext))
+		if reason != "" {
+			return fmt.Errorf("cannot split: %s", reason)
+		}
+		paths[i] = path
+	}
+
+	result.CompressedSize = 0
+	for i, chapter := range chapters {
+		size, err := p.writePart(paths[i], parts[chapter], info, len(result.PageErrors) == 0 && !p.config.Pages.IsSet())
+		if err != nil {
+			for _, written := range paths[:i] {
+				os.Remove(written)
+			}
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(paths[i]), err)
+		}
+		result.CompressedSize += size
+	}
+	result.Parts = paths
+
+	if p.writesCopy(cbzPath, dest) {
+		return nil
+	}
+	var backupPath string
+	err = p.injected(StageBackup, cbzPath)
+	if err == nil {
+		backupPath, err = p.backup.MoveToBackup(cbzPath)
+	}
+	if err != nil {
+		for _, written := range paths {
+			os.Remove(written)
+		}
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	p.events.publish(BackupEvent{Path: cbzPath, Backup: absPath(backupPath), Mode: string(p.backup.Mode())})
+	return nil
+}
+
+// writePart writes one chapter archive with the original's file
+// attributes, returning its size. Only complete parts get the processing
+// marker.
+func (p *Pipeline) writePart(path string, entries []cbz.WriteEntry, info os.FileInfo, complete bool) (int64, error) {
+	archive, err := p.writer.Begin(path)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if err := archive.Add(entry); err != nil {
+			return 0, err
+		}
+	}
+	if !complete {
+		archive.Unmarked()
+	}
+	if err := archive.Commit(); err != nil {
+		return 0, err
+	}
+	if err := cbz.CheckStrict(path); err != nil {
+		os.Remove(path)
+		return 0, fmt.Errorf("part fails the strict zip check: %w", err)
+	}
+	if err := fsutil.CopyMetadata(info, path, p.config.PreserveMTime); err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// hasEntry reports whether entries already hold path
+func hasEntry(entries []cbz.WriteEntry, path string) bool {
+	for _, entry := range entries {
+		if entry.Path == path {
+			return true
+		}
+	}
+	return false
+}
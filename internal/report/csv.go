@@ -0,0 +1,54 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// csvHeader names the columns WriteCSV writes
+var csvHeader = []string{"path", "status", "before_bytes", "after_bytes", "saved_percent", "pages_processed", "duration_seconds", "errors"}
+
+// WriteCSV writes one row per archive, for spreadsheets. Sizes are in
+// bytes; after_bytes and saved_percent are empty for archives that were
+// not compressed, and errors are joined with "; ".
+func (r *Report) WriteCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write CSV report %s: %w", path, err)
+	}
+
+	w := csv.NewWriter(f)
+	w.Write(csvHeader)
+	for _, entry := range r.Files {
+		after, saved := "", ""
+		if entry.Status == StatusProcessed {
+			after = strconv.FormatInt(entry.CompressedSize, 10)
+			if entry.FileSize > 0 {
+				saved = strconv.FormatFloat(float64(entry.FileSize-entry.CompressedSize)/float64(entry.FileSize)*100, 'f', 1, 64)
+			}
+		}
+		w.Write([]string{
+			entry.Path,
+			string(entry.Status),
+			strconv.FormatInt(entry.FileSize, 10),
+			after,
+			saved,
+			strconv.Itoa(entry.PagesProcessed),
+			strconv.FormatFloat(entry.Duration, 'f', 2, 64),
+			strings.Join(entry.Errors, "; "),
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write CSV report %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write CSV report %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Change classifies how an archive's status moved between two reports
+type Change string
+
+const (
+	ChangeAdded           Change = "added"            // Not present in the previous report
+	ChangeRemoved         Change = "removed"          // No longer present in the library
+	ChangeNowOptimized    Change = "now optimized"    // Previously needed processing, now skipped
+	ChangeNeedsProcessing Change = "needs processing" // Previously skipped, now needs processing
+	ChangeNewlyFailing    Change = "newly failing"    // Previously fine, now fails analysis
+	ChangeRecovered       Change = "recovered"        // Previously failing, now analyzes fine
+)
+
+// DiffEntry records a single archive whose status changed
+type DiffEntry struct {
+	Path     string
+	Change   Change
+	Previous *FileEntry // nil for added archives
+	Current  *FileEntry // nil for removed archives
+}
+
+// DiffResult lists all status changes between two reports
+type DiffResult struct {
+	Entries   []DiffEntry
+	Unchanged int
+}
+
+// Diff compares a previous report with the current one and returns the status changes
+func Diff(previous, current *Report) *DiffResult {
+	prevByPath := make(map[string]*FileEntry, len(previous.Files))
+	for i := range previous.Files {
+		prevByPath[previous.Files[i].Path] = &previous.Files[i]
+	}
+
+	result := &DiffResult{}
+	seen := make(map[string]bool, len(current.Files))
+
+	for i := range current.Files {
+		cur := &current.Files[i]
+		seen[cur.Path] = true
+
+		prev, ok := prevByPath[cur.Path]
+		if !ok {
+			result.Entries = append(result.Entries, DiffEntry{Path: cur.Path, Change: ChangeAdded, Current: cur})
+			continue
+		}
+
+		change, changed := classifyChange(prev.Status, cur.Status)
+		if !changed {
+			result.Unchanged++
+			continue
+		}
+		result.Entries = append(result.Entries, DiffEntry{Path: cur.Path, Change: change, Previous: prev, Current: cur})
+	}
+
+	for i := range previous.Files {
+		prev := &previous.Files[i]
+		if !seen[prev.Path] {
+			result.Entries = append(result.Entries, DiffEntry{Path: prev.Path, Change: ChangeRemoved, Previous: prev})
+		}
+	}
+
+	sort.Slice(result.Entries, func(i, j int) bool {
+		if result.Entries[i].Change != result.Entries[j].Change {
+			return result.Entries[i].Change < result.Entries[j].Change
+		}
+		return result.Entries[i].Path < result.Entries[j].Path
+	})
+
+	return result
+}
+
+// classifyChange maps a status transition to a Change; processed archives count as optimized
+func classifyChange(prev, cur Status) (Change, bool) {
+	prevBucket, curBucket := statusBucket(prev), statusBucket(cur)
+	if prevBucket == curBucket {
+		return "", false
+	}
+
+	switch {
+	case curBucket == StatusFailed:
+		return ChangeNewlyFailing, true
+	case prevBucket == StatusFailed:
+		return ChangeRecovered, true
+	case curBucket == StatusSkipped:
+		return ChangeNowOptimized, true
+	default:
+		return ChangeNeedsProcessing, true
+	}
+}
+
+// statusBucket folds statuses that mean the same thing for diffing purposes
+func statusBucket(s Status) Status {
+	if s == StatusProcessed {
+		return StatusSkipped
+	}
+	return s
+}
+
+// Count returns the number of entries with the given change
+func (d *DiffResult) Count(change Change) int {
+	n := 0
+	for _, e := range d.Entries {
+		if e.Change == change {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText prints a human-readable diff, grouped by change type
+func (d *DiffResult) WriteText(w io.Writer) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "=== CHANGES SINCE PREVIOUS REPORT ===")
+
+	if len(d.Entries) == 0 {
+		fmt.Fprintf(w, "No changes (%d archives unchanged)\n", d.Unchanged)
+		return
+	}
+
+	var current Change
+	for _, e := range d.Entries {
+		if e.Change != current {
+			current = e.Change
+			fmt.Fprintf(w, "\n%s (%d):\n", current, d.Count(current))
+		}
+		detail := ""
+		switch {
+		case e.Current != nil && e.Current.Reason != "":
+			detail = " - " + e.Current.Reason
+		case e.Previous != nil && e.Current != nil:
+			detail = fmt.Sprintf(" - was %s", e.Previous.Status)
+		}
+		fmt.Fprintf(w, "  %s%s\n", e.Path, detail)
+	}
+
+	fmt.Fprintf(w, "\nUnchanged: %d\n", d.Unchanged)
+}
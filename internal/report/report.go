@@ -0,0 +1,271 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"compress_comics/internal/processor"
+)
+
+// ReportVersion is bumped whenever the JSON layout changes incompatibly
+const ReportVersion = 1
+
+// Status describes what happened (or would happen) to a single archive
+type Status string
+
+const (
+	StatusProcess   Status = "process"   // Dry-run: archive would be processed
+	StatusProcessed Status = "processed" // Archive was compressed and replaced
+	StatusSkipped   Status = "skipped"   // Archive was skipped by the heuristics
+	StatusFailed    Status = "failed"    // Analysis or processing failed
+)
+
+// FileEntry is the per-archive record in a report
+type FileEntry struct {
+	Path             string             `json:"path"`
+	Output           string             `json:"output,omitempty"` // Compressed archive, when renamed with -rename-to-cbz or written to -output-dir
+	Parts            []string           `json:"parts,omitempty"`  // Per-chapter archives, when split with -split
+	Status           Status             `json:"status"`
+	Reason           string             `json:"reason,omitempty"`
+	SkipKind         string             `json:"skip_kind,omitempty"` // Category of a skip reason (already optimized, marker present, ...)
+	FileSize         int64              `json:"file_size"`
+	CompressedSize   int64              `json:"compressed_size,omitempty"`
+	PageCount        int                `json:"page_count,omitempty"`
+	PagesProcessed   int                `json:"pages_processed,omitempty"` // Pages re-encoded
+	Duration         float64            `json:"duration_seconds,omitempty"`
+	MBPerPage        float64            `json:"mb_per_page,omitempty"`
+	EstimatedSavings int64              `json:"estimated_savings,omitempty"`
+	HighBitDepth     int                `json:"high_bit_depth_pages,omitempty"`
+	CMYKPages        int                `json:"cmyk_pages,omitempty"`
+	LowQualityPages  int                `json:"low_quality_pages,omitempty"` // JPEGs kept because they were below the target quality
+	ModernPages      int                `json:"modern_pages,omitempty"`      // WebP/AVIF pages kept because they were under keep_modern_kb_per_mp
+	Deskewed         map[string]float64 `json:"deskewed_pages,omitempty"`    // Page -> degrees rotated
+	KeptPages        map[string]string  `json:"kept_pages,omitempty"`        // Page -> why its original bytes were kept
+	StripsComposed   int                `json:"strips_composed,omitempty"`
+	ComposedPages    int                `json:"composed_pages,omitempty"`
+	Errors           []string           `json:"errors,omitempty"`
+	PageErrors       []PageErrorEntry   `json:"page_errors,omitempty"`
+	UnsafePaths      []string           `json:"unsafe_paths,omitempty"`    // Absolute or traversing entry paths, as "original -> normalized"
+	SalvagedPages    []string           `json:"salvaged_pages,omitempty"`  // Corrupt pages kept because they still decode (-salvage)
+	DroppedEntries   []string           `json:"dropped_entries,omitempty"` // Corrupt entries left out (-salvage)
+	Warnings         []string           `json:"warnings,omitempty"`        // Problems that left the written archive intact
+	DeviceFit        map[string]bool    `json:"device_fit,omitempty"`      // Device profile -> pages fit its screen without downscaling
+}
+
+// PageErrorEntry locates a failed page inside an archive
+type PageErrorEntry struct {
+	Page  string `json:"page"`
+	Stage string `json:"stage"` // limits, decode, encode or panic
+	Error string `json:"error"`
+}
+
+// Summary holds the batch totals of a report
+type Summary struct {
+	TotalFiles      int            `json:"total_files"`
+	ProcessedFiles  int            `json:"processed_files"`
+	SkippedFiles    int            `json:"skipped_files"`
+	SkipReasons     map[string]int `json:"skip_reasons,omitempty"`  // Skipped archives per skip kind
+	IgnoredFiles    int            `json:"ignored_files,omitempty"` // Archives left out by skip patterns and exclude files
+	FailedFiles     int            `json:"failed_files"`
+	TotalOriginal   int64          `json:"total_original"`
+	TotalCompressed int64          `json:"total_compressed"`
+	NotAttempted    int            `json:"not_attempted,omitempty"`
+	Aborted         bool           `json:"aborted,omitempty"`
+	AbortReason     string         `json:"abort_reason,omitempty"`
+	CodecWins       map[string]int `json:"codec_wins,omitempty"` // Pages won per codec with -codecs
+	LowQualityPages int            `json:"low_quality_pages,omitempty"`
+	ModernPages     int            `json:"modern_pages,omitempty"`
+	NeedsDownscale  map[string]int `json:"needs_downscale,omitempty"` // Archives per device profile with pages larger than its screen
+	Library         *Library       `json:"library,omitempty"`         // Every archive, skipped and failed ones included (not in dry runs)
+}
+
+// Library is the batch measured over all its archives; TotalOriginal and
+// TotalCompressed cover compressed archives only
+type Library struct {
+	Archives       int     `json:"archives"`
+	BeforeBytes    int64   `json:"before_bytes"`
+	AfterBytes     int64   `json:"after_bytes"`
+	UnchangedBytes int64   `json:"unchanged_bytes"` // In skipped and failed archives
+	SavedBytes     int64   `json:"saved_bytes"`
+	SavedPercent   float64 `json:"saved_percent"`
+}
+
+// SeriesEntry holds per-series totals (series = the archives' parent directory)
+type SeriesEntry struct {
+	Series          string  `json:"series"`
+	Files           int     `json:"files"`
+	ProcessedFiles  int     `json:"processed_files"`
+	SkippedFiles    int     `json:"skipped_files"`
+	FailedFiles     int     `json:"failed_files"`
+	TotalOriginal   int64   `json:"total_original,omitempty"` // Sizes are left out of dry runs
+	TotalCompressed int64   `json:"total_compressed,omitempty"`
+	SavingsPercent  float64 `json:"savings_percent,omitempty"`
+}
+
+// Report is the machine-readable result of a run, written with -report
+type Report struct {
+	Version     int           `json:"version"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	DryRun      bool          `json:"dry_run"`
+	Summary     Summary       `json:"summary"`
+	Series      []SeriesEntry `json:"series"`
+	Files       []FileEntry   `json:"files"`
+}
+
+// FromBatch builds a report from a batch result
+func FromBatch(batch *processor.BatchResult, dryRun bool) *Report {
+	r := &Report{
+		Version:     ReportVersion,
+		GeneratedAt: time.Now(),
+		DryRun:      dryRun,
+		Summary: Summary{
+			TotalFiles:      batch.TotalFiles,
+			ProcessedFiles:  batch.ProcessedFiles,
+			SkippedFiles:    batch.SkippedFiles,
+			SkipReasons:     batch.SkipReasons(),
+			IgnoredFiles:    batch.IgnoredFiles,
+			FailedFiles:     batch.FailedFiles,
+			TotalOriginal:   batch.TotalOriginal,
+			TotalCompressed: batch.TotalCompressed,
+			NotAttempted:    batch.NotAttempted,
+			Aborted:         batch.Aborted,
+			AbortReason:     batch.AbortReason,
+			CodecWins:       batch.CodecWins(),
+			LowQualityPages: batch.LowQualityPages(),
+			ModernPages:     batch.ModernPages(),
+			NeedsDownscale:  batch.NeedsDownscale(),
+		},
+		Files: make([]FileEntry, 0, len(batch.Results)),
+	}
+	if library := batch.Library(); !dryRun && library.Archives > 0 {
+		r.Summary.Library = &Library{
+			Archives:       library.Archives,
+			BeforeBytes:    library.Before,
+			AfterBytes:     library.After,
+			UnchangedBytes: library.Unchanged,
+			SavedBytes:     library.Saved(),
+			SavedPercent:   library.Percent(),
+		}
+	}
+
+	for _, result := range batch.Results {
+		r.Files = append(r.Files, NewFileEntry(result))
+	}
+
+	for _, s := range batch.SeriesStats() {
+		entry := SeriesEntry{
+			Series:         filepath.Clean(s.Series),
+			Files:          s.Files,
+			ProcessedFiles: s.ProcessedFiles,
+			SkippedFiles:   s.SkippedFiles,
+			FailedFiles:    s.FailedFiles,
+		}
+		if !dryRun {
+			entry.TotalOriginal = s.TotalOriginal
+			entry.TotalCompressed = s.TotalCompressed
+			entry.SavingsPercent = s.SavingsPercent()
+		}
+		r.Series = append(r.Series, entry)
+	}
+
+	return r
+}
+
+// NewFileEntry converts a single pipeline result into a report entry
+func NewFileEntry(result processor.Result) FileEntry {
+	entry := FileEntry{
+		Path:            filepath.Clean(result.SourcePath),
+		FileSize:        result.OriginalSize,
+		CompressedSize:  result.CompressedSize,
+		PagesProcessed:  result.ImagesProcessed,
+		Duration:        result.Duration.Seconds(),
+		HighBitDepth:    result.HighBitDepth,
+		CMYKPages:       result.CMYKPages,
+		LowQualityPages: result.LowQualityPages,
+		ModernPages:     result.ModernPages,
+		Deskewed:        result.Deskewed,
+		KeptPages:       result.KeptPages,
+		StripsComposed:  result.StripsComposed,
+		ComposedPages:   result.ComposedPages,
+		DeviceFit:       result.DeviceFit,
+		UnsafePaths:     result.UnsafePaths,
+		SalvagedPages:   result.SalvagedPages,
+		DroppedEntries:  result.DroppedEntries,
+		Warnings:        result.Warnings,
+	}
+
+	for _, err := range result.Errors {
+		entry.Errors = append(entry.Errors, err.Error())
+	}
+	for _, pageErr := range result.PageErrors {
+		entry.PageErrors = append(entry.PageErrors, PageErrorEntry{
+			Page:  pageErr.Page,
+			Stage: pageErr.Stage,
+			Error: pageErr.Err.Error(),
+		})
+	}
+
+	if analysis := result.Analysis; analysis != nil {
+		entry.FileSize = analysis.FileSize
+		entry.PageCount = analysis.PageCount
+		entry.MBPerPage = analysis.MBPerPage
+		entry.EstimatedSavings = analysis.EstimatedSavingsBytes
+		entry.HighBitDepth = analysis.HighBitDepthPages
+		entry.CMYKPages = analysis.CMYKPages
+	}
+
+	switch {
+	case result.Skipped:
+		entry.Status = StatusSkipped
+		entry.Reason = result.SkipReason
+		entry.SkipKind = result.SkipKind
+	case result.OutputPath != "":
+		entry.Status = StatusProcessed
+		if result.OutputPath != result.SourcePath {
+			entry.Output = result.OutputPath
+		}
+		entry.Parts = result.Parts
+	case result.Analysis != nil:
+		entry.Status = StatusProcess
+	default:
+		entry.Status = StatusFailed
+		if len(entry.Errors) > 0 {
+			entry.Reason = entry.Errors[0]
+		}
+	}
+
+	return entry
+}
+
+// WriteFile writes the report as indented JSON
+func (r *Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load reads a report previously written with WriteFile
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report %s: %w", path, err)
+	}
+
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	if r.Version != ReportVersion {
+		return nil, fmt.Errorf("unsupported report version %d in %s", r.Version, path)
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,273 @@
+package selftest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Page size of the corpus: a typical 300 dpi digital page, under the
+// default max_dimension so the size and fidelity checks measure encoding
+const (
+	pageWidth  = 1200
+	pageHeight = 1800
+)
+
+// Page is one corpus page with the bounds its processed version must meet
+type Page struct {
+	Name     string
+	Kind     string  // What the page stands for
+	Format   string  // Source format: png or jpeg
+	MaxRatio float64 // Largest output/source size allowed
+	MinSSIM  float64 // Smallest SSIM against the source allowed
+	draw     func() image.Image
+}
+
+// Corpus lists the built-in pages. They are drawn from code, not stored, so
+// the binary stays small; the drawing is deterministic, so every build and
+// platform tests the same pixels.
+var Corpus = []Page{
+	{Name: "color-art.png", Kind: "flat color art with outlines", Format: "png", MaxRatio: 0.8, MinSSIM: 0.99, draw: colorArt},
+	{Name: "screentone.jpg", Kind: "scanned manga with screentone", Format: "jpeg", MaxRatio: 0.9, MinSSIM: 0.99, draw: screentone},
+	{Name: "text.png", Kind: "text page", Format: "png", MaxRatio: 1.0, MinSSIM: 0.985, draw: textPage},
+	{Name: "cover.jpg", Kind: "photographic cover", Format: "jpeg", MaxRatio: 0.6, MinSSIM: 0.97, draw: photoCover},
+}
+
+// Source encodes the page in its source format
+func (p Page) Source() ([]byte, image.Image, error) {
+	img := p.draw()
+	var buf bytes.Buffer
+	var err error
+	if p.Format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 97})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), img, err
+}
+
+// rng is a small xorshift generator; math/rand's sequences aren't promised
+// to stay the same across Go releases
+type rng uint64
+
+func (r *rng) next() uint64 {
+	*r ^= *r << 13
+	*r ^= *r >> 7
+	*r ^= *r << 17
+	return uint64(*r)
+}
+
+// float returns a number in [0, 1)
+func (r *rng) float() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
+
+// intn returns a number in [0, n)
+func (r *rng) intn(n int) int {
+	return int(r.next() % uint64(n))
+}
+
+// panels returns a comic page grid with gutters
+func panels() []image.Rectangle {
+	const margin, gutter = 60, 30
+	rows := []int{margin, 620, 1180, pageHeight - margin}
+	var rects []image.Rectangle
+	for i := 0; i+1 < len(rows); i++ {
+		top, bottom := rows[i], rows[i+1]-gutter
+		if i%2 == 0 {
+			mid := pageWidth/2 + (i-1)*80
+			rects = append(rects,
+				image.Rect(margin, top, mid-gutter/2, bottom),
+				image.Rect(mid+gutter/2, top, pageWidth-margin, bottom))
+		} else {
+			rects = append(rects, image.Rect(margin, top, pageWidth-margin, bottom))
+		}
+	}
+	return rects
+}
+
+// outline draws a border of width w around r
+func outline(img draw.Image, r image.Rectangle, w int, c color.Color) {
+	src := image.NewUniform(c)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+w), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Max.Y-w, r.Max.X, r.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+w, r.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Max.X-w, r.Min.Y, r.Max.X, r.Max.Y), src, image.Point{}, draw.Src)
+}
+
+// disc fills a circle, anti-aliased over one pixel, with shade giving its color at each point
+func disc(img *image.NRGBA, cx, cy, radius float64, shade func(x, y int) color.NRGBA) {
+	for y := int(cy - radius - 1); y <= int(cy+radius+1); y++ {
+		for x := int(cx - radius - 1); x <= int(cx+radius+1); x++ {
+			if !(image.Point{x, y}.In(img.Rect)) {
+				continue
+			}
+			d := math.Hypot(float64(x)-cx, float64(y)-cy)
+			cover := math.Max(0, math.Min(1, radius-d+0.5))
+			if cover == 0 {
+				continue
+			}
+			blend(img, x, y, shade(x, y), cover)
+		}
+	}
+}
+
+// blend mixes c into the pixel at x, y by alpha
+func blend(img *image.NRGBA, x, y int, c color.NRGBA, alpha float64) {
+	i := img.PixOffset(x, y)
+	px := img.Pix[i : i+3 : i+3]
+	px[0] = uint8(float64(px[0])*(1-alpha) + float64(c.R)*alpha)
+	px[1] = uint8(float64(px[1])*(1-alpha) + float64(c.G)*alpha)
+	px[2] = uint8(float64(px[2])*(1-alpha) + float64(c.B)*alpha)
+}
+
+// colorArt is digital line art: flat fills, soft cel shading, black outlines
+func colorArt() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, pageWidth, pageHeight))
+	draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+	r := rng(1)
+	palette := []color.NRGBA{
+		{230, 90, 70, 255}, {70, 130, 200, 255}, {250, 200, 80, 255},
+		{120, 190, 110, 255}, {170, 110, 190, 255}, {240, 170, 140, 255},
+	}
+	for i, panel := range panels() {
+		sky := palette[(i+1)%len(palette)]
+		draw.Draw(img, panel, image.NewUniform(color.NRGBA{sky.R/2 + 120, sky.G/2 + 120, sky.B/2 + 120, 255}), image.Point{}, draw.Src)
+		for range 6 {
+			base := palette[r.intn(len(palette))]
+			cx := float64(panel.Min.X + r.intn(panel.Dx()))
+			cy := float64(panel.Min.Y + r.intn(panel.Dy()))
+			radius := 30 + r.float()*120
+			disc(img, cx, cy, radius+4, func(int, int) color.NRGBA { return color.NRGBA{20, 20, 20, 255} })
+			disc(img, cx, cy, radius, func(x, y int) color.NRGBA {
+				// Light from the top left
+				t := ((float64(x)-cx)+(float64(y)-cy))/(2*radius)*0.5 + 0.5
+				k := 1.1 - 0.35*t
+				return color.NRGBA{clamp(float64(base.R) * k), clamp(float64(base.G) * k), clamp(float64(base.B) * k), 255}
+			})
+		}
+		outline(img, panel, 5, color.Black)
+	}
+	return img
+}
+
+// screentone is a black and white manga page: dot screens of several
+// densities, hatching and speed lines
+func screentone() image.Image {
+	img := image.NewGray(image.Rect(0, 0, pageWidth, pageHeight))
+	draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+	r := rng(2)
+	for i, panel := range panels() {
+		switch i % 3 {
+		case 0: // Dot screen, density rising to the right
+			const pitch = 8
+			for y := panel.Min.Y; y < panel.Max.Y; y++ {
+				for x := panel.Min.X; x < panel.Max.X; x++ {
+					density := 0.15 + 0.5*float64(x-panel.Min.X)/float64(panel.Dx())
+					dx := float64(x%pitch) - pitch/2 + 0.5
+					dy := float64(y%pitch) - pitch/2 + 0.5
+					if math.Hypot(dx, dy) < density*pitch*0.7 {
+						img.SetGray(x, y, color.Gray{25})
+					}
+				}
+			}
+		case 1: // Speed lines towards a vanishing point
+			cx, cy := float64(panel.Min.X+panel.Dx()/2), float64(panel.Min.Y+panel.Dy()/2)
+			for range 140 {
+				angle := r.float() * 2 * math.Pi
+				inner := 80 + r.float()*120
+				for d := inner; d < 900; d += 0.5 {
+					x := int(cx + math.Cos(angle)*d)
+					y := int(cy + math.Sin(angle)*d)
+					if (image.Point{x, y}).In(panel) {
+						img.SetGray(x, y, color.Gray{0})
+					}
+				}
+			}
+		case 2: // Cross hatching
+			for y := panel.Min.Y; y < panel.Max.Y; y++ {
+				for x := panel.Min.X; x < panel.Max.X; x++ {
+					if (x+y)%11 == 0 || ((x-y)%13 == 0 && x > panel.Min.X+panel.Dx()/3) {
+						img.SetGray(x, y, color.Gray{40})
+					}
+				}
+			}
+		}
+		outline(img, panel, 4, color.Black)
+	}
+	// Scanned pages are slightly soft
+	return imaging.Blur(img, 0.6)
+}
+
+// textPage is a prose page (an afterword or chapter notes): small dark
+// text on white with anti-aliased edges
+func textPage() image.Image {
+	// Drawn at half size with a bitmap font, then scaled up, which gives
+	// the glyphs the soft edges of rendered text
+	small := image.NewGray(image.Rect(0, 0, pageWidth/2, pageHeight/2))
+	draw.Draw(small, small.Rect, image.White, image.Point{}, draw.Src)
+	words := strings.Fields(`the pages of a comic are mostly pictures but
+		afterwords translation notes and prose chapters are text which
+		shows ringing around sharp edges long before anything else does
+		so a page of it belongs in any test of how pages are compressed`)
+	drawer := &font.Drawer{Dst: small, Src: image.Black, Face: basicfont.Face7x13}
+	r := rng(3)
+	for line := 0; line < 52; line++ {
+		y := 40 + line*16
+		x := 40
+		for x < pageWidth/2-80 {
+			word := words[r.intn(len(words))]
+			drawer.Dot = fixed.P(x, y)
+			drawer.DrawString(word)
+			x += (len(word) + 1) * 7
+		}
+	}
+	return imaging.Resize(small, pageWidth, pageHeight, imaging.Lanczos)
+}
+
+// photoCover is a painted or photographic cover: smooth gradients, soft
+// shapes at several scales and fine grain
+func photoCover() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, pageWidth, pageHeight))
+	r := rng(4)
+	type wave struct{ fx, fy, phase, amp float64 }
+	var waves [3][]wave
+	for c := range waves {
+		for range 6 {
+			waves[c] = append(waves[c], wave{
+				fx: (r.float() - 0.5) * 0.02, fy: (r.float() - 0.5) * 0.02,
+				phase: r.float() * 2 * math.Pi, amp: 20 + r.float()*25,
+			})
+		}
+	}
+	for y := 0; y < pageHeight; y++ {
+		for x := 0; x < pageWidth; x++ {
+			var px [3]float64
+			for c := range px {
+				v := 60 + 120*float64(y)/pageHeight
+				for _, w := range waves[c] {
+					v += w.amp * math.Sin(w.fx*float64(x)+w.fy*float64(y)+w.phase)
+				}
+				px[c] = v + (r.float()-0.5)*6 // Grain
+			}
+			i := img.PixOffset(x, y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = clamp(px[0]), clamp(px[1]), clamp(px[2]), 255
+		}
+	}
+	return img
+}
+
+// clamp rounds v into a channel value
+func clamp(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
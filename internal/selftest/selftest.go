@@ -0,0 +1,96 @@
+// Package selftest runs the image processor over a built-in corpus of
+// representative pages and checks that the output stays within size and
+// fidelity bounds, to verify a build and its settings on a platform
+package selftest
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"compress_comics/internal/cbz"
+	_ "compress_comics/internal/codec" // Decoders for -codecs output
+	"compress_comics/internal/config"
+	"compress_comics/internal/processor"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+// Outcome is the result of processing one corpus page
+type Outcome struct {
+	Page        Page
+	SourceBytes int64
+	OutputBytes int64
+	SSIM        float64
+	Kept        bool   // The processor left the page as it was
+	Output      []byte // Processed page
+	Source      []byte // Source page
+	OutputName  string
+	Err         error
+}
+
+// Ratio is the output size as a share of the source size
+func (o Outcome) Ratio() float64 {
+	if o.SourceBytes == 0 {
+		return 0
+	}
+	return float64(o.OutputBytes) / float64(o.SourceBytes)
+}
+
+// Passed reports whether the page met its bounds
+func (o Outcome) Passed() bool {
+	return o.Err == nil && o.Ratio() <= o.Page.MaxRatio && o.SSIM >= o.Page.MinSSIM
+}
+
+// Run processes every corpus page with the page settings of cfg
+func Run(cfg config.Config) []Outcome {
+	proc := processor.NewImageProcessor(cfg.MaxDimension, cfg.JPEGQuality, processor.ImageOptionsFromConfig(cfg))
+	outcomes := make([]Outcome, len(Corpus))
+	for i, page := range Corpus {
+		outcomes[i] = runPage(proc, page)
+	}
+	return outcomes
+}
+
+// runPage processes one page and measures the result against the source
+func runPage(proc *processor.ImageProcessor, page Page) Outcome {
+	outcome := Outcome{Page: page}
+	data, _, err := page.Source()
+	if err != nil {
+		outcome.Err = fmt.Errorf("encoding source: %w", err)
+		return outcome
+	}
+	outcome.Source = data
+	outcome.SourceBytes = int64(len(data))
+
+	result, err := proc.Process(cbz.ImageEntry{Path: page.Name, Data: data, OriginalSize: int64(len(data))})
+	if err != nil {
+		outcome.Err = err
+		return outcome
+	}
+	outcome.Output = result.Data
+	outcome.OutputName = result.NewPath
+	outcome.OutputBytes = result.NewSize
+	outcome.Kept = bytes.Equal(result.Data, data)
+
+	// Compare with the source as the user's file would decode, at the output size
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		outcome.Err = fmt.Errorf("decoding source: %w", err)
+		return outcome
+	}
+	output, _, err := image.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		outcome.Err = fmt.Errorf("decoding output: %w", err)
+		return outcome
+	}
+	if size := output.Bounds().Size(); size != source.Bounds().Size() {
+		source = imaging.Resize(source, size.X, size.Y, imaging.Lanczos)
+	}
+	outcome.SSIM = SSIM(source, output)
+	return outcome
+}
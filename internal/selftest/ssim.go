@@ -0,0 +1,79 @@
+package selftest
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// SSIM window size and step; overlapping 8x8 windows as in the usual
+// implementations
+const (
+	ssimWindow = 8
+	ssimStep   = 4
+)
+
+// Stabilizing constants for 8-bit luma
+var (
+	ssimC1 = math.Pow(0.01*255, 2)
+	ssimC2 = math.Pow(0.03*255, 2)
+)
+
+// SSIM returns the mean structural similarity of the luma of a and b,
+// which must be the same size: 1 for identical images, lower as structure
+// (edges, texture) is lost. Unlike PSNR it punishes blurred screentone and
+// ringing around text more than a small uniform shift.
+func SSIM(a, b image.Image) float64 {
+	la, lb := luma(a), luma(b)
+	width, height := a.Bounds().Dx(), a.Bounds().Dy()
+
+	var sum float64
+	var windows int
+	for y := 0; y+ssimWindow <= height; y += ssimStep {
+		for x := 0; x+ssimWindow <= width; x += ssimStep {
+			var meanA, meanB float64
+			for j := y; j < y+ssimWindow; j++ {
+				for i := x; i < x+ssimWindow; i++ {
+					meanA += la[j*width+i]
+					meanB += lb[j*width+i]
+				}
+			}
+			const n = ssimWindow * ssimWindow
+			meanA /= n
+			meanB /= n
+
+			var varA, varB, cov float64
+			for j := y; j < y+ssimWindow; j++ {
+				for i := x; i < x+ssimWindow; i++ {
+					da, db := la[j*width+i]-meanA, lb[j*width+i]-meanB
+					varA += da * da
+					varB += db * db
+					cov += da * db
+				}
+			}
+			varA /= n - 1
+			varB /= n - 1
+			cov /= n - 1
+
+			sum += (2*meanA*meanB + ssimC1) * (2*cov + ssimC2) /
+				((meanA*meanA + meanB*meanB + ssimC1) * (varA + varB + ssimC2))
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1
+	}
+	return sum / float64(windows)
+}
+
+// luma returns the 8-bit luma of every pixel of img, row by row
+func luma(img image.Image) []float64 {
+	b := img.Bounds()
+	out := make([]float64, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out = append(out, float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y))
+		}
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+// Package trash moves files to the operating system's trash / recycle bin
+// using each platform's on-disk format, so they can be restored with the
+// familiar desktop tools (or programmatically with Restore).
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupported is returned on platforms without a known trash implementation
+var ErrUnsupported = errors.New("trash is not supported on this platform")
+
+// Trash moves path into the trash and returns its location inside the trash
+func Trash(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(absPath); err != nil {
+		return "", err
+	}
+	return trashFile(absPath)
+}
+
+// Restore moves a trashed file back to originalPath and removes its trash metadata
+func Restore(trashedPath, originalPath string) error {
+	if _, err := os.Stat(trashedPath); err != nil {
+		return fmt.Errorf("trashed file not found: %w", err)
+	}
+	return restoreFile(trashedPath, originalPath)
+}
+
+// uniqueName returns name, or name with a numeric suffix, such that taken(candidate) is false
+func uniqueName(name string, taken func(string) bool) string {
+	if !taken(name) {
+		return name
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// exists reports whether path exists
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,33 @@
+//go:build darwin
+
+package trash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"compress_comics/internal/fsutil"
+)
+
+func trashFile(absPath string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	trashDir := filepath.Join(home, ".Trash")
+
+	name := uniqueName(filepath.Base(absPath), func(n string) bool {
+		return exists(filepath.Join(trashDir, n))
+	})
+	trashedPath := filepath.Join(trashDir, name)
+
+	if err := fsutil.Move(absPath, trashedPath); err != nil {
+		return "", fmt.Errorf("failed to move %s to trash: %w", absPath, err)
+	}
+	return trashedPath, nil
+}
+
+func restoreFile(trashedPath, originalPath string) error {
+	return fsutil.Move(trashedPath, originalPath)
+}
@@ -0,0 +1,161 @@
+//go:build unix && !darwin
+
+package trash
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"compress_comics/internal/fsutil"
+)
+
+// homeTrash returns $XDG_DATA_HOME/Trash (freedesktop.org Trash specification)
+func homeTrash() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// trashDirFor picks the trash for absPath the way the freedesktop.org spec
+// does: the home trash when the file is on its device, otherwise a trash at
+// the top of the file's mount, $topdir/.Trash/$uid when the administrator
+// provided a sticky $topdir/.Trash, or $topdir/.Trash-$uid. Files are only
+// ever renamed into a trash, never copied across devices. topdir is "" for
+// the home trash.
+func trashDirFor(absPath string) (trashDir, topdir string, err error) {
+	device, err := fsutil.DeviceOf(absPath)
+	if err != nil {
+		return "", "", err
+	}
+	home, err := homeTrash()
+	if err != nil {
+		return "", "", err
+	}
+	if homeDevice, err := fsutil.DeviceOf(existingAncestor(home)); err == nil && homeDevice == device {
+		return home, "", nil
+	}
+
+	topdir = mountTop(filepath.Dir(absPath), device)
+	uid := strconv.Itoa(os.Getuid())
+	shared := filepath.Join(topdir, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		dir := filepath.Join(shared, uid)
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			return dir, topdir, nil
+		}
+	}
+	dir := filepath.Join(topdir, ".Trash-"+uid)
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return "", "", fmt.Errorf("no trash on the device of %s: %w", absPath, err)
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return "", "", fmt.Errorf("no trash on the device of %s: %s is not a directory", absPath, dir)
+	}
+	return dir, topdir, nil
+}
+
+// mountTop returns the highest directory above dir (or dir itself) still
+// on device: the mount point of dir's filesystem
+func mountTop(dir, device string) string {
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		if parentDevice, err := fsutil.DeviceOf(parent); err != nil || parentDevice != device {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// existingAncestor returns path, or its nearest ancestor that exists
+func existingAncestor(path string) string {
+	for !exists(path) && filepath.Dir(path) != path {
+		path = filepath.Dir(path)
+	}
+	return path
+}
+
+func trashFile(absPath string) (string, error) {
+	trashDir, topdir, err := trashDirFor(absPath)
+	if err != nil {
+		return "", err
+	}
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("failed to create trash dir: %w", err)
+		}
+	}
+
+	// Reserve the name by creating the .trashinfo exclusively, as the spec requires
+	var name string
+	var info *os.File
+	for {
+		name = uniqueName(filepath.Base(absPath), func(n string) bool {
+			return exists(filepath.Join(filesDir, n)) || exists(filepath.Join(infoDir, n+".trashinfo"))
+		})
+		info, err = os.OpenFile(filepath.Join(infoDir, name+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("failed to create trash info: %w", err)
+		}
+	}
+
+	// Trashes at the top of a mount record paths relative to it, so they
+	// stay valid wherever the device is mounted next
+	recorded := absPath
+	if topdir != "" {
+		if rel, err := filepath.Rel(topdir, absPath); err == nil {
+			recorded = rel
+		}
+	}
+	escaped := (&url.URL{Path: recorded}).EscapedPath()
+	_, err = fmt.Fprintf(info, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", escaped, time.Now().Format("2006-01-02T15:04:05"))
+	if closeErr := info.Close(); err == nil {
+		err = closeErr
+	}
+	infoPath := info.Name()
+	if err != nil {
+		os.Remove(infoPath)
+		return "", fmt.Errorf("failed to write trash info: %w", err)
+	}
+
+	trashedPath := filepath.Join(filesDir, name)
+	if err := fsutil.Rename(absPath, trashedPath); err != nil {
+		os.Remove(infoPath)
+		return "", fmt.Errorf("failed to move %s to trash: %w", absPath, err)
+	}
+
+	return trashedPath, nil
+}
+
+func restoreFile(trashedPath, originalPath string) error {
+	if err := fsutil.Move(trashedPath, originalPath); err != nil {
+		return err
+	}
+
+	// files/<name> pairs with info/<name>.trashinfo
+	filesDir := filepath.Dir(trashedPath)
+	if filepath.Base(filesDir) == "files" {
+		infoPath := filepath.Join(filepath.Dir(filesDir), "info", filepath.Base(trashedPath)+".trashinfo")
+		if err := os.Remove(infoPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("restored file but failed to remove trash info: %w", err)
+		}
+	}
+	return nil
+}
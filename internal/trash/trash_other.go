@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package trash
+
+func trashFile(absPath string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func restoreFile(trashedPath, originalPath string) error {
+	return ErrUnsupported
+}
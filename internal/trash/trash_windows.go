@@ -0,0 +1,126 @@
+//go:build windows
+
+package trash
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"compress_comics/internal/fsutil"
+
+	"golang.org/x/sys/windows"
+)
+
+// recycleBinDir returns <volume>\$Recycle.Bin\<user SID> for the volume holding absPath
+func recycleBinDir(absPath string) (string, error) {
+	token := windows.GetCurrentProcessToken()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up current user: %w", err)
+	}
+
+	volume := filepath.VolumeName(absPath)
+	dir := filepath.Join(volume+`\`, "$Recycle.Bin", user.User.Sid.String())
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		// Explorer creates this folder with the right ACLs; we don't fabricate it
+		return "", fmt.Errorf("recycle bin not initialized on %s", volume)
+	}
+	return dir, nil
+}
+
+// recycleID returns a random 6-character identifier like Explorer's $R/$I names
+func recycleID() (string, error) {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i := range buf {
+		buf[i] = alphabet[int(buf[i])%len(alphabet)]
+	}
+	return string(buf), nil
+}
+
+// recycleInfo encodes a version 2 $I record: header, size, deletion time and original path
+func recycleInfo(absPath string, size int64, deleted time.Time) []byte {
+	path := utf16.Encode([]rune(absPath + "\x00"))
+
+	buf := make([]byte, 28+2*len(path))
+	binary.LittleEndian.PutUint64(buf[0:], 2)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(size))
+	ft := windows.NsecToFiletime(deleted.UnixNano())
+	binary.LittleEndian.PutUint32(buf[16:], ft.LowDateTime)
+	binary.LittleEndian.PutUint32(buf[20:], ft.HighDateTime)
+	binary.LittleEndian.PutUint32(buf[24:], uint32(len(path)))
+	for i, c := range path {
+		binary.LittleEndian.PutUint16(buf[28+2*i:], c)
+	}
+	return buf
+}
+
+func trashFile(absPath string) (string, error) {
+	binDir, err := recycleBinDir(absPath)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(absPath)
+	for {
+		id, err := recycleID()
+		if err != nil {
+			return "", err
+		}
+		infoPath := filepath.Join(binDir, "$I"+id+ext)
+		trashedPath := filepath.Join(binDir, "$R"+id+ext)
+
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create recycle bin record: %w", err)
+		}
+		_, err = f.Write(recycleInfo(absPath, info.Size(), time.Now()))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(infoPath)
+			return "", fmt.Errorf("failed to write recycle bin record: %w", err)
+		}
+
+		// Same volume by construction, so a plain rename suffices
+		if err := fsutil.Rename(absPath, trashedPath); err != nil {
+			os.Remove(infoPath)
+			return "", fmt.Errorf("failed to move %s to recycle bin: %w", absPath, err)
+		}
+		return trashedPath, nil
+	}
+}
+
+func restoreFile(trashedPath, originalPath string) error {
+	if err := fsutil.Rename(trashedPath, originalPath); err != nil {
+		return err
+	}
+
+	// $R<id>.ext pairs with $I<id>.ext
+	name := filepath.Base(trashedPath)
+	if strings.HasPrefix(name, "$R") {
+		infoPath := filepath.Join(filepath.Dir(trashedPath), "$I"+strings.TrimPrefix(name, "$R"))
+		if err := os.Remove(infoPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("restored file but failed to remove recycle bin record: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"compress_comics/internal/config"
+	"compress_comics/internal/lint"
+	"compress_comics/internal/processor"
+)
+
+// runLint implements the lint subcommand
+func runLint(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	var (
+		inputPath  string
+		minPages   int
+		ignoreSpec string
+		recursive  bool
+		workers    int
+	)
+	fs.StringVar(&inputPath, "input", "", "Path to CBZ file or directory (required)")
+	fs.StringVar(&inputPath, "i", "", "Path to CBZ file or directory (shorthand)")
+	fs.IntVar(&minPages, "min-pages", 4, "Flag archives with fewer pages than this (0 = only empty archives)")
+	fs.StringVar(&ignoreSpec, "ignore", "", "Comma-separated issue kinds not to report: "+strings.Join(lint.Kinds, ", "))
+	fs.BoolVar(&recursive, "recursive", true, "Scan directories recursively")
+	fs.IntVar(&workers, "workers", runtime.NumCPU(), "Number of archives checked in parallel")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s lint -input <path> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Checks archives for structural problems without changing anything: corrupt\n")
+		fmt.Fprintf(os.Stderr, "entries, gaps in page numbering, mixed page formats, missing ComicInfo.xml and\n")
+		fmt.Fprintf(os.Stderr, "suspicious page counts. Exits with status 1 if any issue is found.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if inputPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input is required")
+		fs.Usage()
+		return 1
+	}
+	if workers < 1 {
+		fmt.Fprintln(os.Stderr, "Error: workers must be at least 1")
+		return 1
+	}
+	opts := lint.Options{MinPages: minPages, Ignore: make(map[string]bool)}
+	for _, kind := range strings.Split(ignoreSpec, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		if !slices.Contains(lint.Kinds, kind) {
+			fmt.Fprintf(os.Stderr, "Error: unknown issue kind %q (must be one of %s)\n", kind, strings.Join(lint.Kinds, ", "))
+			return 1
+		}
+		opts.Ignore[kind] = true
+	}
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot access %s: %v\n", inputPath, err)
+		return 1
+	}
+
+	files := []string{inputPath}
+	if info.IsDir() {
+		cfg := *baseCfg
+		cfg.Recursive = recursive
+		files, err = processor.FindArchives(cfg, inputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("No CBZ files found in %s\n", inputPath)
+		return 0
+	}
+
+	results := checkArchives(files, opts, workers)
+
+	flagged := 0
+	byKind := make(map[string]int)
+	for i, issues := range results {
+		if len(issues) == 0 {
+			continue
+		}
+		flagged++
+		fmt.Println(files[i])
+		for _, issue := range issues {
+			fmt.Printf("  [%s] %s\n", issue.Kind, issue.Message)
+			byKind[issue.Kind]++
+		}
+	}
+
+	if flagged > 0 {
+		fmt.Println()
+	}
+	fmt.Println("=== Lint Summary ===")
+	fmt.Printf("Archives checked: %d\n", len(files))
+	fmt.Printf("With issues:      %d\n", flagged)
+	for _, kind := range lint.Kinds {
+		if byKind[kind] > 0 {
+			fmt.Printf("  %-15s %d\n", kind+":", byKind[kind])
+		}
+	}
+
+	if flagged > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkArchives lints files in parallel, returning issues in file order
+func checkArchives(files []string, opts lint.Options, workers int) [][]lint.Issue {
+	results := make([][]lint.Issue, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = lint.Check(files[i], opts)
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
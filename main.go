@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
+	"os/signal"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"compress_comics/internal/analyzer"
+	"compress_comics/internal/backup"
+	"compress_comics/internal/cbz"
 	"compress_comics/internal/config"
+	"compress_comics/internal/history"
 	"compress_comics/internal/processor"
+	"compress_comics/internal/report"
+	"compress_comics/internal/tracing"
 )
 
 //go:embed cbz-compress.yaml
@@ -24,6 +37,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Dispatch subcommands before parsing the compression flags
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+		if os.Args[1] == "--oneshot" || os.Args[1] == "-oneshot" {
+			os.Exit(runOneshot(os.Args[2:]))
+		}
+	}
+
 	// Load runtime config file (overrides embedded defaults)
 	baseCfg, err := config.LoadWithDefaults()
 	if err != nil {
@@ -31,27 +54,87 @@ func main() {
 		os.Exit(1)
 	}
 
+	// A profile (e.g. from `calibrate`) overrides the config file, and flags override both
+	profilePath := config.ProfileArg(os.Args[1:])
+	if profilePath != "" {
+		if err := config.LoadProfile(baseCfg, profilePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading profile: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Define flags using loaded config as defaults
 	var (
-		inputPath   string
+		inputs      inputList
 		backupDir   string
+		backupMode  string
 		maxDim      int
 		quality     int
 		threshold   float64
 		recursive   bool
 		force       bool
+		reprocess   bool
 		dryRun      bool
 		verbose     bool
 		workers     int
+		failFast    bool
+		strict      bool
+		salvage     bool
+		renameToCBZ string
+		progress    string
+		outputDir   string
+		output      string
+		outputName  string
+		onCollision string
+		maxFailures int
+		preflight   bool
+		recentDays  int
+		reportPath  string
+		csvPath     string
+		diffPath    string
+		interactive bool
+		pagesSpec   string
+		sampleDir   string
+		tempDir     string
+		localCopy   bool
+		copyWorkers int
+		tempBudget  int
+		maxInFlight int
+		mountLimits = mountLimitList{}
+		durable     bool
+		keepMTime   bool
+		sampleCount int
+		maxMP       float64
+		maxDecodeMB int
+		codecsSpec  string
+		dither      bool
+		autoLevels  bool
+		deskew      bool
+		compose     float64
+		webtoon     bool
+		flatten     bool
+		split       bool
+		joinSplit   bool
+		excludeFile string
+		zipLevel    int
+		zipMethod   string
+		gamma       float64
+		maxPages    int
+		otelURL     string
+		injectSpec  string
 		showVersion bool
 	)
 
-	flag.StringVar(&inputPath, "input", "", "Path to CBZ file or directory (required)")
-	flag.StringVar(&inputPath, "i", "", "Path to CBZ file or directory (shorthand)")
+	flag.Var(&inputs, "input", "Path to CBZ file or directory (required; repeat to process several roots as one batch)")
+	flag.Var(&inputs, "i", "Path to CBZ file or directory (shorthand)")
+
+	flag.String(config.ProfileFlag, "", "Settings profile (YAML, e.g. written by calibrate) applied over the config file")
 
 	flag.StringVar(&backupDir, "backup", baseCfg.BackupDir, "Directory to store original files")
 	flag.StringVar(&backupDir, "b", baseCfg.BackupDir, "Backup directory (shorthand)")
 
+	flag.StringVar(&backupMode, "backup-mode", baseCfg.BackupMode, "Where originals go: dir (backup directory), trash (OS trash / recycle bin) or store (deduplicated by content hash)")
+
 	flag.IntVar(&maxDim, "max-dim", baseCfg.MaxDimension, "Maximum dimension in pixels (long edge)")
 	flag.IntVar(&quality, "quality", baseCfg.JPEGQuality, "JPEG quality (1-100)")
 	flag.IntVar(&quality, "q", baseCfg.JPEGQuality, "JPEG quality (shorthand)")
@@ -59,12 +142,19 @@ func main() {
 	flag.Float64Var(&threshold, "threshold", baseCfg.ThresholdMBPage, "MB per page threshold for skip heuristic")
 	flag.Float64Var(&threshold, "t", baseCfg.ThresholdMBPage, "MB per page threshold (shorthand)")
 
+	flag.Float64Var(&maxMP, "max-megapixels", baseCfg.MaxMegapixels, "Leave pages larger than this unchanged instead of decoding them (0 = unlimited)")
+	flag.IntVar(&maxDecodeMB, "max-decode-mb", baseCfg.MaxDecodeMB, "Leave pages estimated to need more memory than this to decode unchanged (0 = unlimited)")
+
+	flag.StringVar(&excludeFile, "exclude-file", baseCfg.ExcludeFile, "File of gitignore-style patterns for archives never to process (in addition to .cbzignore files)")
+
 	flag.BoolVar(&recursive, "recursive", true, "Process directories recursively")
 	flag.BoolVar(&recursive, "r", true, "Recursive (shorthand)")
 
 	flag.BoolVar(&force, "force", false, "Process even if file appears optimized")
 	flag.BoolVar(&force, "f", false, "Force processing (shorthand)")
 
+	flag.BoolVar(&reprocess, "reprocess", false, "Reprocess archives a previous run already compressed (ignores the content-hash marker)")
+
 	flag.BoolVar(&dryRun, "dry-run", false, "Preview changes without modifying files")
 	flag.BoolVar(&verbose, "verbose", false, "Show detailed progress")
 	flag.BoolVar(&verbose, "v", false, "Verbose (shorthand)")
@@ -72,23 +162,86 @@ func main() {
 	flag.IntVar(&workers, "workers", runtime.NumCPU(), "Number of parallel workers for directory processing")
 	flag.IntVar(&workers, "w", runtime.NumCPU(), "Parallel workers (shorthand)")
 
+	flag.StringVar(&pagesSpec, "pages", "", "Keep only this page range, e.g. 1-50, in a copy (needs -output or -output-dir)")
+	flag.IntVar(&maxPages, "max-pages", 0, "Keep only the first N pages in a copy (shorthand for -pages 1-N)")
+
+	flag.IntVar(&zipLevel, "zip-level", baseCfg.ZipLevel, "Deflate level of written archives, 0 (store, fastest) to 9 (smallest); pages are already compressed, so low levels cost little")
+	flag.StringVar(&zipMethod, "zip-method", baseCfg.ZipMethod, "How entries of written archives are stored: deflate, or store (uncompressed; some e-readers page through these much faster)")
+
+	flag.StringVar(&tempDir, "temp-dir", "", "Build temporary archives in this directory (e.g. a fast SSD) instead of next to the source")
+	flag.BoolVar(&localCopy, "local-copy", false, "For network shares: copy each archive to -temp-dir (default: system temp), process it there and copy the result back")
+	flag.IntVar(&copyWorkers, "copy-workers", 2, "Concurrent copies to and from the share with -local-copy, independent of -workers")
+	flag.IntVar(&maxInFlight, "max-inflight-mb", 0, "Most MB of archive contents held in memory at once; large archives wait for room while small ones run in parallel (0 = limited by -workers only)")
+	flag.IntVar(&tempBudget, "temp-budget-mb", 0, "Most temp space in MB the archives being processed at once may take; larger archives wait for room (0 = unlimited)")
+	maps.Copy(mountLimits, baseCfg.MountLimits)
+	flag.Var(mountLimits, "mount-limit", "Process at most N archives at once on the filesystem holding PATH, as PATH=N (repeatable; adds to mount_limits)")
+
+	flag.BoolVar(&durable, "durable", false, "Fsync archives and directories before each replacement (slower, power-loss safe)")
+
+	flag.BoolVar(&keepMTime, "preserve-mtime", true, "Keep the original modification time on replaced archives")
+
+	flag.StringVar(&sampleDir, "export-samples", "", "Save before/after page pairs of each processed archive to this directory")
+	flag.IntVar(&sampleCount, "samples", 3, "Number of before/after pairs per archive for -export-samples")
+
+	flag.StringVar(&codecsSpec, "codecs", "", "Encode each page with these codecs in parallel and keep the smallest: jpeg, webp, original (e.g. jpeg,webp,original)")
+
+	flag.BoolVar(&dither, "dither", false, "Dither 16-bit pages down to 8 bits instead of rounding (avoids banding in gradients)")
+
+	flag.BoolVar(&autoLevels, "auto-levels", false, "Stretch page contrast (histogram black/white points) for faded scans")
+	flag.BoolVar(&deskew, "deskew", false, "Detect and correct small rotations (up to 5°) of scanned pages before resizing")
+	flag.Float64Var(&compose, "compose", baseCfg.ComposeAspect, "Stack consecutive thin strip slices into pages of this height/width ratio, e.g. 1.5 (0 = off)")
+	flag.BoolVar(&flatten, "flatten", false, "Move pages out of folders inside archives (chapter subfolders) into the root, renumbered 001, 002, ... in page order")
+	flag.BoolVar(&split, "split", false, "Split merged multi-volume archives (two or more chapter folders of 4+ pages) into one CBZ per chapter")
+	flag.BoolVar(&joinSplit, "join-split", false, "Rewrite split zip sets (name.z01, name.z02, ... name.zip) as one archive even when their pages need no compression")
+	flag.BoolVar(&webtoon, "webtoon", false, "Webtoon mode: clamp only page width to -max-dim, so tall vertical strips keep their height")
+	flag.Float64Var(&gamma, "gamma", baseCfg.Gamma, "Midtone gamma applied to every page (1 = unchanged, >1 brightens)")
+
+	flag.StringVar(&progress, "progress", processor.ProgressAuto, "Progress output: auto (bar on a terminal, plain when piped or run from cron/CI), bar, plain or none (summary only)")
+	flag.BoolVar(&interactive, "interactive", false, "Analyze first, then choose which files to process")
+
+	flag.StringVar(&reportPath, "report", "", "Write a JSON report of the run to this file")
+	flag.StringVar(&csvPath, "report-csv", "", "Write a CSV report of the run (one row per archive) to this file")
+	flag.StringVar(&diffPath, "diff", "", "Compare the run against a previous JSON report and list status changes")
+
+	flag.BoolVar(&failFast, "fail-fast", false, "Stop the batch on the first failed file")
+	flag.BoolVar(&strict, "strict", false, "Fail an archive (leaving it untouched) when any of its pages fails, instead of keeping failed pages unchanged")
+	flag.BoolVar(&salvage, "salvage", false, "For archives with entries that fail their CRC or don't decompress: keep the corrupt pages that still decode and drop the rest, instead of failing the archive (reads the whole archive into memory)")
+	flag.StringVar(&renameToCBZ, "rename-to-cbz", processor.RenameKeep, "For archives without a .cbz extension (see archive_extensions): keep the name, replace with a .cbz, or write a .cbz alongside")
+	flag.StringVar(&output, "output", "", "Write the compressed archive to this file instead of replacing the input ('-' = stdout); a single archive only. With -input - the archive is read from stdin")
+	flag.StringVar(&outputDir, "output-dir", "", "Write compressed archives into this directory instead of replacing the originals (which stay untouched)")
+	flag.StringVar(&outputName, "output-name", processor.DefaultOutputName, "Path of each archive under -output-dir: {dir} (relative to the input), {series} (parent directory), {name}, {ext}")
+	flag.StringVar(&onCollision, "on-collision", processor.CollisionSkip, "When an archive already exists under -output-dir: skip, overwrite, or version (\"name (2).cbz\")")
+	flag.IntVar(&maxFailures, "max-failures", 0, "Stop the batch after this many failed files (0 = unlimited)")
+	flag.BoolVar(&preflight, "preflight", true, "Before a batch starts, check that every output directory, the backup directory and the temp directory are writable")
+	flag.IntVar(&recentDays, "recent-first", 0, "Process archives modified in the last N days first, newest first, then the rest (0 = scan order)")
+
+	flag.StringVar(&otelURL, "otel-endpoint", "", "Export OpenTelemetry traces to this OTLP/HTTP endpoint (default: OTEL_EXPORTER_OTLP_ENDPOINT, off if unset)")
+
 	flag.BoolVar(&showVersion, "version", false, "Show version information")
 
+	// Developer flag, left out of -help
+	flag.StringVar(&injectSpec, "inject-failure", "", "Fail a stage on purpose to test backup, restore and rollback: stage=NAME[,file=GLOB]")
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "CBZ Compressor v%s\n\n", version)
 		fmt.Fprintf(os.Stderr, "Compresses CBZ comic book files for tablet reading.\n")
 		fmt.Fprintf(os.Stderr, "Optimizes images to max %d pixels, JPEG quality %d.\n\n", baseCfg.MaxDimension, baseCfg.JPEGQuality)
 		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "  %s -input <path> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -input <path> [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s <command> [options]\n\n", os.Args[0])
+		printCommands(os.Stderr)
+		fmt.Fprintln(os.Stderr)
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  %s -input comic.cbz\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -input ./comics -recursive\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -input ./comics -dry-run -verbose\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -input ./comics -q 85 -max-dim 1600\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -input ./comics -force\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s -input ./comics -w 4\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -input ./comics -interactive\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -input ./comics -w 4\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -input ./comics -dry-run -report now.json -diff last.json\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
+		printFlags(flag.CommandLine)
 		fmt.Fprintf(os.Stderr, "\nConfig file:\n")
 		fmt.Fprintf(os.Stderr, "  Place a %s file in the current directory to set defaults.\n", config.DefaultConfigFileName)
 	}
@@ -100,7 +253,7 @@ func main() {
 		os.Exit(0)
 	}
 
-	if inputPath == "" {
+	if len(inputs) == 0 {
 		fmt.Fprintln(os.Stderr, "Error: -input is required")
 		flag.Usage()
 		os.Exit(1)
@@ -112,36 +265,311 @@ func main() {
 		os.Exit(1)
 	}
 
+	if interactive && dryRun {
+		fmt.Fprintln(os.Stderr, "Error: -interactive cannot be combined with -dry-run")
+		os.Exit(1)
+	}
+
+	// Validate backup mode
+	if _, err := backup.ParseMode(backupMode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var inject *processor.FailureInjection
+	if injectSpec != "" {
+		if inject, err = processor.ParseFailureInjection(injectSpec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if gamma <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -gamma must be greater than 0")
+		os.Exit(1)
+	}
+
+	if compose < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -compose cannot be negative")
+		os.Exit(1)
+	}
+
+	// Validate codec race
+	codecs, err := processor.ParseCodecs(codecsSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	renamePolicy, err := processor.ParseRenamePolicy(renameToCBZ)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -rename-to-cbz: %v\n", err)
+		os.Exit(1)
+	}
+	progressMode, err := processor.ParseProgressMode(progress)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -progress: %v\n", err)
+		os.Exit(1)
+	}
+	collisionPolicy, err := processor.ParseCollisionPolicy(onCollision)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -on-collision: %v\n", err)
+		os.Exit(1)
+	}
+	if err := processor.ValidateOutputName(outputName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -output-name: %v\n", err)
+		os.Exit(1)
+	}
+
+	// A single archive written elsewhere (-output), possibly read from stdin
+	var single *singleOutput
+	if slices.Contains(inputs, "-") && output == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input - needs -output (use -output - to write to stdout)")
+		os.Exit(1)
+	}
+	if output != "" {
+		switch {
+		case len(inputs) > 1:
+			fmt.Fprintln(os.Stderr, "Error: -output takes a single archive")
+			os.Exit(1)
+		case outputDir != "" || interactive || split:
+			fmt.Fprintln(os.Stderr, "Error: -output cannot be combined with -output-dir, -interactive or -split")
+			os.Exit(1)
+		}
+		if single, err = newSingleOutput(inputs[0], output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inputs[0] = single.input
+		outputDir = single.outputDir()
+		outputName = "{name}{ext}"
+		collisionPolicy = processor.CollisionOverwrite
+	}
+
+	// Validate format policy from the config file
+	if err := baseCfg.FormatPolicy.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := baseCfg.ProcessExtensions.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := baseCfg.QualityCurve.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := baseCfg.ComicInfoRules.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := baseCfg.SeriesRules.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := baseCfg.DeviceProfiles.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if baseCfg.KeepModernKBPerMP < 0 {
+		fmt.Fprintln(os.Stderr, "Error: keep_modern_kb_per_mp cannot be negative")
+		os.Exit(1)
+	}
+	if err := config.MountLimits(mountLimits).Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Validate page selection
+	pages, err := cbz.ParsePageRange(pagesSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if maxPages < 0 {
+		fmt.Fprintln(os.Stderr, "Error: max-pages must be positive")
+		os.Exit(1)
+	}
+	if maxPages > 0 {
+		if pages.IsSet() {
+			fmt.Fprintln(os.Stderr, "Error: -pages and -max-pages cannot be combined")
+			os.Exit(1)
+		}
+		pages = cbz.PageRange{First: 1, Last: maxPages}
+	}
+	if pages.IsSet() && outputDir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -pages and -max-pages write a subset of each archive; use -output or -output-dir so the originals stay whole")
+		os.Exit(1)
+	}
+
+	if err := cbz.ValidateZipLevel(zipLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cbz.ValidateZipMethod(zipMethod); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if copyWorkers < 1 {
+		fmt.Fprintln(os.Stderr, "Error: copy-workers must be at least 1")
+		os.Exit(1)
+	}
+
+	if tempBudget < 0 {
+		fmt.Fprintln(os.Stderr, "Error: temp-budget-mb must be 0 (unlimited) or more")
+		os.Exit(1)
+	}
+	if maxInFlight < 0 {
+		fmt.Fprintln(os.Stderr, "Error: max-inflight-mb must be 0 (unlimited) or more")
+		os.Exit(1)
+	}
+
+	if tempDir != "" {
+		if tempInfo, err := os.Stat(tempDir); err != nil || !tempInfo.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: temp-dir %s is not an existing directory\n", tempDir)
+			os.Exit(1)
+		}
+	}
+
+	if excludeFile != "" {
+		if _, err := os.Stat(excludeFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: exclude-file %s: %v\n", excludeFile, err)
+			os.Exit(1)
+		}
+	}
+
+	if sampleDir != "" && sampleCount < 1 {
+		fmt.Fprintln(os.Stderr, "Error: samples must be at least 1")
+		os.Exit(1)
+	}
+
+	// Validate failure budget
+	if recentDays < 0 {
+		fmt.Fprintln(os.Stderr, "Error: recent-first cannot be negative")
+		os.Exit(1)
+	}
+	if maxFailures < 0 {
+		fmt.Fprintln(os.Stderr, "Error: max-failures cannot be negative")
+		os.Exit(1)
+	}
+	if failFast {
+		maxFailures = 1
+	}
+
 	// Validate workers
 	if workers < 1 {
 		fmt.Fprintln(os.Stderr, "Error: workers must be at least 1")
 		os.Exit(1)
 	}
 
+	// Load previous report up front so a bad path fails before any work is done
+	var previousReport *report.Report
+	if diffPath != "" {
+		previousReport, err = report.Load(diffPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Build config
 	cfg := config.Config{
-		MaxDimension:    maxDim,
-		JPEGQuality:     quality,
-		BackupDir:       backupDir,
-		ThresholdMBPage: threshold,
-		SkipPatterns:    baseCfg.SkipPatterns,
-		Recursive:       recursive,
-		Force:           force,
-		DryRun:          dryRun,
-		Verbose:         verbose,
-		Workers:         workers,
+		MaxDimension:      maxDim,
+		JPEGQuality:       quality,
+		BackupDir:         backupDir,
+		BackupMode:        backupMode,
+		ThresholdMBPage:   threshold,
+		SkipPatterns:      baseCfg.SkipPatterns,
+		ArchiveExtensions: baseCfg.ArchiveExtensions,
+		ExcludeFile:       excludeFile,
+		FormatPolicy:      baseCfg.FormatPolicy,
+		ProcessExtensions: baseCfg.ProcessExtensions,
+		QualityCurve:      baseCfg.QualityCurve,
+		Grayscale:         baseCfg.Grayscale,
+		KeepLowQuality:    baseCfg.KeepLowQuality,
+		KeepModernKBPerMP: baseCfg.KeepModernKBPerMP,
+		ComicInfoRules:    baseCfg.ComicInfoRules,
+		SeriesRules:       baseCfg.SeriesRules,
+		DeviceProfiles:    baseCfg.DeviceProfiles,
+		MountLimits:       config.MountLimits(mountLimits),
+		MaxMegapixels:     maxMP,
+		MaxDecodeMB:       maxDecodeMB,
+		Recursive:         recursive,
+		Force:             force,
+		IgnoreMarker:      reprocess,
+		DryRun:            dryRun,
+		Verbose:           verbose,
+		Workers:           workers,
+		MaxFailures:       maxFailures,
+		Preflight:         preflight,
+		RecentDays:        recentDays,
+		Strict:            strict,
+		Salvage:           salvage,
+		RenameToCBZ:       renamePolicy,
+		OutputDir:         outputDir,
+		OutputName:        outputName,
+		OnCollision:       collisionPolicy,
+		Pages:             pages,
+		Codecs:            codecs,
+		Dither:            dither,
+		AutoLevels:        autoLevels,
+		Deskew:            deskew,
+		ComposeAspect:     compose,
+		Webtoon:           webtoon,
+		Flatten:           flatten,
+		Split:             split,
+		JoinSplit:         joinSplit,
+		AutoLevelsDirs:    baseCfg.AutoLevelsDirs,
+		LevelsClipPercent: baseCfg.LevelsClipPercent,
+		Gamma:             gamma,
+		TempDir:           tempDir,
+		LocalCopy:         localCopy,
+		CopyWorkers:       copyWorkers,
+		TempBudgetMB:      tempBudget,
+		MaxInFlightMB:     maxInFlight,
+		ZipLevel:          zipLevel,
+		ZipMethod:         zipMethod,
+		Durable:           durable,
+		PreserveMTime:     keepMTime,
+		SampleDir:         sampleDir,
+		SampleCount:       sampleCount,
+	}
+
+	// Tracing is opt-in; spans are no-ops unless a provider is installed here
+	shutdownTracing := func(context.Context) error { return nil }
+	if tracing.Enabled(otelURL) {
+		shutdownTracing, err = tracing.Setup(context.Background(), otelURL, version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Create reporter
-	reporter := processor.NewConsoleReporter(verbose, os.Stdout)
+	reporter := processor.NewProgressReporter(progressMode, verbose, os.Stdout)
 
 	// Create pipeline
 	pipeline := processor.NewPipeline(cfg, reporter)
+	abortOnSignal(pipeline)
+	if inject != nil {
+		pipeline.InjectFailure(inject)
+		fmt.Fprintf(os.Stderr, "WARNING: -inject-failure %s: matching archives will fail on purpose\n", inject)
+	}
 
-	// Determine if input is file or directory
-	info, err := os.Stat(inputPath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: cannot access %s: %v\n", inputPath, err)
+	// Determine if input is file or directory; every root must exist before any work starts
+	var info os.FileInfo
+	for _, root := range inputs {
+		if info, err = os.Stat(root); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot access %s: %v\n", root, err)
+			os.Exit(1)
+		}
+	}
+	inputPath := inputs[0]
+	multiRoot := len(inputs) > 1
+	if single != nil && info.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: -output takes an archive, %s is a directory\n", inputPath)
+		single.cleanup()
 		os.Exit(1)
 	}
 
@@ -157,20 +585,51 @@ func main() {
 	}
 
 	var exitCode int
+	var batch *processor.BatchResult
+
+	if interactive {
+		files, err := pipeline.FindRoots(inputs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 
-	if info.IsDir() {
-		result, err := pipeline.ProcessDirectory(inputPath)
+		selected, err := selectInteractively(cfg, pipeline, files, os.Stdin, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("\nProcessing %d selected file(s)...\n\n", len(selected))
+		batch, err = pipeline.ProcessFiles(selected)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			exitCode = 1
-		} else if result.FailedFiles > 0 {
+		} else if batch.FailedFiles > 0 {
 			exitCode = 1
 		}
+	} else if multiRoot || info.IsDir() {
+		// Several roots share one worker pool, summary and report
+		var result *processor.BatchResult
+		files, err := pipeline.FindRoots(inputs)
+		if err == nil {
+			result, err = pipeline.ProcessFiles(files)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitCode = 1
+		} else {
+			batch = result
+			if result.FailedFiles > 0 {
+				exitCode = 1
+			}
+		}
 	} else {
 		result, err := pipeline.ProcessFile(inputPath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			exitCode = 1
+			result = &processor.Result{SourcePath: inputPath, Errors: []error{err}}
 		} else {
 			if len(result.Errors) > 0 {
 				for _, e := range result.Errors {
@@ -182,13 +641,154 @@ func main() {
 				summary := analyzer.NewDryRunSummary([]*analyzer.AnalysisResult{result.Analysis})
 				reporter.OnDryRunComplete(summary)
 			}
+			if single != nil && !dryRun {
+				if err := single.deliver(result); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					exitCode = 1
+				}
+			}
+		}
+		batch = singleFileBatch(result, err)
+	}
+
+	if batch != nil && (reportPath != "" || csvPath != "" || previousReport != nil) {
+		current := report.FromBatch(batch, dryRun)
+		if reportPath != "" {
+			if err := current.WriteFile(reportPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exitCode = 1
+			}
+		}
+		if csvPath != "" {
+			if err := current.WriteCSV(csvPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exitCode = 1
+			}
+		}
+		if previousReport != nil {
+			report.Diff(previousReport, current).WriteText(os.Stdout)
+		}
+	}
+
+	// Record compressed archives for the stats command (not stdin's temp copy)
+	if batch != nil && !dryRun && (single == nil || !single.stdin) {
+		entries := history.FromBatch(batch, cfg.MaxDimension, cfg.JPEGQuality)
+		if err := history.Append(history.DefaultPath(cfg.BackupDir), entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update history: %v\n", err)
+		}
+		session := history.NewSession(batch, cfg, os.Args[1:], pipeline.Backups())
+		if _, err := history.WriteSession(cfg.BackupDir, session); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write session file: %v\n", err)
 		}
 	}
 
+	// Drop completed operations from the replace journal; anything left needs `recover`
+	if err := pipeline.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update replace journal: %v\n", err)
+	}
+
+	// Flush spans before exiting; os.Exit skips deferred calls
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := shutdownTracing(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to export traces: %v\n", err)
+	}
+	cancel()
+
 	// Print config at end
 	fmt.Println()
 	fmt.Println("=== Finished CBZ Compressor ===")
 	fmt.Println(cfg)
 
+	if single != nil {
+		single.cleanup()
+	}
 	os.Exit(exitCode)
 }
+
+// singleFileBatch wraps the outcome of a single-file run so it can be reported like a batch
+func singleFileBatch(result *processor.Result, err error) *processor.BatchResult {
+	batch := &processor.BatchResult{
+		Results:       []processor.Result{*result},
+		TotalFiles:    1,
+		TotalDuration: result.Duration,
+	}
+	switch {
+	case err != nil:
+		batch.FailedFiles = 1
+	case result.Skipped:
+		batch.SkippedFiles = 1
+	default:
+		batch.ProcessedFiles = 1
+		batch.TotalOriginal = result.OriginalSize
+		batch.TotalCompressed = result.CompressedSize
+	}
+	return batch
+}
+
+// inputList collects repeated -input flags
+type inputList []string
+
+func (l *inputList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *inputList) Set(path string) error {
+	*l = append(*l, path)
+	return nil
+}
+
+// mountLimitList collects repeated -mount-limit PATH=N flags
+type mountLimitList config.MountLimits
+
+func (l mountLimitList) String() string {
+	return config.MountLimits(l).String()
+}
+
+func (l mountLimitList) Set(spec string) error {
+	i := strings.LastIndex(spec, "=")
+	if i <= 0 {
+		return fmt.Errorf("%q is not PATH=N", spec)
+	}
+	limit, err := strconv.Atoi(spec[i+1:])
+	if err != nil {
+		return fmt.Errorf("%q is not PATH=N", spec)
+	}
+	l[spec[:i]] = limit
+	return nil
+}
+
+// hiddenFlags are developer flags -help leaves out
+var hiddenFlags = map[string]bool{"inject-failure": true}
+
+// printFlags prints the defaults of every flag in fs but the hidden ones
+func printFlags(fs *flag.FlagSet) {
+	visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	visible.SetOutput(fs.Output())
+	fs.VisitAll(func(f *flag.Flag) {
+		if hiddenFlags[f.Name] {
+			return
+		}
+		visible.Var(f.Value, f.Name, f.Usage)
+		visible.Lookup(f.Name).DefValue = f.DefValue
+	})
+	visible.PrintDefaults()
+}
+
+// abortOnSignal stops the run on Ctrl-C or SIGTERM without leaving an
+// original half-replaced: the archive being swapped is finished or put back
+// before exiting. A second signal exits at once; `recover` cleans up then.
+func abortOnSignal(pipeline *processor.Pipeline) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		fmt.Fprintln(os.Stderr, "\nInterrupted: restoring the archive being replaced (press Ctrl-C again to quit now)")
+		if !pipeline.Abort(30 * time.Second) {
+			fmt.Fprintln(os.Stderr, "Warning: a replacement did not finish; run `recover` to resolve it")
+		} else if err := pipeline.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update replace journal: %v\n", err)
+		}
+		os.Exit(130)
+	}()
+}
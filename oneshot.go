@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"compress_comics/internal/analyzer"
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+	"compress_comics/internal/history"
+	"compress_comics/internal/processor"
+	"compress_comics/internal/report"
+)
+
+// Exit codes of oneshot mode
+const (
+	oneshotOK       = 0 // Every archive processed or skipped
+	oneshotFailures = 1 // Some archives failed, or the batch was aborted
+	oneshotConfig   = 2 // Invalid environment; nothing was touched
+	oneshotFatal    = 3 // The run could not start (unreadable input, scan error)
+)
+
+// Variables oneshot reads itself; every other CBZ_ variable is a config key
+const (
+	envInput       = "CBZ_INPUT"           // Archives or directories, separated like PATH (required)
+	envConfig      = "CBZ_CONFIG"          // Config file to start from (default: built-in defaults only)
+	envDryRun      = "CBZ_DRY_RUN"         // Analyze only
+	envForce       = "CBZ_FORCE"           // Process archives that look optimized
+	envWorkers     = "CBZ_WORKERS"         // Parallel workers (default: CPU count)
+	envMaxFailures = "CBZ_MAX_FAILURES"    // Abort after this many failed archives (0 = never)
+	envTempBudget  = "CBZ_TEMP_BUDGET_MB"  // Temp space the archives in flight may take (0 = unlimited)
+	envMaxInFlight = "CBZ_MAX_INFLIGHT_MB" // Archive contents in memory at once (0 = unlimited)
+	envReport      = "CBZ_REPORT"          // JSON report file
+	envHealthAddr  = "CBZ_HEALTH_ADDR"     // Healthcheck listen address (default :8080, "off" disables)
+	envHealthStall = "CBZ_HEALTH_STALL"    // Healthcheck fails after this long without progress (default 30m)
+)
+
+var oneshotEnv = map[string]bool{
+	envInput: true, envConfig: true, envDryRun: true, envForce: true, envWorkers: true,
+	envMaxFailures: true, envTempBudget: true, envMaxInFlight: true, envReport: true, envHealthAddr: true, envHealthStall: true,
+}
+
+// oneshotOptions are the settings of a oneshot run besides the config
+type oneshotOptions struct {
+	inputs      []string
+	reportPath  string
+	healthAddr  string
+	healthStall time.Duration
+}
+
+// runOneshot implements --oneshot: one batch for containers and CronJobs,
+// configured only through CBZ_* environment variables, printing JSON lines
+// on stdout and serving a healthcheck while it runs
+func runOneshot(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Error: --oneshot takes no flags; configure it with CBZ_* environment variables")
+		return oneshotConfig
+	}
+
+	cfg, opts, err := oneshotSettings(os.Environ())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return oneshotConfig
+	}
+
+	events := newJSONReporter(os.Stdout)
+	pipeline := processor.NewPipeline(*cfg, events)
+	abortOnSignal(pipeline)
+
+	if opts.healthAddr != "" {
+		server, err := serveHealth(opts.healthAddr, events, opts.healthStall)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: healthcheck: %v\n", err)
+			return oneshotFatal
+		}
+		defer server.Close()
+	}
+
+	files, err := pipeline.FindRoots(opts.inputs)
+	if err != nil {
+		events.fatal(err)
+		return oneshotFatal
+	}
+	batch, err := pipeline.ProcessFiles(files)
+	if err != nil {
+		events.fatal(err)
+		return oneshotFatal
+	}
+
+	code := oneshotOK
+	if batch.FailedFiles > 0 || batch.Aborted {
+		code = oneshotFailures
+	}
+	rep := report.FromBatch(batch, cfg.DryRun)
+	events.summary(rep)
+	if opts.reportPath != "" {
+		if err := rep.WriteFile(opts.reportPath); err != nil {
+			events.fatal(err)
+			code = oneshotFatal
+		}
+	}
+	if !cfg.DryRun {
+		entries := history.FromBatch(batch, cfg.MaxDimension, cfg.JPEGQuality)
+		if err := history.Append(history.DefaultPath(cfg.BackupDir), entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update history: %v\n", err)
+		}
+		session := history.NewSession(batch, *cfg, os.Args[1:], pipeline.Backups())
+		if _, err := history.WriteSession(cfg.BackupDir, session); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write session file: %v\n", err)
+		}
+	}
+	if err := pipeline.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update replace journal: %v\n", err)
+	}
+	return code
+}
+
+// oneshotSettings builds the config from built-in defaults (or CBZ_CONFIG)
+// and the environment, rejecting anything it doesn't recognize
+func oneshotSettings(environ []string) (*config.Config, oneshotOptions, error) {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok && oneshotEnv[name] {
+			env[name] = value
+		}
+	}
+	opts := oneshotOptions{
+		reportPath:  env[envReport],
+		healthAddr:  ":8080",
+		healthStall: 30 * time.Minute,
+	}
+
+	for _, input := range filepath.SplitList(env[envInput]) {
+		if input != "" {
+			opts.inputs = append(opts.inputs, input)
+		}
+	}
+	if len(opts.inputs) == 0 {
+		return nil, opts, fmt.Errorf("%s is required", envInput)
+	}
+
+	defaults := config.DefaultConfig()
+	cfg := &defaults
+	if path := env[envConfig]; path != "" {
+		var err error
+		if cfg, err = config.LoadFromFile(path); err != nil {
+			return nil, opts, fmt.Errorf("%s: %w", envConfig, err)
+		}
+	}
+	if err := config.LoadEnv(cfg, environ, oneshotEnv); err != nil {
+		return nil, opts, err
+	}
+	if cfg.JPEGQuality < 1 || cfg.JPEGQuality > 100 {
+		return nil, opts, fmt.Errorf("CBZ_JPEG_QUALITY must be between 1 and 100")
+	}
+	if cfg.KeepModernKBPerMP < 0 {
+		return nil, opts, fmt.Errorf("CBZ_KEEP_MODERN_KB_PER_MP cannot be negative")
+	}
+	if err := cbz.ValidateZipMethod(cfg.ZipMethod); err != nil {
+		return nil, opts, fmt.Errorf("CBZ_ZIP_METHOD: %w", err)
+	}
+	for _, validate := range []func() error{
+		cfg.FormatPolicy.Validate, cfg.ProcessExtensions.Validate, cfg.QualityCurve.Validate, cfg.ComicInfoRules.Validate, cfg.SeriesRules.Validate, cfg.DeviceProfiles.Validate, cfg.MountLimits.Validate,
+	} {
+		if err := validate(); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	// The CLI's flag defaults
+	cfg.Workers = runtime.NumCPU()
+	cfg.CopyWorkers = 2
+	cfg.PreserveMTime = true
+	cfg.RenameToCBZ = processor.RenameKeep
+	cfg.OnCollision = processor.CollisionSkip
+	cfg.OutputName = processor.DefaultOutputName
+	cfg.SampleCount = 3
+	cfg.Preflight = true
+
+	var err error
+	parseBool := func(name string, dst *bool) {
+		if v, ok := env[name]; ok && err == nil {
+			if *dst, err = strconv.ParseBool(v); err != nil {
+				err = fmt.Errorf("%s: %q is not a boolean", name, v)
+			}
+		}
+	}
+	parseInt := func(name string, dst *int, min int) {
+		if v, ok := env[name]; ok && err == nil {
+			n, convErr := strconv.Atoi(v)
+			if convErr != nil || n < min {
+				err = fmt.Errorf("%s: %q is not a number of at least %d", name, v, min)
+				return
+			}
+			*dst = n
+		}
+	}
+	parseBool(envDryRun, &cfg.DryRun)
+	parseBool(envForce, &cfg.Force)
+	parseInt(envWorkers, &cfg.Workers, 1)
+	parseInt(envMaxFailures, &cfg.MaxFailures, 0)
+	parseInt(envTempBudget, &cfg.TempBudgetMB, 0)
+	parseInt(envMaxInFlight, &cfg.MaxInFlightMB, 0)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	if addr, ok := env[envHealthAddr]; ok {
+		opts.healthAddr = addr
+		if addr == "off" {
+			opts.healthAddr = ""
+		}
+	}
+	if v, ok := env[envHealthStall]; ok {
+		if opts.healthStall, err = time.ParseDuration(v); err != nil || opts.healthStall <= 0 {
+			return nil, opts, fmt.Errorf("%s: %q is not a positive duration (e.g. 30m)", envHealthStall, v)
+		}
+	}
+	return cfg, opts, nil
+}
+
+// jsonReporter prints one JSON object per line: a "file" event for each
+// archive (the fields of a report entry) and a final "summary" event (the
+// report summary). It also keeps the counts the healthcheck serves.
+type jsonReporter struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	done int
+	// Healthcheck state
+	total        int
+	failed       int
+	started      time.Time
+	lastProgress time.Time
+	bytesDone    int64
+	bytesTotal   int64
+	elapsed      time.Duration
+}
+
+func newJSONReporter(w io.Writer) *jsonReporter {
+	now := time.Now()
+	return &jsonReporter{enc: json.NewEncoder(w), started: now, lastProgress: now}
+}
+
+// emit writes one event; callers hold r.mu
+func (r *jsonReporter) emit(event string, fields any) {
+	r.enc.Encode(struct {
+		Event  string `json:"event"`
+		Fields any    `json:"data"`
+	}{event, fields})
+}
+
+// fatal reports an error that ends the run
+func (r *jsonReporter) fatal(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emit("error", map[string]string{"error": err.Error()})
+}
+
+func (r *jsonReporter) OnFileStart(path string, index, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total = total
+	r.lastProgress = time.Now()
+}
+
+func (r *jsonReporter) OnPageProgress(path string, progress processor.PageProgress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastProgress = time.Now()
+}
+
+func (r *jsonReporter) OnBatchProgress(processedBytes, totalBytes int64, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bytesDone, r.bytesTotal, r.elapsed = processedBytes, totalBytes, elapsed
+}
+
+func (r *jsonReporter) OnFileComplete(result processor.Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := report.NewFileEntry(result)
+	r.done++
+	if entry.Status == report.StatusFailed {
+		r.failed++
+	}
+	r.lastProgress = time.Now()
+	r.emit("file", entry)
+}
+
+// summary reports the batch totals; it is the last line of a run
+func (r *jsonReporter) summary(rep *report.Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emit("summary", rep.Summary)
+}
+
+func (r *jsonReporter) OnBatchComplete(result processor.BatchResult)                   {}
+func (r *jsonReporter) OnDryRunFile(result *analyzer.AnalysisResult)                   {}
+func (r *jsonReporter) OnFileSkipped(path string, reason string)                       {}
+func (r *jsonReporter) OnImageProcessed(imagePath string, originalSize, newSize int64) {}
+func (r *jsonReporter) OnDryRunComplete(summary *analyzer.DryRunSummary)               {}
+
+// health is the healthcheck response
+type health struct {
+	Status       string    `json:"status"` // "running", or "stalled" with status 503
+	FilesDone    int       `json:"files_done"`
+	FilesTotal   int       `json:"files_total"`
+	Failed       int       `json:"failed"`
+	StartedAt    time.Time `json:"started_at"`
+	LastProgress time.Time `json:"last_progress"`
+	BytesDone    int64     `json:"bytes_done"`
+	BytesTotal   int64     `json:"bytes_total"`
+	ETASeconds   int64     `json:"eta_seconds,omitempty"` // Omitted until there's a rate to go on
+}
+
+// serveHealth answers GET /healthz while the batch runs: 200 while archives
+// keep completing, 503 once nothing has happened for stall (a hung decode
+// or a dead share), so a liveness probe can restart the job
+func serveHealth(addr string, r *jsonReporter, stall time.Duration) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		r.mu.Lock()
+		h := health{
+			Status:       "running",
+			FilesDone:    r.done,
+			FilesTotal:   r.total,
+			Failed:       r.failed,
+			StartedAt:    r.started,
+			LastProgress: r.lastProgress,
+			BytesDone:    r.bytesDone,
+			BytesTotal:   r.bytesTotal,
+			ETASeconds:   int64(processor.EstimateRemaining(r.bytesDone, r.bytesTotal, r.elapsed).Seconds()),
+		}
+		r.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if time.Since(h.LastProgress) > stall {
+			h.Status = "stalled"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Warning: healthcheck stopped: %v\n", err)
+		}
+	}()
+	return server, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"compress_comics/internal/config"
+	"compress_comics/internal/journal"
+)
+
+// runRecover implements the recover subcommand
+func runRecover(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	var (
+		backupDir string
+		rollback  bool
+		dryRun    bool
+	)
+	fs.StringVar(&backupDir, "backup", baseCfg.BackupDir, "Backup directory holding the replace journal")
+	fs.StringVar(&backupDir, "b", baseCfg.BackupDir, "Backup directory (shorthand)")
+	fs.BoolVar(&rollback, "rollback", false, "Always restore originals instead of finishing interrupted replacements")
+	fs.BoolVar(&dryRun, "dry-run", false, "List interrupted replacements without changing anything")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s recover [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Resolves replacements left unfinished by a crash, using the journal in the backup directory.\n")
+		fmt.Fprintf(os.Stderr, "By default a replacement whose compressed archive is intact is finished; otherwise the original is restored.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	path := journal.DefaultPath(backupDir)
+	records, err := journal.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	pending := journal.Pending(records)
+	if len(pending) == 0 {
+		fmt.Printf("No interrupted replacements in %s\n", path)
+		return 0
+	}
+
+	fmt.Printf("Found %d interrupted replacement(s) in %s\n\n", len(pending), path)
+
+	j := journal.New(path)
+	var failed int
+	for _, rec := range pending {
+		if dryRun {
+			fmt.Printf("[%s] %s (started %s)\n", rec.State, rec.Target, rec.Time.Format("2006-01-02 15:04:05"))
+			continue
+		}
+
+		outcome, err := j.Recover(rec, rollback)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[FAIL] %s: %v\n", rec.Target, err)
+			failed++
+			continue
+		}
+		fmt.Printf("[%s] %s\n", outcome, rec.Target)
+	}
+
+	if err := j.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to compact journal: %v\n", err)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compress_comics/internal/backup"
+	"compress_comics/internal/cbz"
+	"compress_comics/internal/config"
+	"compress_comics/internal/fsutil"
+	"compress_comics/internal/processor"
+)
+
+// runRepair implements the repair subcommand
+func runRepair(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	var (
+		inputPath string
+		suffix    string
+		replace   bool
+		backupDir string
+		overwrite bool
+		recursive bool
+		dryRun    bool
+		verbose   bool
+	)
+	fs.StringVar(&inputPath, "input", "", "Path to CBZ file or directory (required)")
+	fs.StringVar(&inputPath, "i", "", "Path to CBZ file or directory (shorthand)")
+	fs.StringVar(&suffix, "suffix", ".repaired", "Added to the archive name for the repaired copy")
+	fs.BoolVar(&replace, "replace", false, "Replace the damaged archive, moving it to the backup directory, instead of writing a copy")
+	fs.StringVar(&backupDir, "backup", baseCfg.BackupDir, "Backup directory for damaged originals with -replace (backup_mode applies)")
+	fs.StringVar(&backupDir, "b", baseCfg.BackupDir, "Backup directory (shorthand)")
+	fs.BoolVar(&overwrite, "overwrite", false, "Replace existing repaired copies")
+	fs.BoolVar(&recursive, "recursive", true, "Scan directories recursively")
+	fs.BoolVar(&dryRun, "dry-run", false, "Report what could be salvaged without writing anything")
+	fs.BoolVar(&verbose, "verbose", false, "List every lost entry")
+	fs.BoolVar(&verbose, "v", false, "List every lost entry (shorthand)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s repair -input <path> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Salvages the intact entries of corrupt or truncated CBZs (reading local headers\n")
+		fmt.Fprintf(os.Stderr, "when the central directory is damaged) and writes a clean archive of them.\n")
+		fmt.Fprintf(os.Stderr, "Healthy archives are left alone. Each damaged archive is read whole into memory.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if inputPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -input is required")
+		fs.Usage()
+		return 1
+	}
+	if !replace && suffix == "" {
+		fmt.Fprintln(os.Stderr, "Error: -suffix cannot be empty (use -replace to repair in place)")
+		return 1
+	}
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot access %s: %v\n", inputPath, err)
+		return 1
+	}
+
+	files := []string{inputPath}
+	if info.IsDir() {
+		cfg := *baseCfg
+		cfg.Recursive = recursive
+		files, err = processor.FindArchives(cfg, inputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("No CBZ files found in %s\n", inputPath)
+		return 0
+	}
+
+	mode, err := backup.ParseMode(baseCfg.BackupMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	writer := cbz.NewWriter(cbz.WriterOptions{Level: baseCfg.ZipLevel, Store: baseCfg.ZipMethod == cbz.ZipMethodStore})
+	backups := backup.NewManager(backupDir, mode)
+	var healthy, repaired, failed int
+
+	for _, path := range files {
+		salvage, err := cbz.Salvage(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[FAIL] %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		if !salvage.Scanned && salvage.Recovered == 0 && len(salvage.Lost) == 0 {
+			healthy++
+			continue
+		}
+
+		contents := salvage.Contents
+		if len(contents.Images) == 0 {
+			fmt.Fprintf(os.Stderr, "[FAIL] %s: no readable pages (%d entries lost)\n", path, len(salvage.Lost))
+			failed++
+			continue
+		}
+
+		summary := fmt.Sprintf("%d pages, %d other files kept, %d entries lost", len(contents.Images), len(contents.OtherFiles), len(salvage.Lost))
+		if salvage.Scanned {
+			summary += ", central directory rebuilt"
+		}
+
+		outPath := strings.TrimSuffix(path, filepath.Ext(path)) + suffix + filepath.Ext(path)
+		if replace {
+			outPath = path
+		}
+
+		if dryRun {
+			fmt.Printf("[REPAIR] %s: %s\n", path, summary)
+		} else if err := writeRepaired(writer, backups, contents, path, outPath, replace, overwrite); err != nil {
+			fmt.Fprintf(os.Stderr, "[FAIL] %s: %v\n", path, err)
+			failed++
+			continue
+		} else {
+			fmt.Printf("[REPAIRED] %s -> %s: %s\n", path, filepath.Base(outPath), summary)
+		}
+		repaired++
+
+		if verbose {
+			for _, entry := range salvage.Lost {
+				fmt.Printf("    lost %s\n", entry)
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("=== Repair Summary ===")
+	fmt.Printf("Healthy:    %d\n", healthy)
+	if dryRun {
+		fmt.Printf("Repairable: %d\n", repaired)
+	} else {
+		fmt.Printf("Repaired:   %d\n", repaired)
+	}
+	fmt.Printf("Failed:     %d\n", failed)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeRepaired writes the salvaged contents to outPath. With replace,
+// outPath is the source path: the damaged source moves to backup once the
+// repaired archive is complete.
+func writeRepaired(writer *cbz.Writer, backups *backup.Manager, contents *cbz.Contents, source, outPath string, replace, overwrite bool) error {
+	target := outPath
+	if replace {
+		target = source + cbz.TempSuffix
+	} else if _, err := os.Stat(outPath); err == nil && !overwrite {
+		return fmt.Errorf("%s exists (use -overwrite)", outPath)
+	}
+
+	archive, err := writer.Begin(target)
+	if err != nil {
+		return err
+	}
+	for _, img := range contents.Images {
+		if err := archive.Add(cbz.BytesEntry(img.Path, img.Data)); err != nil {
+			return err
+		}
+	}
+	for _, other := range contents.OtherFiles {
+		if err := archive.Add(cbz.BytesEntry(other.Path, other.Data)); err != nil {
+			return err
+		}
+	}
+	// Not compressed: later runs must still process it
+	archive.Unmarked()
+	if err := archive.Commit(); err != nil {
+		return err
+	}
+
+	if !replace {
+		return nil
+	}
+	if _, err := backups.MoveToBackup(source); err != nil {
+		os.Remove(target)
+		return fmt.Errorf("failed to back up damaged archive: %w", err)
+	}
+	if err := fsutil.Rename(target, source); err != nil {
+		return fmt.Errorf("failed to move repaired archive into place (original is in backup): %w", err)
+	}
+	return nil
+}
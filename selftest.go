@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"compress_comics/internal/config"
+	"compress_comics/internal/processor"
+	"compress_comics/internal/selftest"
+)
+
+// runSelftest implements the selftest subcommand
+func runSelftest(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	var (
+		quality  int
+		maxDim   int
+		writeDir string
+	)
+	fs.IntVar(&quality, "quality", baseCfg.JPEGQuality, "JPEG quality (1-100)")
+	fs.IntVar(&quality, "q", baseCfg.JPEGQuality, "JPEG quality (shorthand)")
+	fs.IntVar(&maxDim, "max-dim", baseCfg.MaxDimension, "Maximum dimension in pixels (long edge)")
+	fs.StringVar(&writeDir, "write", "", "Also save each source page and its processed version in this directory")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s selftest [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Compresses a built-in set of representative pages (color art, screentone\n")
+		fmt.Fprintf(os.Stderr, "manga, a text page, a photographic cover) with the config file's page settings\n")
+		fmt.Fprintf(os.Stderr, "and checks each result's size and SSIM against fixed bounds, to verify that\n")
+		fmt.Fprintf(os.Stderr, "this build and these settings behave sanely. Exits with status 1 on a failure.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if quality < 1 || quality > 100 {
+		fmt.Fprintln(os.Stderr, "Error: quality must be between 1 and 100")
+		return 1
+	}
+	if maxDim < 1 {
+		fmt.Fprintln(os.Stderr, "Error: max-dim must be positive")
+		return 1
+	}
+	cfg := *baseCfg
+	cfg.JPEGQuality = quality
+	cfg.MaxDimension = maxDim
+
+	fmt.Printf("cbz-compress %s selftest (%s/%s, %s), quality %d, max dimension %d\n\n",
+		version, runtime.GOOS, runtime.GOARCH, runtime.Version(), quality, maxDim)
+	fmt.Printf("%-16s %-30s %10s %10s %7s %7s  %s\n", "Page", "Kind", "Source", "Output", "Size", "SSIM", "Result")
+
+	failed := 0
+	for _, outcome := range selftest.Run(cfg) {
+		page := outcome.Page
+		if outcome.Err != nil {
+			failed++
+			fmt.Printf("%-16s %-30s %10s %10s %7s %7s  FAIL: %v\n", page.Name, page.Kind, processor.FormatBytes(outcome.SourceBytes), "-", "-", "-", outcome.Err)
+			continue
+		}
+
+		var problems []string
+		if outcome.Ratio() > page.MaxRatio {
+			problems = append(problems, fmt.Sprintf("size above %.0f%%", page.MaxRatio*100))
+		}
+		if outcome.SSIM < page.MinSSIM {
+			problems = append(problems, fmt.Sprintf("SSIM below %g", page.MinSSIM))
+		}
+		status := "ok"
+		if outcome.Kept {
+			status = "ok (kept as is)"
+		}
+		if len(problems) > 0 {
+			failed++
+			status = "FAIL: " + strings.Join(problems, ", ")
+		}
+		fmt.Printf("%-16s %-30s %10s %10s %6.0f%% %7.4f  %s\n", page.Name, page.Kind,
+			processor.FormatBytes(outcome.SourceBytes), processor.FormatBytes(outcome.OutputBytes),
+			outcome.Ratio()*100, outcome.SSIM, status)
+
+		if writeDir != "" {
+			if err := writeSelftestPair(writeDir, outcome); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		fmt.Printf("%d of %d pages failed\n", failed, len(selftest.Corpus))
+		return 1
+	}
+	fmt.Printf("All %d pages passed\n", len(selftest.Corpus))
+	return 0
+}
+
+// writeSelftestPair saves a page's source and processed version side by side
+func writeSelftestPair(dir string, outcome selftest.Outcome) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := outcome.Page.Name
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if err := os.WriteFile(filepath.Join(dir, stem+".source"+filepath.Ext(name)), outcome.Source, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, stem+".output"+filepath.Ext(outcome.OutputName)), outcome.Output, 0644)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"compress_comics/internal/config"
+	"compress_comics/internal/history"
+	"compress_comics/internal/processor"
+)
+
+// runStats implements the stats subcommand
+func runStats(args []string) int {
+	baseCfg, err := config.LoadWithDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", config.DefaultConfigFileName, err)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	var (
+		backupDir string
+		top       int
+	)
+	fs.StringVar(&backupDir, "backup", baseCfg.BackupDir, "Backup directory holding the history database")
+	fs.StringVar(&backupDir, "b", baseCfg.BackupDir, "Backup directory (shorthand)")
+	fs.IntVar(&top, "top", 10, "Number of series to list by reclaimed space")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s stats [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Shows cumulative savings from past runs, recorded in the backup directory.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	path := history.DefaultPath(backupDir)
+	entries, err := history.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No history in %s\n", path)
+		return 0
+	}
+
+	stats := history.Summarize(entries)
+
+	fmt.Println("=== Savings History ===")
+	fmt.Printf("Archives compressed: %d\n", stats.Total.Files)
+	fmt.Printf("Total before:        %s\n", processor.FormatBytes(stats.Total.OriginalSize))
+	fmt.Printf("Total after:         %s\n", processor.FormatBytes(stats.Total.CompressedSize))
+	fmt.Printf("Total saved:         %s (%.1f%%)\n", processor.FormatBytes(stats.Total.Saved()), stats.Total.SavingsPercent())
+
+	fmt.Println()
+	fmt.Println("=== By Month ===")
+	for _, b := range stats.ByMonth {
+		printBucket(b.Key, b)
+	}
+
+	fmt.Println()
+	fmt.Println("=== By Settings ===")
+	for _, b := range stats.BySettings {
+		printBucket(b.Key, b)
+	}
+
+	fmt.Println()
+	fmt.Printf("=== Top Series ===\n")
+	for i, b := range stats.BySeries {
+		if top > 0 && i >= top {
+			break
+		}
+		printBucket(filepath.Base(b.Key), b)
+	}
+
+	return 0
+}
+
+func printBucket(label string, b history.Bucket) {
+	fmt.Printf("  %-32s %5d files  %10s saved  %5.1f%%\n",
+		label, b.Files, processor.FormatBytes(b.Saved()), b.SavingsPercent())
+}
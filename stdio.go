@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"compress_comics/internal/fsutil"
+	"compress_comics/internal/processor"
+)
+
+// stdinName is what an archive read from stdin is called in messages and
+// the output (the zip reader needs a seekable file, so it is copied first)
+const stdinName = "stdin.cbz"
+
+// singleOutput runs one archive through a temp directory for -output and
+// -input -, so neither the input nor anything else on disk is replaced
+type singleOutput struct {
+	dir     string   // Temp directory holding the stdin copy and the output
+	input   string   // Archive to process (the stdin copy for -input -)
+	target  string   // Where the result goes ("-" = stdout)
+	stdin   bool     // input is a copy of stdin
+	archive *os.File // The real stdout, when the archive is written there
+}
+
+// newSingleOutput prepares -output (target) for input. With target "-",
+// every message goes to stderr from here on, keeping stdout for the archive.
+func newSingleOutput(input, target string) (*singleOutput, error) {
+	dir, err := os.MkdirTemp("", "cbz-compress-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	s := &singleOutput{dir: dir, input: input, target: target}
+
+	if target == "-" {
+		s.archive = os.Stdout
+		os.Stdout = os.Stderr
+	}
+
+	if input == "-" {
+		s.stdin = true
+		s.input = filepath.Join(dir, "in", stdinName)
+		if err := copyStdin(s.input); err != nil {
+			s.cleanup()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// copyStdin saves stdin to path
+func copyStdin(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, os.Stdin); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to read archive from stdin: %w", err)
+	}
+	return f.Close()
+}
+
+// outputDir is the -output-dir the pipeline writes the compressed archive to
+func (s *singleOutput) outputDir() string {
+	return filepath.Join(s.dir, "out")
+}
+
+// deliver writes the outcome of the run to the target: the compressed
+// archive, or the input itself when it was skipped, so the target always
+// gets a usable archive
+func (s *singleOutput) deliver(result *processor.Result) error {
+	path := result.OutputPath
+	if result.Skipped || path == "" {
+		path = s.input
+	}
+
+	if s.archive == nil {
+		if path == s.input {
+			return fsutil.Copy(path, s.target)
+		}
+		return fsutil.Move(path, s.target)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(s.archive, f); err != nil {
+		return fmt.Errorf("failed to write archive to stdout: %w", err)
+	}
+	return nil
+}
+
+// cleanup removes the temp directory
+func (s *singleOutput) cleanup() {
+	os.RemoveAll(s.dir)
+}